@@ -0,0 +1,554 @@
+// Command atsaft validates and inspects SAF-T (PT) files.
+//
+// Usage:
+//
+//	atsaft validate FILE
+//	atsaft summary FILE
+//	atsaft verify-hashes -key PUBKEY.pem FILE
+//	atsaft convert [-encoding utf-8|windows-1252] [-indent N] [-basis F|C|I|...] [-o OUT] FILE
+//	atsaft extract (-section NAME | -doc NUMBER) FILE
+//	atsaft diff [-json] OLD NEW
+//	atsaft export [-format csv|ndjson] [-o DIR|OUT] [-comma C] [-bom] FILE
+//	atsaft anonymize [-key PRIVKEY.pem] [-key-version N] [-o OUT] FILE
+//
+// FILE may be "-" to read from standard input. The exit code is 0 on
+// success, 1 when validate or verify-hashes find problems or diff finds
+// differences and 2 on usage or I/O errors.
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/anonymize"
+	"github.com/hestiatechnology/autoridadetributaria/saft/check"
+	"github.com/hestiatechnology/autoridadetributaria/saft/diff"
+	"github.com/hestiatechnology/autoridadetributaria/saft/export"
+	"github.com/hestiatechnology/autoridadetributaria/sign"
+)
+
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitError    = 2
+)
+
+var commands = map[string]func(args []string) int{
+	"validate":      validate,
+	"summary":       summary,
+	"verify-hashes": verifyHashes,
+	"convert":       convert,
+	"extract":       extract,
+	"diff":          diffFiles,
+	"export":        exportTables,
+	"anonymize":     anonymizeFile,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "atsaft: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(exitError)
+	}
+	os.Exit(cmd(os.Args[2:]))
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: atsaft <command> [flags] FILE
+
+commands:
+  validate        run every SAF-T check and list the problems found
+  summary         print counts and totals by document type and tax rate
+  verify-hashes   verify the document hash chains with a public key
+  convert         re-encode or pretty print a file, or prune it to a profile
+  extract         print one section or document
+  diff            compare two files by customer, product and document
+  export          flatten a file into CSV or NDJSON tables
+  anonymize       replace names, addresses, contacts and NIFs with pseudonyms
+`)
+}
+
+// parse parses the flags of a command and loads its FILE argument.
+func parse(fs *flag.FlagSet, args []string) (*saft.AuditFile, bool) {
+	if err := fs.Parse(args); err != nil {
+		return nil, false
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "atsaft %s: expected one FILE argument\n", fs.Name())
+		fs.Usage()
+		return nil, false
+	}
+	a, err := load(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft %s: %v\n", fs.Name(), err)
+		return nil, false
+	}
+	return a, true
+}
+
+func load(path string) (*saft.AuditFile, error) {
+	if path == "-" {
+		return saft.Decode(os.Stdin)
+	}
+	return saft.FromXML(path)
+}
+
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+
+	findings := check.Validate(a)
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	if len(findings) > 0 {
+		fmt.Printf("%d problem(s) found\n", len(findings))
+		return exitFindings
+	}
+	fmt.Println("OK")
+	return exitOK
+}
+
+func summary(args []string) int {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+
+	s := a.Summary()
+	fmt.Printf("Company:    %s (%d)\n", a.Header.CompanyName, a.Header.TaxRegistrationNumber)
+	fmt.Printf("Period:     %s to %s\n", date(a.Header.StartDate), date(a.Header.EndDate))
+	fmt.Printf("Basis:      %s\n\n", a.Header.TaxAccountingBasis)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Customers\tSuppliers\tProducts\tTaxTable\tAccounts\tTransactions\t")
+	fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t\n", s.Customers, s.Suppliers, s.Products, s.TaxTableEntries, s.Accounts, s.Transactions)
+	w.Flush()
+
+	if len(s.Documents) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Section\tType\tCount\tExcluded\tNet\tTax\tGross\t")
+		for _, d := range s.Documents {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\t\n", d.Section, d.Type, d.Count, d.Excluded, d.NetTotal.StringFixed(2), d.TaxPayable.StringFixed(2), d.GrossTotal.StringFixed(2))
+		}
+		w.Flush()
+	}
+
+	if len(s.TaxRates) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Tax\tRegion\tCode\tRate\tLines\tBase\tTax\t")
+		for _, r := range s.TaxRates {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t\n", r.TaxType, r.TaxCountryRegion, r.TaxCode, r.TaxPercentage, r.Lines, r.TaxBase.StringFixed(2), r.TaxAmount.StringFixed(2))
+		}
+		w.Flush()
+	}
+	return exitOK
+}
+
+func date(d saft.SafptdateSpan) string {
+	return time.Time(d).Format(time.DateOnly)
+}
+
+func verifyHashes(args []string) int {
+	fs := flag.NewFlagSet("verify-hashes", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "PEM `file` with the software producer's public key or certificate")
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+	if *keyFile == "" {
+		fmt.Fprintln(os.Stderr, "atsaft verify-hashes: -key is required")
+		return exitError
+	}
+	pub, err := loadPublicKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft verify-hashes: %v\n", err)
+		return exitError
+	}
+
+	report := check.VerifyHashes(a, pub)
+	for _, f := range report.Findings {
+		fmt.Println(f)
+	}
+	fmt.Printf("%d verified, %d invalid, %d skipped (previous document not in file)\n", report.Verified, len(report.Findings), report.Skipped)
+	if len(report.Findings) > 0 {
+		return exitFindings
+	}
+	return exitOK
+}
+
+// loadPublicKey reads an RSA public key from a PEM PUBLIC KEY, RSA PUBLIC
+// KEY or CERTIFICATE block.
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failure decoding PEM block")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA public key: %T", key)
+	}
+	return pub, nil
+}
+
+func convert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	encoding := fs.String("encoding", "windows-1252", "output `encoding`: windows-1252 or utf-8")
+	indent := fs.Int("indent", 4, "spaces per indentation level; 0 writes a single line")
+	basis := fs.String("basis", "", "prune the file to the sections of this TaxAccountingBasis and validate it")
+	out := fs.String("o", "-", "output `file`")
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+
+	w, closeOut, err := output(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft convert: %v\n", err)
+		return exitError
+	}
+	opts := saft.XMLOptions{Encoding: *encoding, Indent: strings.Repeat(" ", *indent)}
+	if *basis != "" {
+		err = a.Export(w, *basis, opts)
+	} else {
+		err = a.WriteXML(w, opts)
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft convert: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func output(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+func extract(args []string) int {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	section := fs.String("section", "", "`name` of the section: Header, MasterFiles, Customer, Supplier, Product, TaxTable, GeneralLedgerAccounts, GeneralLedgerEntries, SourceDocuments, SalesInvoices, MovementOfGoods, WorkingDocuments or Payments")
+	doc := fs.String("doc", "", "document `number`, e.g. \"FT A/1\"")
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+	if (*section == "") == (*doc == "") {
+		fmt.Fprintln(os.Stderr, "atsaft extract: use one of -section or -doc")
+		return exitError
+	}
+
+	var v any
+	if *doc != "" {
+		v = findDocument(a, *doc)
+	} else {
+		v = findSection(a, *section)
+	}
+	if v == nil {
+		fmt.Fprintln(os.Stderr, "atsaft extract: not found")
+		return exitFindings
+	}
+
+	out, err := xml.MarshalIndent(v, "", "    ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft extract: %v\n", err)
+		return exitError
+	}
+	fmt.Println(string(out))
+	return exitOK
+}
+
+func findSection(a *saft.AuditFile, name string) any {
+	mf := &a.MasterFiles
+	sd := a.SourceDocuments
+	if sd == nil {
+		sd = &saft.SourceDocuments{}
+	}
+	switch strings.ToLower(name) {
+	case "header":
+		return &a.Header
+	case "masterfiles":
+		return mf
+	case "customer":
+		return mf.Customer
+	case "supplier":
+		return mf.Supplier
+	case "product":
+		return mf.Product
+	case "taxtable":
+		return nilIfEmpty(mf.TaxTable)
+	case "generalledgeraccounts":
+		return nilIfEmpty(mf.GeneralLedgerAccounts)
+	case "generalledgerentries":
+		return nilIfEmpty(a.GeneralLedgerEntries)
+	case "sourcedocuments":
+		return nilIfEmpty(a.SourceDocuments)
+	case "salesinvoices":
+		return nilIfEmpty(sd.SalesInvoices)
+	case "movementofgoods":
+		return nilIfEmpty(sd.MovementOfGoods)
+	case "workingdocuments":
+		return nilIfEmpty(sd.WorkingDocuments)
+	case "payments":
+		return nilIfEmpty(sd.Payments)
+	}
+	return nil
+}
+
+// nilIfEmpty turns a typed nil pointer into an untyped nil.
+func nilIfEmpty[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return p
+}
+
+func findDocument(a *saft.AuditFile, number string) any {
+	sd := a.SourceDocuments
+	if sd == nil {
+		return nil
+	}
+	if sd.SalesInvoices != nil {
+		for i := range sd.SalesInvoices.Invoice {
+			if sd.SalesInvoices.Invoice[i].InvoiceNo == number {
+				return &sd.SalesInvoices.Invoice[i]
+			}
+		}
+	}
+	if sd.MovementOfGoods != nil {
+		for i := range sd.MovementOfGoods.StockMovement {
+			if sd.MovementOfGoods.StockMovement[i].DocumentNumber == number {
+				return &sd.MovementOfGoods.StockMovement[i]
+			}
+		}
+	}
+	if sd.WorkingDocuments != nil {
+		for i := range sd.WorkingDocuments.WorkDocument {
+			if sd.WorkingDocuments.WorkDocument[i].DocumentNumber == number {
+				return &sd.WorkingDocuments.WorkDocument[i]
+			}
+		}
+	}
+	if sd.Payments != nil {
+		for i := range sd.Payments.Payment {
+			if sd.Payments.Payment[i].PaymentRefNo == number {
+				return &sd.Payments.Payment[i]
+			}
+		}
+	}
+	return nil
+}
+
+func diffFiles(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "atsaft diff: expected OLD and NEW file arguments")
+		fs.Usage()
+		return exitError
+	}
+
+	var files [2]*saft.AuditFile
+	for i := range files {
+		a, err := load(fs.Arg(i))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "atsaft diff: %v\n", err)
+			return exitError
+		}
+		files[i] = a
+	}
+
+	r := diff.Compare(files[0], files[1])
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	} else {
+		err = r.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft diff: %v\n", err)
+		return exitError
+	}
+	if !r.Empty() {
+		return exitFindings
+	}
+	return exitOK
+}
+
+// exportTables streams FILE into tables without loading it whole, so it
+// does not use parse.
+func exportTables(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "output `format`: csv (one file per table) or ndjson")
+	out := fs.String("o", "", "output `directory` for csv (default: current) or file for ndjson (default: standard output)")
+	comma := fs.String("comma", ",", "csv field `delimiter`")
+	bom := fs.Bool("bom", false, "start csv files with a UTF-8 byte order mark")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "atsaft export: expected one FILE argument")
+		fs.Usage()
+		return exitError
+	}
+
+	in := os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "atsaft export: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var w export.Writer
+	closeOut := func() error { return nil }
+	switch *format {
+	case "csv":
+		dir := *out
+		if dir == "" {
+			dir = "."
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "atsaft export: %v\n", err)
+			return exitError
+		}
+		runes := []rune(*comma)
+		if len(runes) != 1 {
+			fmt.Fprintf(os.Stderr, "atsaft export: invalid delimiter %q\n", *comma)
+			return exitError
+		}
+		c := export.NewCSVWriter(dir)
+		c.Comma, c.BOM = runes[0], *bom
+		w = c
+	case "ndjson":
+		path := *out
+		if path == "" {
+			path = "-"
+		}
+		o, closeFile, err := output(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "atsaft export: %v\n", err)
+			return exitError
+		}
+		w, closeOut = export.NewNDJSONWriter(o), closeFile
+	default:
+		fmt.Fprintf(os.Stderr, "atsaft export: unknown format %q\n", *format)
+		return exitError
+	}
+
+	err := export.Stream(in, w)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft export: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func anonymizeFile(args []string) int {
+	fs := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	keyFile := fs.String("key", "", "PEM `file` with a test private key to sign the documents again")
+	keyVersion := fs.String("key-version", "1", "HashControl of the signed documents")
+	out := fs.String("o", "-", "output `file`")
+	a, ok := parse(fs, args)
+	if !ok {
+		return exitError
+	}
+
+	opts := anonymize.Options{KeyVersion: *keyVersion}
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err == nil {
+			opts.Key, err = sign.LoadPrivateKey(string(data))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "atsaft anonymize: %v\n", err)
+			return exitError
+		}
+	}
+	if err := anonymize.Anonymize(a, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft anonymize: %v\n", err)
+		return exitError
+	}
+
+	w, closeOut, err := output(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft anonymize: %v\n", err)
+		return exitError
+	}
+	err = a.WriteXML(w, saft.XMLOptions{Indent: "    "})
+	if cerr := closeOut(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atsaft anonymize: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
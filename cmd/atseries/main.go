@@ -0,0 +1,671 @@
+// Command atseries registers and manages document series with the AT
+// (comunicação de séries, SeriesWS).
+//
+// Usage:
+//
+//	atseries register -serie 2025A -tipo-doc FT [-inicio 1] [-data 2025-01-01]
+//	atseries register -csv series.csv
+//	atseries list [-serie S] [-tipo-doc FT] [-estado A]
+//	atseries finalize -serie S -tipo-doc FT -codigo CODE -ultimo N
+//	atseries cancel -serie S -tipo-doc FT -codigo CODE -sem-documentos [-motivo ER]
+//
+// Every command accepts -config FILE (YAML or JSON, see package config);
+// without it the AT_* environment variables are used. -json prints JSON
+// instead of a table.
+//
+// AT only cancels series in which no documents were issued; cancel requires
+// -sem-documentos to declare it.
+//
+// The CSV file of register -csv has a header row with the columns serie,
+// tipoDoc and, optionally, tipoSerie, classeDoc, numInicialSeq,
+// dataInicioPrevUtiliz, numCertSWFatur and meioProcessamento.
+//
+// The exit code is 0 on success, 1 when AT rejects an operation and 2 on
+// usage, configuration or connection errors.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/config"
+	"github.com/hestiatechnology/autoridadetributaria/seriesws"
+	"github.com/hooklift/gowsdl/soap"
+)
+
+const (
+	exitOK       = 0
+	exitRejected = 1
+	exitError    = 2
+)
+
+var commands = map[string]func(args []string) int{
+	"register": register,
+	"list":     list,
+	"finalize": finalize,
+	"cancel":   cancel,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "atseries: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(exitError)
+	}
+	os.Exit(cmd(os.Args[2:]))
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: atseries <command> [flags]
+
+commands:
+  register   register a series, or every series of a CSV file with -csv
+  list       list the registered series
+  finalize   finalize a series after its last document
+  cancel     cancel a series registered by mistake
+`)
+}
+
+// command holds the flags shared by every subcommand.
+type command struct {
+	fs         *flag.FlagSet
+	configFile *string
+	json       *bool
+}
+
+func newCommand(name string) *command {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	return &command{
+		fs:         fs,
+		configFile: fs.String("config", "", "configuration `file` (YAML or JSON); default: AT_* environment variables"),
+		json:       fs.Bool("json", false, "print JSON instead of a table"),
+	}
+}
+
+// parse parses the flags. Commands check them before calling [command.service],
+// so that a mistake is reported without loading the certificate.
+func (c *command) parse(args []string) bool {
+	if err := c.fs.Parse(args); err != nil {
+		return false
+	}
+	if c.fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "atseries %s: unexpected arguments: %s\n", c.fs.Name(), strings.Join(c.fs.Args(), " "))
+		return false
+	}
+	return true
+}
+
+// isSet reports whether the flag name was given on the command line.
+func (c *command) isSet(name string) bool {
+	set := false
+	c.fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// service builds the SeriesWS client.
+func (c *command) service() (seriesws.SeriesWS, bool) {
+	svc, err := connect(*c.configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "atseries %s: %v\n", c.fs.Name(), err)
+		return nil, false
+	}
+	return svc, true
+}
+
+// connect builds the SeriesWS client from the configuration file, or from
+// the AT_* environment variables when configFile is empty. Tests replace it.
+var connect = func(configFile string) (seriesws.SeriesWS, error) {
+	var cfg *config.Config
+	var err error
+	if configFile != "" {
+		cfg, err = config.Load(configFile)
+	} else {
+		cfg, err = config.FromEnv()
+	}
+	if err != nil {
+		return nil, err
+	}
+	clients, err := cfg.NewClients()
+	if err != nil {
+		return nil, err
+	}
+	return clients.SeriesWS, nil
+}
+
+// stdout is where the results are printed. Tests replace it.
+var stdout io.Writer = os.Stdout
+
+func (c *command) fail(err error) int {
+	fmt.Fprintf(os.Stderr, "atseries %s: %v\n", c.fs.Name(), err)
+	return exitError
+}
+
+// series is a row of register -csv.
+type series struct {
+	serie             string
+	tipoSerie         string
+	classeDoc         string
+	tipoDoc           string
+	numInicialSeq     int32
+	dataInicio        time.Time
+	numCertSWFatur    int32
+	meioProcessamento string
+}
+
+func (s series) request() *seriesws.RegistarSerie {
+	serie := seriesws.SerieType(s.serie)
+	tipoSerie := seriesws.TipoSerieType(s.tipoSerie)
+	classeDoc := seriesws.ClasseDocType(s.classeDoc)
+	tipoDoc := seriesws.TipoDocType(s.tipoDoc)
+	numInicialSeq := seriesws.NumSeqType(s.numInicialSeq)
+	numCert := seriesws.NumCertSWFaturType(s.numCertSWFatur)
+	meio := seriesws.MeioProcessamentoType(s.meioProcessamento)
+	return &seriesws.RegistarSerie{
+		Serie:                &serie,
+		TipoSerie:            &tipoSerie,
+		ClasseDoc:            &classeDoc,
+		TipoDoc:              &tipoDoc,
+		NumInicialSeq:        &numInicialSeq,
+		DataInicioPrevUtiliz: soap.CreateXsdDate(s.dataInicio, false),
+		NumCertSWFatur:       &numCert,
+		MeioProcessamento:    &meio,
+	}
+}
+
+// defaults fills the optional fields: a normal series (N) starting at 1
+// today, processed by a computer program (PI), with the document class
+// derived from the document type.
+func (s *series) defaults() error {
+	if s.serie == "" || s.tipoDoc == "" {
+		return errors.New("serie and tipoDoc are required")
+	}
+	if s.tipoSerie == "" {
+		s.tipoSerie = "N"
+	}
+	if s.classeDoc == "" {
+		c, ok := documentClasses[s.tipoDoc]
+		if !ok {
+			return fmt.Errorf("unknown document class for tipoDoc %s; set classeDoc", s.tipoDoc)
+		}
+		s.classeDoc = c
+	}
+	if s.numInicialSeq == 0 {
+		s.numInicialSeq = 1
+	}
+	if s.dataInicio.IsZero() {
+		s.dataInicio = time.Now()
+	}
+	if s.meioProcessamento == "" {
+		s.meioProcessamento = "PI"
+	}
+	return nil
+}
+
+// documentClasses maps document types to their class: SI (faturação), MG
+// (movimentação de mercadorias), WD (documentos de conferência) and PY
+// (recibos).
+var documentClasses = map[string]string{
+	"FT": "SI", "FS": "SI", "FR": "SI", "NC": "SI", "ND": "SI",
+	"GR": "MG", "GT": "MG", "GA": "MG", "GC": "MG", "GD": "MG",
+	"CM": "WD", "CC": "WD", "FC": "WD", "FO": "WD", "NE": "WD", "OU": "WD",
+	"OR": "WD", "PF": "WD", "RP": "WD", "RE": "WD", "CS": "WD", "LD": "WD",
+	"RA": "WD",
+	"RC": "PY", "RG": "PY",
+}
+
+func register(args []string) int {
+	c := newCommand("register")
+	var s series
+	c.fs.StringVar(&s.serie, "serie", "", "series `identifier`")
+	c.fs.StringVar(&s.tipoSerie, "tipo-serie", "", "series type: N (normal), F (formação) or R (recuperação); default N")
+	c.fs.StringVar(&s.classeDoc, "classe", "", "document class: SI, MG, WD or PY; default derived from -tipo-doc")
+	c.fs.StringVar(&s.tipoDoc, "tipo-doc", "", "document `type`, e.g. FT")
+	inicio := c.fs.Int("inicio", 1, "first sequence `number`")
+	data := c.fs.String("data", "", "expected first use `date` (YYYY-MM-DD); default today")
+	cert := c.fs.Int("cert", 0, "software certificate `number`; 0 if not applicable")
+	c.fs.StringVar(&s.meioProcessamento, "meio", "", "processing: PI (programa informático) or PF (portal); default PI")
+	csvFile := c.fs.String("csv", "", "register every series of a CSV `file`")
+	if !c.parse(args) {
+		return exitError
+	}
+
+	var rows []series
+	if *csvFile != "" {
+		var err error
+		rows, err = readSeriesCSV(*csvFile)
+		if err != nil {
+			return c.fail(err)
+		}
+	} else {
+		s.numInicialSeq = int32(*inicio)
+		s.numCertSWFatur = int32(*cert)
+		if *data != "" {
+			d, err := time.Parse(time.DateOnly, *data)
+			if err != nil {
+				return c.fail(err)
+			}
+			s.dataInicio = d
+		}
+		if err := s.defaults(); err != nil {
+			return c.fail(err)
+		}
+		rows = []series{s}
+	}
+
+	svc, ok := c.service()
+	if !ok {
+		return exitError
+	}
+
+	var results []result
+	code := exitOK
+	for _, s := range rows {
+		resp, err := svc.RegistarSerieContext(context.Background(), s.request())
+		r := result{Serie: s.serie, TipoDoc: s.tipoDoc}
+		if err != nil {
+			r.Message = err.Error()
+			code = exitError
+		} else if resp.RegistarSerieResp != nil {
+			r.fill(resp.RegistarSerieResp)
+		}
+		if r.Series == nil && code == exitOK {
+			code = exitRejected
+		}
+		results = append(results, r)
+	}
+	if err := printResults(stdout, *c.json, results); err != nil {
+		return c.fail(err)
+	}
+	return code
+}
+
+// readSeriesCSV reads the series of register -csv.
+func readSeriesCSV(path string) ([]series, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s: no series", path)
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	get := func(record []string, name string) string {
+		if i, ok := columns[strings.ToLower(name)]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []series
+	for n, record := range records[1:] {
+		line := n + 2
+		s := series{
+			serie:             get(record, "serie"),
+			tipoSerie:         get(record, "tipoSerie"),
+			classeDoc:         get(record, "classeDoc"),
+			tipoDoc:           get(record, "tipoDoc"),
+			meioProcessamento: get(record, "meioProcessamento"),
+		}
+		if v := get(record, "numInicialSeq"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: numInicialSeq: %w", path, line, err)
+			}
+			s.numInicialSeq = int32(n)
+		}
+		if v := get(record, "numCertSWFatur"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: numCertSWFatur: %w", path, line, err)
+			}
+			s.numCertSWFatur = int32(n)
+		}
+		if v := get(record, "dataInicioPrevUtiliz"); v != "" {
+			d, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: dataInicioPrevUtiliz: %w", path, line, err)
+			}
+			s.dataInicio = d
+		}
+		if err := s.defaults(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rows = append(rows, s)
+	}
+	return rows, nil
+}
+
+func list(args []string) int {
+	c := newCommand("list")
+	serie := c.fs.String("serie", "", "series `identifier`")
+	tipoSerie := c.fs.String("tipo-serie", "", "series `type`")
+	classe := c.fs.String("classe", "", "document `class`")
+	tipoDoc := c.fs.String("tipo-doc", "", "document `type`")
+	codigo := c.fs.String("codigo", "", "series validation `code`")
+	estado := c.fs.String("estado", "", "`state`: A (ativa), F (finalizada) or N (anulada)")
+	de := c.fs.String("de", "", "registered from `date` (YYYY-MM-DD)")
+	ate := c.fs.String("ate", "", "registered until `date` (YYYY-MM-DD)")
+	if !c.parse(args) {
+		return exitError
+	}
+
+	req := &seriesws.ConsultarSeries{}
+	if *serie != "" {
+		v := seriesws.SerieType(*serie)
+		req.Serie = &v
+	}
+	if *tipoSerie != "" {
+		v := seriesws.TipoSerieType(*tipoSerie)
+		req.TipoSerie = &v
+	}
+	if *classe != "" {
+		v := seriesws.ClasseDocType(*classe)
+		req.ClasseDoc = &v
+	}
+	if *tipoDoc != "" {
+		v := seriesws.TipoDocType(*tipoDoc)
+		req.TipoDoc = &v
+	}
+	if *codigo != "" {
+		v := seriesws.CodValidacaoSerieType(*codigo)
+		req.CodValidacaoSerie = &v
+	}
+	if *estado != "" {
+		v := seriesws.EstadoType(*estado)
+		req.Estado = &v
+	}
+	for _, d := range []struct {
+		value string
+		dst   *soap.XSDDate
+	}{{*de, &req.DataRegistoDe}, {*ate, &req.DataRegistoAte}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, d.value)
+		if err != nil {
+			return c.fail(err)
+		}
+		*d.dst = soap.CreateXsdDate(t, false)
+	}
+
+	svc, ok := c.service()
+	if !ok {
+		return exitError
+	}
+	resp, err := svc.ConsultarSeriesContext(context.Background(), req)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	var results []result
+	if r := resp.ConsultarSeriesResp; r != nil {
+		for _, info := range r.InfoSerie {
+			results = append(results, newResult(info, r.InfoResultOper))
+		}
+		if len(r.InfoSerie) == 0 && r.InfoResultOper != nil {
+			results = append(results, newResult(nil, r.InfoResultOper))
+		}
+	}
+	if err := printResults(stdout, *c.json, results); err != nil {
+		return c.fail(err)
+	}
+	return exitOK
+}
+
+func finalize(args []string) int {
+	c := newCommand("finalize")
+	serie := c.fs.String("serie", "", "series `identifier`")
+	classe := c.fs.String("classe", "", "document class; default derived from -tipo-doc")
+	tipoDoc := c.fs.String("tipo-doc", "", "document `type`")
+	codigo := c.fs.String("codigo", "", "series validation `code`")
+	ultimo := c.fs.Int("ultimo", 0, "sequence `number` of the last document issued (required)")
+	justificacao := c.fs.String("justificacao", "", "optional `notes`")
+	if !c.parse(args) {
+		return exitError
+	}
+
+	s, err := identify(*serie, *classe, *tipoDoc, *codigo)
+	if err != nil {
+		return c.fail(err)
+	}
+	if !c.isSet("ultimo") {
+		return c.fail(errors.New("-ultimo is required: use 0 if no documents were issued"))
+	}
+	seq := seriesws.NumSeqType(*ultimo)
+	req := &seriesws.FinalizarSerie{
+		Serie:               &s.serie,
+		ClasseDoc:           &s.classeDoc,
+		TipoDoc:             &s.tipoDoc,
+		CodValidacaoSerie:   &s.codigo,
+		SeqUltimoDocEmitido: &seq,
+	}
+	if *justificacao != "" {
+		j := seriesws.JustificacaoType(*justificacao)
+		req.Justificacao = &j
+	}
+
+	svc, ok := c.service()
+	if !ok {
+		return exitError
+	}
+	resp, err := svc.FinalizarSerieContext(context.Background(), req)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.report(*serie, *tipoDoc, resp.FinalizarSerieResp)
+}
+
+func cancel(args []string) int {
+	c := newCommand("cancel")
+	serie := c.fs.String("serie", "", "series `identifier`")
+	classe := c.fs.String("classe", "", "document class; default derived from -tipo-doc")
+	tipoDoc := c.fs.String("tipo-doc", "", "document `type`")
+	codigo := c.fs.String("codigo", "", "series validation `code`")
+	motivo := c.fs.String("motivo", "ER", "`reason` code; ER (erro)")
+	declaracao := c.fs.Bool("sem-documentos", false, "declare that no documents were issued in the series (required)")
+	if !c.parse(args) {
+		return exitError
+	}
+	if !*declaracao {
+		return c.fail(errors.New("-sem-documentos is required: AT only cancels series in which no documents were issued"))
+	}
+
+	s, err := identify(*serie, *classe, *tipoDoc, *codigo)
+	if err != nil {
+		return c.fail(err)
+	}
+	m := seriesws.MotivoType(*motivo)
+	req := &seriesws.AnularSerie{
+		Serie:                &s.serie,
+		ClasseDoc:            &s.classeDoc,
+		TipoDoc:              &s.tipoDoc,
+		CodValidacaoSerie:    &s.codigo,
+		Motivo:               &m,
+		DeclaracaoNaoEmissao: *declaracao,
+	}
+
+	svc, ok := c.service()
+	if !ok {
+		return exitError
+	}
+	resp, err := svc.AnularSerieContext(context.Background(), req)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.report(*serie, *tipoDoc, resp.AnularSerieResp)
+}
+
+type identified struct {
+	serie     seriesws.SerieType
+	classeDoc seriesws.ClasseDocType
+	tipoDoc   seriesws.TipoDocType
+	codigo    seriesws.CodValidacaoSerieType
+}
+
+// identify checks the flags that identify a registered series.
+func identify(serie, classe, tipoDoc, codigo string) (identified, error) {
+	if serie == "" || tipoDoc == "" || codigo == "" {
+		return identified{}, errors.New("-serie, -tipo-doc and -codigo are required")
+	}
+	if classe == "" {
+		c, ok := documentClasses[tipoDoc]
+		if !ok {
+			return identified{}, fmt.Errorf("unknown document class for %s; use -classe", tipoDoc)
+		}
+		classe = c
+	}
+	return identified{
+		serie:     seriesws.SerieType(serie),
+		classeDoc: seriesws.ClasseDocType(classe),
+		tipoDoc:   seriesws.TipoDocType(tipoDoc),
+		codigo:    seriesws.CodValidacaoSerieType(codigo),
+	}, nil
+}
+
+// report prints the response of finalize and cancel.
+func (c *command) report(serie, tipoDoc string, resp *seriesws.SeriesResp) int {
+	r := result{Serie: serie, TipoDoc: tipoDoc}
+	if resp != nil {
+		r.fill(resp)
+	}
+	if err := printResults(stdout, *c.json, []result{r}); err != nil {
+		return c.fail(err)
+	}
+	if r.Series == nil {
+		return exitRejected
+	}
+	return exitOK
+}
+
+// result is a series and the AT result of the operation on it, flattened for
+// printing.
+type result struct {
+	Serie   string      `json:"serie"`
+	TipoDoc string      `json:"tipoDoc"`
+	Code    int32       `json:"codResultOper,omitempty"`
+	Message string      `json:"msgResultOper,omitempty"`
+	Series  *seriesInfo `json:"infoSerie,omitempty"`
+}
+
+type seriesInfo struct {
+	TipoSerie            string `json:"tipoSerie,omitempty"`
+	ClasseDoc            string `json:"classeDoc,omitempty"`
+	NumInicialSeq        int32  `json:"numInicialSeq,omitempty"`
+	NumFinalSeq          int32  `json:"numFinalSeq,omitempty"`
+	DataInicioPrevUtiliz string `json:"dataInicioPrevUtiliz,omitempty"`
+	SeqUltimoDocEmitido  int32  `json:"seqUltimoDocEmitido,omitempty"`
+	MeioProcessamento    string `json:"meioProcessamento,omitempty"`
+	NumCertSWFatur       int32  `json:"numCertSWFatur"`
+	CodValidacaoSerie    string `json:"codValidacaoSerie,omitempty"`
+	DataRegisto          string `json:"dataRegisto,omitempty"`
+	Estado               string `json:"estado,omitempty"`
+	MotivoEstado         string `json:"motivoEstado,omitempty"`
+	Justificacao         string `json:"justificacao,omitempty"`
+	DataEstado           string `json:"dataEstado,omitempty"`
+	NifComunicou         string `json:"nifComunicou,omitempty"`
+}
+
+func newResult(info *seriesws.SeriesInfo, op *seriesws.OperationResultInfo) result {
+	var r result
+	r.fill(&seriesws.SeriesResp{InfoSerie: info, InfoResultOper: op})
+	return r
+}
+
+func (r *result) fill(resp *seriesws.SeriesResp) {
+	if op := resp.InfoResultOper; op != nil {
+		r.Code = int32(value(op.CodResultOper))
+		r.Message = string(value(op.MsgResultOper))
+	}
+	info := resp.InfoSerie
+	if info == nil {
+		return
+	}
+	r.Serie = string(value(info.Serie))
+	r.TipoDoc = string(value(info.TipoDoc))
+	r.Series = &seriesInfo{
+		TipoSerie:            string(value(info.TipoSerie)),
+		ClasseDoc:            string(value(info.ClasseDoc)),
+		NumInicialSeq:        int32(value(info.NumInicialSeq)),
+		NumFinalSeq:          int32(value(info.NumFinalSeq)),
+		DataInicioPrevUtiliz: formatDate(info.DataInicioPrevUtiliz.ToGoTime(), time.DateOnly),
+		SeqUltimoDocEmitido:  int32(value(info.SeqUltimoDocEmitido)),
+		MeioProcessamento:    string(value(info.MeioProcessamento)),
+		NumCertSWFatur:       int32(value(info.NumCertSWFatur)),
+		CodValidacaoSerie:    string(value(info.CodValidacaoSerie)),
+		DataRegisto:          formatDate(info.DataRegisto.ToGoTime(), time.DateOnly),
+		Estado:               string(value(info.Estado)),
+		MotivoEstado:         string(value(info.MotivoEstado)),
+		Justificacao:         string(value(info.Justificacao)),
+		DataEstado:           formatDate(info.DataEstado.ToGoTime(), time.DateTime),
+		NifComunicou:         string(value(info.NifComunicou)),
+	}
+}
+
+func value[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// formatDate formats t, or returns "" for the zero date of an absent
+// element.
+func formatDate(t time.Time, layout string) string {
+	if t.Year() <= 1 {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+func printResults(w io.Writer, asJSON bool, results []result) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERIE\tTIPO\tCLASSE\tCODIGO\tESTADO\tINICIO\tULTIMO\tREGISTO\tRESULTADO")
+	for _, r := range results {
+		var s seriesInfo
+		if r.Series != nil {
+			s = *r.Series
+		}
+		msg := r.Message
+		if r.Code != 0 {
+			msg = fmt.Sprintf("%d %s", r.Code, r.Message)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", r.Serie, r.TipoDoc, s.ClasseDoc, s.CodValidacaoSerie, s.Estado, s.NumInicialSeq, s.SeqUltimoDocEmitido, s.DataRegisto, msg)
+	}
+	return tw.Flush()
+}
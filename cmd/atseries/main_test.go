@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/seriesws"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "series.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSeriesCSV(t *testing.T) {
+	path := writeCSV(t, "TipoDoc, serie, numInicialSeq, dataInicioPrevUtiliz, classeDoc, numCertSWFatur\n"+
+		"FT, 2025A, , , , \n"+
+		"GT, 2025G, 10, 2025-03-01, MG, 1234\n")
+	rows, err := readSeriesCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("readSeriesCSV() = %d series, want 2", len(rows))
+	}
+
+	ft := rows[0]
+	if ft.serie != "2025A" || ft.tipoDoc != "FT" || ft.classeDoc != "SI" || ft.tipoSerie != "N" ||
+		ft.numInicialSeq != 1 || ft.meioProcessamento != "PI" || ft.numCertSWFatur != 0 {
+		t.Errorf("row 1 = %+v, want the defaults", ft)
+	}
+	if y, m, d := ft.dataInicio.Date(); y != time.Now().Year() || m != time.Now().Month() || d != time.Now().Day() {
+		t.Errorf("row 1 dataInicio = %v, want today", ft.dataInicio)
+	}
+
+	gt := rows[1]
+	want := series{serie: "2025G", tipoSerie: "N", classeDoc: "MG", tipoDoc: "GT", numInicialSeq: 10,
+		dataInicio: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), numCertSWFatur: 1234, meioProcessamento: "PI"}
+	if gt != want {
+		t.Errorf("row 2 = %+v, want %+v", gt, want)
+	}
+}
+
+func TestReadSeriesCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no series", "serie,tipoDoc\n", "no series"},
+		{"missing tipoDoc", "serie,tipoDoc\nA,FT\nB,\n", ":3: serie and tipoDoc are required"},
+		{"unknown class", "serie,tipoDoc\nA,XX\n", ":2: unknown document class"},
+		{"bad number", "serie,tipoDoc,numInicialSeq\nA,FT,um\n", ":2: numInicialSeq"},
+		{"bad date", "serie,tipoDoc,dataInicioPrevUtiliz\nA,FT,01/03/2025\n", ":2: dataInicioPrevUtiliz"},
+	}
+	for _, tt := range tests {
+		_, err := readSeriesCSV(writeCSV(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: readSeriesCSV() = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// fakeSeriesWS registers every series except "REJ", which AT rejects, and
+// records the finalizations and cancellations.
+type fakeSeriesWS struct {
+	seriesws.SeriesWS
+	err       error
+	finalized []*seriesws.FinalizarSerie
+	cancelled []*seriesws.AnularSerie
+}
+
+func (f *fakeSeriesWS) resp(serie seriesws.SerieType) *seriesws.SeriesResp {
+	if serie == "REJ" {
+		code := seriesws.CodResultOperType(4001)
+		msg := seriesws.MsgResultOperType("Série já existe")
+		return &seriesws.SeriesResp{InfoResultOper: &seriesws.OperationResultInfo{CodResultOper: &code, MsgResultOper: &msg}}
+	}
+	return &seriesws.SeriesResp{InfoSerie: &seriesws.SeriesInfo{Serie: &serie}}
+}
+
+func (f *fakeSeriesWS) RegistarSerieContext(ctx context.Context, request *seriesws.RegistarSerie) (*seriesws.RegistarSerieResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &seriesws.RegistarSerieResponse{RegistarSerieResp: f.resp(*request.Serie)}, nil
+}
+
+func (f *fakeSeriesWS) FinalizarSerieContext(ctx context.Context, request *seriesws.FinalizarSerie) (*seriesws.FinalizarSerieResponse, error) {
+	f.finalized = append(f.finalized, request)
+	return &seriesws.FinalizarSerieResponse{FinalizarSerieResp: f.resp(*request.Serie)}, nil
+}
+
+func (f *fakeSeriesWS) AnularSerieContext(ctx context.Context, request *seriesws.AnularSerie) (*seriesws.AnularSerieResponse, error) {
+	f.cancelled = append(f.cancelled, request)
+	return &seriesws.AnularSerieResponse{AnularSerieResp: f.resp(*request.Serie)}, nil
+}
+
+func TestExitCodes(t *testing.T) {
+	csvFile := writeCSV(t, "serie,tipoDoc\nA,FT\nREJ,FT\n")
+	tests := []struct {
+		name       string
+		cmd        func([]string) int
+		args       []string
+		connectErr error
+		callErr    error
+		want       int
+	}{
+		{"register", register, []string{"-serie", "A", "-tipo-doc", "FT"}, nil, nil, exitOK},
+		{"register rejected", register, []string{"-serie", "REJ", "-tipo-doc", "FT"}, nil, nil, exitRejected},
+		{"register CSV with a rejected series", register, []string{"-csv", csvFile}, nil, nil, exitRejected},
+		{"register without -tipo-doc", register, []string{"-serie", "A"}, nil, nil, exitError},
+		{"register connection error", register, []string{"-serie", "A", "-tipo-doc", "FT"}, nil, errors.New("connection refused"), exitError},
+		{"unknown flag", register, []string{"-serie", "A", "-tipo-doc", "FT", "-x"}, nil, nil, exitError},
+		{"unexpected argument", register, []string{"-serie", "A", "-tipo-doc", "FT", "B"}, nil, nil, exitError},
+		{"configuration error", register, []string{"-serie", "A", "-tipo-doc", "FT"}, errors.New("no certificate"), nil, exitError},
+		{"cancel", cancel, []string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C", "-sem-documentos"}, nil, nil, exitOK},
+		{"cancel rejected", cancel, []string{"-serie", "REJ", "-tipo-doc", "FT", "-codigo", "C", "-sem-documentos"}, nil, nil, exitRejected},
+		{"cancel without -codigo", cancel, []string{"-serie", "A", "-tipo-doc", "FT", "-sem-documentos"}, nil, nil, exitError},
+		{"finalize", finalize, []string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C", "-ultimo", "0"}, nil, nil, exitOK},
+		{"finalize without -ultimo", finalize, []string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C"}, nil, nil, exitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSeriesWS{err: tt.callErr}
+			stubService(t, fake, tt.connectErr)
+			if got := tt.cmd(tt.args); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelRequiresDeclaration(t *testing.T) {
+	fake := &fakeSeriesWS{}
+	stubService(t, fake, nil)
+	if got := cancel([]string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C"}); got != exitError {
+		t.Errorf("cancel without -sem-documentos = %d, want %d", got, exitError)
+	}
+	if len(fake.cancelled) != 0 {
+		t.Fatal("cancel without -sem-documentos called AT")
+	}
+
+	if got := cancel([]string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C", "-sem-documentos"}); got != exitOK {
+		t.Errorf("cancel = %d, want %d", got, exitOK)
+	}
+	if len(fake.cancelled) != 1 || !fake.cancelled[0].DeclaracaoNaoEmissao || *fake.cancelled[0].Motivo != "ER" {
+		t.Errorf("cancel sent %+v, want the declaration and reason ER", fake.cancelled)
+	}
+}
+
+func TestFinalizeRequiresLast(t *testing.T) {
+	fake := &fakeSeriesWS{}
+	stubService(t, fake, nil)
+	if got := finalize([]string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C"}); got != exitError {
+		t.Errorf("finalize without -ultimo = %d, want %d", got, exitError)
+	}
+	if len(fake.finalized) != 0 {
+		t.Fatal("finalize without -ultimo called AT")
+	}
+
+	if got := finalize([]string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C", "-ultimo", "0"}); got != exitOK {
+		t.Errorf("finalize -ultimo 0 = %d, want %d", got, exitOK)
+	}
+	if len(fake.finalized) != 1 || *fake.finalized[0].SeqUltimoDocEmitido != 0 {
+		t.Errorf("finalize sent %+v, want sequence 0", fake.finalized)
+	}
+}
+
+// TestFlagsCheckedBeforeConnecting checks that flag mistakes are reported
+// without loading the configuration.
+func TestFlagsCheckedBeforeConnecting(t *testing.T) {
+	stubService(t, &fakeSeriesWS{}, nil)
+	connected := false
+	connect = func(string) (seriesws.SeriesWS, error) {
+		connected = true
+		return nil, errors.New("no certificate")
+	}
+	tests := []struct {
+		name string
+		cmd  func([]string) int
+		args []string
+	}{
+		{"register", register, []string{"-serie", "A"}},
+		{"list", list, []string{"-de", "01/03/2025"}},
+		{"finalize", finalize, []string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C"}},
+		{"cancel", cancel, []string{"-serie", "A", "-tipo-doc", "FT", "-codigo", "C"}},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd(tt.args); got != exitError {
+			t.Errorf("%s = %d, want %d", tt.name, got, exitError)
+		}
+	}
+	if connected {
+		t.Error("invalid flags loaded the configuration")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestPrintResultsError(t *testing.T) {
+	results := []result{{Serie: "A", TipoDoc: "FT"}}
+	for _, asJSON := range []bool{false, true} {
+		if err := printResults(failingWriter{}, asJSON, results); err == nil {
+			t.Errorf("printResults(json=%v) ignored the write error", asJSON)
+		}
+	}
+
+	fake := &fakeSeriesWS{}
+	stubService(t, fake, nil)
+	stdout = failingWriter{}
+	if got := register([]string{"-serie", "A", "-tipo-doc", "FT", "-json"}); got != exitError {
+		t.Errorf("register with a failing output = %d, want %d", got, exitError)
+	}
+}
+
+// stubService makes the commands use svc, or fail to connect with err, and
+// discards their output.
+func stubService(t *testing.T, svc seriesws.SeriesWS, err error) {
+	t.Helper()
+	oldConnect, oldStdout := connect, stdout
+	t.Cleanup(func() { connect, stdout = oldConnect, oldStdout })
+	connect = func(string) (seriesws.SeriesWS, error) {
+		if err != nil {
+			return nil, err
+		}
+		return svc, nil
+	}
+	stdout = io.Discard
+}
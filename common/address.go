@@ -0,0 +1,220 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Maximum lengths, in characters, of the SAF-T (PT) address fields, as in
+// saftpt1.04_01.xsd.
+const (
+	MaxBuildingNumber = 10
+	MaxStreetName     = 200
+	MaxAddressDetail  = 210
+	MaxCity           = 50
+	MaxPostalCode     = 20
+	MaxRegion         = 50
+)
+
+// Unknown is the value the Portaria n.º 302/2016 allows in the address
+// fields of the final consumer and of unknown shipping points.
+const Unknown = "Desconhecido"
+
+var (
+	ErrInvalidPostalCode = errors.New("invalid postal code")
+	ErrInvalidAddress    = errors.New("invalid address")
+	ErrTaxRegionMismatch = errors.New("tax region does not match postal code")
+)
+
+// IsUnknown reports whether s is [Unknown], ignoring case and spacing.
+func IsUnknown(s string) bool {
+	return strings.EqualFold(NormalizeSpace(s), Unknown)
+}
+
+// NormalizeSpace trims s and collapses runs of whitespace into one space.
+func NormalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// NormalizePostalCodePT returns a Portuguese postal code as NNNN-NNN.
+// Spaces, a missing dash and a trailing locality ("1000-001 Lisboa") are
+// accepted.
+func NormalizePostalCodePT(s string) (string, error) {
+	s = NormalizeSpace(s)
+	digits := make([]byte, 0, 7)
+	for i := 0; i < len(s) && len(digits) < 7; i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '-' || c == ' ':
+			if len(digits) != 4 {
+				return "", fmt.Errorf("%w: %s", ErrInvalidPostalCode, s)
+			}
+		default:
+			return "", fmt.Errorf("%w: %s", ErrInvalidPostalCode, s)
+		}
+	}
+	if len(digits) != 7 || digits[0] == '0' {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPostalCode, s)
+	}
+	return string(digits[:4]) + "-" + string(digits[4:]), nil
+}
+
+// ValidatePostalCodePT checks that s is a Portuguese postal code in the
+// NNNN-NNN format.
+func ValidatePostalCodePT(s string) error {
+	n, err := NormalizePostalCodePT(s)
+	if err != nil {
+		return err
+	}
+	if n != s {
+		return fmt.Errorf("%w: %s, want %s", ErrInvalidPostalCode, s, n)
+	}
+	return nil
+}
+
+// PostalCodeRegion returns the SAF-T tax region of a Portuguese postal code:
+// PT-MA for 9000 to 9499, PT-AC for 9500 to 9999 and PT otherwise.
+func PostalCodeRegion(postalCode string) (string, error) {
+	n, err := NormalizePostalCodePT(postalCode)
+	if err != nil {
+		return "", err
+	}
+	prefix, _ := strconv.Atoi(n[:4])
+	switch {
+	case prefix >= 9000 && prefix < 9500:
+		return RegionMadeira, nil
+	case prefix >= 9500:
+		return RegionAzores, nil
+	}
+	return RegionMainland, nil
+}
+
+// CheckTaxRegion reports an error wrapping [ErrTaxRegionMismatch] when a
+// Portuguese tax region (PT, PT-AC or PT-MA) does not match the region of
+// postalCode.
+func CheckTaxRegion(postalCode, taxRegion string) error {
+	region, err := PostalCodeRegion(postalCode)
+	if err != nil {
+		return err
+	}
+	if region != taxRegion {
+		return fmt.Errorf("%w: %s is in %s, not %s", ErrTaxRegionMismatch, postalCode, region, taxRegion)
+	}
+	return nil
+}
+
+// Address is a SAF-T address (AddressStructure).
+type Address struct {
+	BuildingNumber string
+	StreetName     string
+	AddressDetail  string
+	City           string
+	PostalCode     string
+	Region         string
+	Country        string
+}
+
+// Normalize collapses whitespace in every field, upper-cases the country,
+// title-cases the city and region ("VILA NOVA DE GAIA" becomes "Vila Nova de
+// Gaia") and formats Portuguese postal codes as NNNN-NNN. [Unknown] values
+// are written in their canonical form.
+func (a Address) Normalize() Address {
+	a.BuildingNumber = NormalizeSpace(a.BuildingNumber)
+	a.StreetName = NormalizeSpace(a.StreetName)
+	a.AddressDetail = normalizeUnknown(a.AddressDetail)
+	a.City = titleCase(normalizeUnknown(a.City))
+	a.Region = titleCase(NormalizeSpace(a.Region))
+	a.Country = normalizeUnknown(a.Country)
+	if !IsUnknown(a.Country) {
+		a.Country = strings.ToUpper(a.Country)
+	}
+	a.PostalCode = normalizeUnknown(a.PostalCode)
+	if isPortugal(a.Country) {
+		if n, err := NormalizePostalCodePT(a.PostalCode); err == nil {
+			a.PostalCode = n
+		}
+	}
+	return a
+}
+
+// Validate checks the required fields, the SAF-T maxima and, for Portugal,
+// the postal code format. With allowUnknown, AddressDetail, City,
+// PostalCode and Country may be [Unknown], as for the final consumer.
+func (a Address) Validate(allowUnknown bool) error {
+	fields := []struct {
+		name     string
+		value    string
+		max      int
+		required bool
+	}{
+		{"BuildingNumber", a.BuildingNumber, MaxBuildingNumber, false},
+		{"StreetName", a.StreetName, MaxStreetName, false},
+		{"AddressDetail", a.AddressDetail, MaxAddressDetail, true},
+		{"City", a.City, MaxCity, true},
+		{"PostalCode", a.PostalCode, MaxPostalCode, true},
+		{"Region", a.Region, MaxRegion, false},
+		// Two-letter codes, PT-AC, PT-MA or Desconhecido
+		{"Country", a.Country, len(Unknown), true},
+	}
+	for _, f := range fields {
+		if f.required && strings.TrimSpace(f.value) == "" {
+			return fmt.Errorf("%w: missing %s", ErrInvalidAddress, f.name)
+		}
+		if n := utf8.RuneCountInString(f.value); n > f.max {
+			return fmt.Errorf("%w: %s has %d characters, max %d", ErrInvalidAddress, f.name, n, f.max)
+		}
+		if !allowUnknown && f.required && IsUnknown(f.value) {
+			return fmt.Errorf("%w: %s cannot be %s", ErrInvalidAddress, f.name, Unknown)
+		}
+	}
+
+	if isPortugal(a.Country) && !IsUnknown(a.PostalCode) {
+		if err := ValidatePostalCodePT(a.PostalCode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isPortugal(country string) bool {
+	return taxIDCountry(country) == "PT"
+}
+
+func normalizeUnknown(s string) string {
+	s = NormalizeSpace(s)
+	if IsUnknown(s) {
+		return Unknown
+	}
+	return s
+}
+
+// lowerWords are kept in lower case by titleCase unless they start the text.
+var lowerWords = map[string]bool{
+	"a": true, "as": true, "da": true, "das": true, "de": true, "do": true,
+	"dos": true, "e": true, "o": true, "os": true,
+}
+
+// titleCase capitalizes each word of s, keeping Portuguese particles such as
+// "de" and "dos" in lower case, also inside hyphenated names
+// ("MONTEMOR-O-NOVO" becomes "Montemor-o-Novo").
+func titleCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, w := range words {
+		parts := strings.Split(w, "-")
+		for j, p := range parts {
+			if p == "" || (i > 0 || j > 0) && lowerWords[p] {
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(p)
+			parts[j] = string(unicode.ToUpper(r)) + p[size:]
+		}
+		words[i] = strings.Join(parts, "-")
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPostalCodeRegion(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"1000-001", RegionMainland},
+		{"4400 123", RegionMainland},
+		{"9000-018", RegionMadeira},
+		{"9400-150", RegionMadeira},
+		{"9500-054 Ponta Delgada", RegionAzores},
+		{"9900-014", RegionAzores},
+	}
+	for _, tt := range tests {
+		got, err := PostalCodeRegion(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("PostalCodeRegion(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "1000", "1000-01", "0100-001", "1000-0012", "AB12-345"} {
+		if err := ValidatePostalCodePT(in); !errors.Is(err, ErrInvalidPostalCode) {
+			t.Errorf("ValidatePostalCodePT(%q) = %v, want ErrInvalidPostalCode", in, err)
+		}
+	}
+
+	if err := CheckTaxRegion("9000-018", RegionMainland); !errors.Is(err, ErrTaxRegionMismatch) {
+		t.Errorf("CheckTaxRegion(Funchal, PT) = %v, want ErrTaxRegionMismatch", err)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	a := Address{
+		AddressDetail: "  Rua  do Ouro, 1 ",
+		City:          "MONTEMOR-O-NOVO",
+		PostalCode:    "7050 123",
+		Country:       "pt",
+	}.Normalize()
+	want := Address{AddressDetail: "Rua do Ouro, 1", City: "Montemor-o-Novo", PostalCode: "7050-123", Country: "PT"}
+	if a != want {
+		t.Errorf("Normalize() = %+v, want %+v", a, want)
+	}
+	if err := a.Validate(false); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	if got := (Address{City: "vila nova de gaia"}).Normalize().City; got != "Vila Nova de Gaia" {
+		t.Errorf("Normalize().City = %q", got)
+	}
+
+	unknown := Address{AddressDetail: Unknown, City: "desconhecido", PostalCode: Unknown, Country: Unknown}.Normalize()
+	if err := unknown.Validate(true); err != nil {
+		t.Errorf("Validate(true) of the final consumer address = %v", err)
+	}
+	if err := unknown.Validate(false); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Validate(false) of the final consumer address = %v, want ErrInvalidAddress", err)
+	}
+
+	long := want
+	long.City = "Vila Nova de Gaia Vila Nova de Gaia Vila Nova de Gaia"
+	if err := long.Validate(false); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Validate() with a long city = %v, want ErrInvalidAddress", err)
+	}
+
+	long = want
+	long.AddressDetail = strings.Repeat("á", MaxAddressDetail)
+	if err := long.Validate(false); err != nil {
+		t.Errorf("Validate() with a %d character AddressDetail = %v", MaxAddressDetail, err)
+	}
+	long.AddressDetail += "a"
+	if err := long.Validate(false); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Validate() with a %d character AddressDetail = %v, want ErrInvalidAddress", MaxAddressDetail+1, err)
+	}
+}
@@ -73,41 +73,14 @@ var VatExemptionCodes = []VatExemptionCode{
 	{Code: "M99", Description: "Não sujeito ou não tributado", Law: "Outras situações de não liquidação do imposto (Exemplos: artigo 2.º, n.º 2 ; artigo 3.º, n.ºs 4, 6 e 7; artigo 4.º, n.º 5, todos do CIVA)"},
 }
 
+// ValidateNIFPT reports whether nif is a valid 9-digit Portuguese NIF. Use
+// [ParseNIF] to also get the entity type.
 func ValidateNIFPT(nif string) bool {
 	if len(nif) != 9 {
 		return false
 	}
-
-	if nif[0] != '1' && nif[0] != '2' && nif[0] != '3' &&
-		nif[:2] != "45" && nif[0] != '5' && nif[0] != '6' &&
-		nif[:2] != "70" && nif[:2] != "71" && nif[:2] != "72" &&
-		nif[:2] != "74" && nif[:2] != "75" && nif[:2] != "77" &&
-		nif[:2] != "78" && nif[:2] != "79" && nif[0] != '8' &&
-		nif[:2] != "90" && nif[:2] != "91" && nif[:2] != "98" &&
-		nif[:2] != "99" {
-		return false
-	}
-
-	check1 := int(nif[0]-'0') * 9
-	check2 := int(nif[1]-'0') * 8
-	check3 := int(nif[2]-'0') * 7
-	check4 := int(nif[3]-'0') * 6
-	check5 := int(nif[4]-'0') * 5
-	check6 := int(nif[5]-'0') * 4
-	check7 := int(nif[6]-'0') * 3
-	check8 := int(nif[7]-'0') * 2
-
-	total := check1 + check2 + check3 + check4 + check5 + check6 + check7 + check8
-	mod11 := total % 11
-	var comp int
-	if mod11 == 1 || mod11 == 0 {
-		comp = 0
-	} else {
-		comp = 11 - mod11
-	}
-
-	lastDigit := int(nif[8] - '0')
-	return lastDigit == comp
+	_, err := ParseNIF(nif)
+	return err == nil
 }
 
 var (
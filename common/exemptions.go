@@ -0,0 +1,265 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidVatExemption = errors.New("invalid vat exemption")
+)
+
+// CustomerRequirement restricts the customer an exemption code may be used
+// with.
+type CustomerRequirement int
+
+const (
+	// AnyCustomer: no restriction.
+	AnyCustomer CustomerRequirement = iota
+	// DomesticCustomer: the customer must be Portuguese (PT, PT-AC or PT-MA).
+	DomesticCustomer
+	// ForeignCustomer: the customer must not be Portuguese.
+	ForeignCustomer
+	// EUCustomer: the customer must be from another EU member state.
+	EUCustomer
+)
+
+// VatExemptionRule holds the conditions under which an exemption code of
+// [VatExemptionCodes] may be used.
+type VatExemptionRule struct {
+	// TaxCodes lists the TaxCode values the code may accompany. Empty means
+	// any TaxCode, as long as the percentage is 0.
+	TaxCodes []string
+	// Customer restricts the country of the customer.
+	Customer CustomerRequirement
+	// ReverseCharge is set for the autoliquidação codes (M30 to M43), where
+	// the customer accounts for the VAT.
+	ReverseCharge bool
+	// ValidFrom is the first day the code may be used; zero if always valid.
+	ValidFrom time.Time
+	// ValidTo is the first day the code may no longer be used; zero if still
+	// valid.
+	ValidTo time.Time
+	// InvoiceText is the mention the invoice must include.
+	InvoiceText string
+}
+
+// VatExemptionRules maps each code of [VatExemptionCodes] to its rule.
+var VatExemptionRules = map[string]VatExemptionRule{
+	"M01": {TaxCodes: []string{VATExempt}, InvoiceText: "Artigo 16.º, n.º 6 do CIVA"},
+	"M02": {TaxCodes: []string{VATExempt}, InvoiceText: "Artigo 6.º do Decreto-Lei n.º 198/90, de 19 de junho"},
+	"M04": {TaxCodes: []string{VATExempt}, InvoiceText: "Isento artigo 13.º do CIVA"},
+	"M05": {TaxCodes: []string{VATExempt}, InvoiceText: "Isento artigo 14.º do CIVA"},
+	"M06": {TaxCodes: []string{VATExempt}, InvoiceText: "Isento artigo 15.º do CIVA"},
+	"M07": {TaxCodes: []string{VATExempt}, InvoiceText: "Isento artigo 9.º do CIVA"},
+	"M09": {InvoiceText: "IVA - não confere direito a dedução"},
+	"M10": {TaxCodes: []string{VATExempt}, InvoiceText: "IVA – regime de isenção"},
+	"M11": {TaxCodes: []string{VATExempt}, InvoiceText: "Regime particular do tabaco"},
+	"M12": {TaxCodes: []string{VATExempt}, InvoiceText: "Regime da margem de lucro – Agências de viagens"},
+	"M13": {TaxCodes: []string{VATExempt}, InvoiceText: "Regime da margem de lucro – Bens em segunda mão"},
+	"M14": {TaxCodes: []string{VATExempt}, InvoiceText: "Regime da margem de lucro – Objetos de arte"},
+	"M15": {TaxCodes: []string{VATExempt}, InvoiceText: "Regime da margem de lucro – Objetos de coleção e antiguidades"},
+	"M16": {TaxCodes: []string{VATExempt}, Customer: EUCustomer, InvoiceText: "Isento artigo 14.º do RITI"},
+	"M19": {TaxCodes: []string{VATExempt}, InvoiceText: "Outras isenções"},
+	"M20": {InvoiceText: "IVA - regime forfetário"},
+	"M21": {InvoiceText: "IVA – não confere direito à dedução (ou expressão similar)"},
+	"M25": {TaxCodes: []string{VATExempt}, InvoiceText: "Mercadorias à consignação"},
+	"M26": {
+		TaxCodes:    []string{VATExempt},
+		Customer:    DomesticCustomer,
+		ValidFrom:   time.Date(2023, time.April, 18, 0, 0, 0, 0, time.UTC),
+		ValidTo:     time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC),
+		InvoiceText: "Isenção de IVA com direito à dedução no cabaz alimentar",
+	},
+	"M30": reverseCharge(DomesticCustomer),
+	"M31": reverseCharge(DomesticCustomer),
+	"M32": reverseCharge(DomesticCustomer),
+	"M33": reverseCharge(DomesticCustomer),
+	"M34": reverseCharge(DomesticCustomer),
+	"M40": reverseCharge(AnyCustomer),
+	"M41": reverseCharge(DomesticCustomer),
+	"M42": reverseCharge(DomesticCustomer),
+	"M43": reverseCharge(DomesticCustomer),
+	"M99": {TaxCodes: []string{VATExempt, "NS"}, InvoiceText: "Não sujeito ou não tributado"},
+}
+
+func reverseCharge(customer CustomerRequirement) VatExemptionRule {
+	return VatExemptionRule{
+		TaxCodes:      []string{VATExempt},
+		Customer:      customer,
+		ReverseCharge: true,
+		InvoiceText:   "IVA - autoliquidação",
+	}
+}
+
+// ValidOn reports whether the code may be used on date.
+func (r VatExemptionRule) ValidOn(date time.Time) bool {
+	d := civilDate(date)
+	if !r.ValidFrom.IsZero() && d.Before(r.ValidFrom) {
+		return false
+	}
+	return r.ValidTo.IsZero() || d.Before(r.ValidTo)
+}
+
+// VatExemptionUse is a line that carries an exemption code.
+type VatExemptionUse struct {
+	Code string
+	// Reason is the TaxExemptionReason; empty if the document has none, as
+	// in e-Fatura communication.
+	Reason        string
+	TaxCode       string
+	TaxPercentage decimal.Decimal
+	// CustomerCountry is the customer's country (ISO 3166-1, PT-AC or PT-MA);
+	// empty if unknown, which skips the customer check.
+	CustomerCountry string
+	// Date is the tax point date; zero skips the validity check.
+	Date time.Time
+}
+
+// LookupVatExemption returns the code and rule for an exemption code.
+func LookupVatExemption(code string) (VatExemptionCode, VatExemptionRule, bool) {
+	i := slices.IndexFunc(VatExemptionCodes, func(c VatExemptionCode) bool { return c.Code == code })
+	if i < 0 {
+		return VatExemptionCode{}, VatExemptionRule{}, false
+	}
+	return VatExemptionCodes[i], VatExemptionRules[code], true
+}
+
+// ValidateVatExemption checks a line against the rule of its exemption code.
+// The reason must mention the description, the legal provision or the
+// invoice text of the code, compared word by word ignoring case, accents
+// and punctuation. The law allows a similar expression, so other words
+// around it, such as "(ou similar)", are accepted.
+func ValidateVatExemption(u VatExemptionUse) error {
+	code, rule, ok := LookupVatExemption(u.Code)
+	if !ok {
+		return fmt.Errorf("%w: unknown code %s", ErrInvalidVatExemption, u.Code)
+	}
+
+	if !u.TaxPercentage.IsZero() {
+		return fmt.Errorf("%w: %s with tax percentage %s", ErrInvalidVatExemption, u.Code, u.TaxPercentage)
+	}
+
+	if len(rule.TaxCodes) > 0 && !slices.Contains(rule.TaxCodes, u.TaxCode) {
+		return fmt.Errorf("%w: %s with tax code %s, want %s", ErrInvalidVatExemption, u.Code, u.TaxCode, strings.Join(rule.TaxCodes, " or "))
+	}
+
+	if !u.Date.IsZero() && !rule.ValidOn(u.Date) {
+		return fmt.Errorf("%w: %s not valid on %s", ErrInvalidVatExemption, u.Code, u.Date.Format(time.DateOnly))
+	}
+
+	if u.CustomerCountry != "" {
+		country := taxIDCountry(u.CustomerCountry)
+		var ok bool
+		switch rule.Customer {
+		case DomesticCustomer:
+			ok = country == "PT"
+		case ForeignCustomer:
+			ok = country != "PT"
+		case EUCustomer:
+			ok = country != "PT" && slices.Contains(euCountries, country)
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s not allowed for a customer from %s", ErrInvalidVatExemption, u.Code, u.CustomerCountry)
+		}
+	}
+
+	if u.Reason != "" && !mentions(u.Reason, code.Description) && !mentions(u.Reason, code.Law) && !mentions(u.Reason, rule.InvoiceText) {
+		return fmt.Errorf("%w: %s with reason %q, want %q", ErrInvalidVatExemption, u.Code, u.Reason, rule.InvoiceText)
+	}
+
+	return nil
+}
+
+// mentions reports whether reason contains the words of text, ignoring
+// case, accents and punctuation. Notes in parentheses in text, such as "(ou
+// expressão similar)", are left out. Runs of non-ASCII characters in reason
+// that do not match, as left by a wrong encoding ("Autoliquidaï¿½ï¿½o"),
+// match any characters.
+func mentions(reason, text string) bool {
+	tw := words(parenthetical.ReplaceAllString(text, " "))
+	rw := words(reason)
+	if len(tw) == 0 {
+		return false
+	}
+	for i := 0; i+len(tw) <= len(rw); i++ {
+		if slices.EqualFunc(rw[i:i+len(tw)], tw, sameWord) {
+			return true
+		}
+	}
+	return false
+}
+
+var parenthetical = regexp.MustCompile(`\([^)]*\)`)
+
+// words lower-cases s, removes ordinal indicators and splits it at spaces,
+// dashes and ASCII punctuation, e.g. "artigo", "14", "do" and "riti" for "Artigo
+// 14.º do RITI".
+func words(s string) []string {
+	s = strings.Map(func(r rune) rune {
+		if r == 'º' || r == 'ª' || r == '°' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.Is(unicode.Pd, r) || r < utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// sameWord compares a word of a reason with a word of the legal text.
+func sameWord(r, t string) bool {
+	t = foldAccents(t)
+	if foldAccents(r) == t {
+		return true
+	}
+	var pattern strings.Builder
+	wild := false
+	for _, c := range r {
+		switch {
+		case c < utf8.RuneSelf:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		case !wild:
+			pattern.WriteString(".*")
+		}
+		wild = c >= utf8.RuneSelf
+	}
+	if !strings.Contains(pattern.String(), ".*") {
+		return false
+	}
+	ok, _ := regexp.MatchString("^"+pattern.String()+"$", t)
+	return ok
+}
+
+func foldAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(s))
+}
+
+// IsEUCountry reports whether country, a SAF-T Country value, is an EU
+// member state. PT-AC, PT-MA and the VIES prefix EL are accepted.
+func IsEUCountry(country string) bool {
+	return slices.Contains(euCountries, taxIDCountry(country))
+}
+
+// euCountries are the EU member states (ISO 3166-1; GR for Greece).
+var euCountries = []string{
+	"AT", "BE", "BG", "CY", "CZ", "DE", "DK", "EE", "ES", "FI",
+	"FR", "GR", "HR", "HU", "IE", "IT", "LT", "LU", "LV", "MT",
+	"NL", "PL", "PT", "RO", "SE", "SI", "SK",
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateVatExemption(t *testing.T) {
+	date := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	valid := []VatExemptionUse{
+		{Code: "M07", Reason: "Isento artigo 9.º do CIVA", TaxCode: VATExempt, Date: date},
+		{Code: "M07", Reason: "Artigo 9.º do CIVA", TaxCode: VATExempt, Date: date},
+		{Code: "M05", TaxCode: VATExempt, CustomerCountry: "AO", Date: date},
+		{Code: "M05", TaxCode: VATExempt, CustomerCountry: "PT", Date: date},
+		{Code: "M16", Reason: "Isento Artigo 14º do RITI (ou similar)", TaxCode: VATExempt, CustomerCountry: "ES", Date: date},
+		{Code: "M30", Reason: "IVA - Autoliquidacao", TaxCode: VATExempt, CustomerCountry: "PT", Date: date},
+		{Code: "M30", Reason: "IVA - Autoliquidaï¿½ï¿½o", TaxCode: VATExempt, CustomerCountry: "PT", Date: date},
+		{Code: "M16", Reason: "Isento Artigo 14ï¿½ do RITI (ou similar)", TaxCode: VATExempt, CustomerCountry: "ES", Date: date},
+		{Code: "M21", Reason: "IVA - não confere direito à dedução", TaxCode: VATExempt, Date: date},
+		{Code: "M16", TaxCode: VATExempt, CustomerCountry: "ES", Date: date},
+		{Code: "M30", Reason: "IVA - autoliquidação", TaxCode: VATExempt, CustomerCountry: "PT-MA", Date: date},
+		{Code: "M26", TaxCode: VATExempt, CustomerCountry: "PT", Date: time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{Code: "M99", TaxCode: "NS"},
+	}
+	for _, u := range valid {
+		if err := ValidateVatExemption(u); err != nil {
+			t.Errorf("ValidateVatExemption(%+v) = %v", u, err)
+		}
+	}
+
+	invalid := []VatExemptionUse{
+		{Code: "M03", TaxCode: VATExempt},
+		{Code: "M07", TaxCode: VATNormal},
+		{Code: "M07", TaxCode: VATExempt, TaxPercentage: decimal.NewFromInt(23)},
+		{Code: "M07", Reason: "Isento", TaxCode: VATExempt},
+		{Code: "M30", Reason: "IVA - regime de isenção", TaxCode: VATExempt, CustomerCountry: "PT"},
+		{Code: "M07", Reason: "Isento artigo 19.º do CIVA", TaxCode: VATExempt},
+		{Code: "M16", Reason: "Isento artigo 14.º do CIVA", TaxCode: VATExempt, CustomerCountry: "ES"},
+		{Code: "M16", TaxCode: VATExempt, CustomerCountry: "US"},
+		{Code: "M30", TaxCode: VATExempt, CustomerCountry: "FR"},
+		{Code: "M26", TaxCode: VATExempt, Date: date},
+	}
+	for _, u := range invalid {
+		if err := ValidateVatExemption(u); !errors.Is(err, ErrInvalidVatExemption) {
+			t.Errorf("ValidateVatExemption(%+v) = %v, want ErrInvalidVatExemption", u, err)
+		}
+	}
+
+	for _, c := range VatExemptionCodes {
+		if _, ok := VatExemptionRules[c.Code]; !ok {
+			t.Errorf("no rule for %s", c.Code)
+		}
+	}
+}
+
+func TestIsEUCountry(t *testing.T) {
+	for country, want := range map[string]bool{"ES": true, "el": true, "PT-AC": true, "GB": false, "XI": false, "AO": false, "": false} {
+		if got := IsEUCountry(country); got != want {
+			t.Errorf("IsEUCountry(%q) = %v, want %v", country, got, want)
+		}
+	}
+}
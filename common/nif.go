@@ -0,0 +1,163 @@
+package common
+
+import "fmt"
+
+// NIFType is the kind of entity a Portuguese NIF was assigned to, as given
+// by its first digits.
+type NIFType int
+
+const (
+	NIFTypeUnknown NIFType = iota
+	// NIFSingularPerson: 1, 2 or 3 — pessoa singular.
+	NIFSingularPerson
+	// NIFSingularNonResident: 45 — pessoa singular não residente.
+	NIFSingularNonResident
+	// NIFCollectiveEntity: 5 — pessoa coletiva.
+	NIFCollectiveEntity
+	// NIFPublicAdministration: 6 — administração pública central, regional
+	// ou local.
+	NIFPublicAdministration
+	// NIFUndividedInheritance: 70, 74 and 75 — herança indivisa.
+	NIFUndividedInheritance
+	// NIFNonResidentWithholding: 71 — não residente coletivo sujeito a
+	// retenção na fonte a título definitivo.
+	NIFNonResidentWithholding
+	// NIFInvestmentFund: 72 — fundo de investimento.
+	NIFInvestmentFund
+	// NIFOfficialAssignment: 77 — atribuição oficiosa de NIF de sujeito
+	// passivo.
+	NIFOfficialAssignment
+	// NIFVATRefundNonResident: 78 — não residente abrangido pelo regime de
+	// reembolso de IVA (VAT refund).
+	NIFVATRefundNonResident
+	// NIFExceptionalRegime: 79 — regime excecional (Expo 98).
+	NIFExceptionalRegime
+	// NIFSoleTrader: 8 — empresário em nome individual (no longer issued).
+	NIFSoleTrader
+	// NIFCondominiumOrIrregular: 90 and 91 — condomínio, sociedade irregular
+	// ou herança indivisa de empresário individual.
+	NIFCondominiumOrIrregular
+	// NIFNonResident: 98 — não residente sem estabelecimento estável.
+	NIFNonResident
+	// NIFCivilPartnership: 99 — sociedade civil sem personalidade jurídica.
+	NIFCivilPartnership
+	// NIFFinalConsumer is the generic final consumer NIF [FinalConsumerNIF].
+	NIFFinalConsumer
+)
+
+var nifTypeNames = map[NIFType]string{
+	NIFTypeUnknown:            "Desconhecido",
+	NIFSingularPerson:         "Pessoa singular",
+	NIFSingularNonResident:    "Pessoa singular não residente",
+	NIFCollectiveEntity:       "Pessoa coletiva",
+	NIFPublicAdministration:   "Administração pública",
+	NIFUndividedInheritance:   "Herança indivisa",
+	NIFNonResidentWithholding: "Não residente sujeito a retenção na fonte a título definitivo",
+	NIFInvestmentFund:         "Fundo de investimento",
+	NIFOfficialAssignment:     "Atribuição oficiosa",
+	NIFVATRefundNonResident:   "Não residente (reembolso de IVA)",
+	NIFExceptionalRegime:      "Regime excecional",
+	NIFSoleTrader:             "Empresário em nome individual",
+	NIFCondominiumOrIrregular: "Condomínio, sociedade irregular ou herança indivisa",
+	NIFNonResident:            "Não residente sem estabelecimento estável",
+	NIFCivilPartnership:       "Sociedade civil sem personalidade jurídica",
+	NIFFinalConsumer:          "Consumidor final",
+}
+
+// String returns the Portuguese description of the entity type.
+func (t NIFType) String() string {
+	if name, ok := nifTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("NIFType(%d)", int(t))
+}
+
+// nifPrefixes maps the leading digits of a NIF to its type. One-digit
+// prefixes are looked up after two-digit ones.
+var nifPrefixes = map[string]NIFType{
+	"1":  NIFSingularPerson,
+	"2":  NIFSingularPerson,
+	"3":  NIFSingularPerson,
+	"45": NIFSingularNonResident,
+	"5":  NIFCollectiveEntity,
+	"6":  NIFPublicAdministration,
+	"70": NIFUndividedInheritance,
+	"71": NIFNonResidentWithholding,
+	"72": NIFInvestmentFund,
+	"74": NIFUndividedInheritance,
+	"75": NIFUndividedInheritance,
+	"77": NIFOfficialAssignment,
+	"78": NIFVATRefundNonResident,
+	"79": NIFExceptionalRegime,
+	"8":  NIFSoleTrader,
+	"90": NIFCondominiumOrIrregular,
+	"91": NIFCondominiumOrIrregular,
+	"98": NIFNonResident,
+	"99": NIFCivilPartnership,
+}
+
+// NIF is a parsed Portuguese tax identification number.
+type NIF struct {
+	// Number is the normalized 9-digit NIF, without the PT prefix.
+	Number string
+	// Type is the kind of entity the NIF was assigned to.
+	Type NIFType
+}
+
+// String returns the 9-digit NIF.
+func (n NIF) String() string {
+	return n.Number
+}
+
+// IsFinalConsumer reports whether n is the generic final consumer NIF.
+func (n NIF) IsFinalConsumer() bool {
+	return n.Type == NIFFinalConsumer
+}
+
+// IsSingular reports whether n identifies a natural person, resident or not,
+// including sole traders.
+func (n NIF) IsSingular() bool {
+	switch n.Type {
+	case NIFSingularPerson, NIFSingularNonResident, NIFSoleTrader:
+		return true
+	}
+	return false
+}
+
+// IsCollective reports whether n identifies a legal person or another
+// collective entity (companies, public bodies, funds, condominiums,
+// inheritances, civil partnerships and non-resident entities).
+func (n NIF) IsCollective() bool {
+	return n.Type != NIFTypeUnknown && n.Type != NIFFinalConsumer && !n.IsSingular()
+}
+
+// ParseNIF validates a Portuguese NIF and returns it with its entity type.
+// Spaces, dots, dashes and a leading "PT" are ignored.
+func ParseNIF(s string) (NIF, error) {
+	number := NormalizeTaxID("PT", s)
+	if len(number) != 9 || !isDigits(number) {
+		return NIF{}, fmt.Errorf("%w: %s", ErrInvalidNIFPT, s)
+	}
+	if number == FinalConsumerNIF {
+		return NIF{Number: number, Type: NIFFinalConsumer}, nil
+	}
+
+	t, ok := nifPrefixes[number[:2]]
+	if !ok {
+		t, ok = nifPrefixes[number[:1]]
+	}
+	if !ok || !nifCheckDigitOK(number) {
+		return NIF{}, fmt.Errorf("%w: %s", ErrInvalidNIFPT, s)
+	}
+	return NIF{Number: number, Type: t}, nil
+}
+
+// nifCheckDigitOK verifies the mod 11 check digit of a 9-digit NIF.
+func nifCheckDigitOK(nif string) bool {
+	mod11 := weightedSum(nif, 9, 8, 7, 6, 5, 4, 3, 2) % 11
+	comp := 0
+	if mod11 > 1 {
+		comp = 11 - mod11
+	}
+	return digit(nif, 8) == comp
+}
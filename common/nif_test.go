@@ -0,0 +1,48 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNIF(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       NIFType
+		collective bool
+	}{
+		{"126555397", NIFSingularPerson, false},
+		{"PT 253 557 437", NIFSingularPerson, false},
+		{"454033206", NIFSingularNonResident, false},
+		{"521649986", NIFCollectiveEntity, true},
+		{"649281756", NIFPublicAdministration, true},
+		{"704488272", NIFUndividedInheritance, true},
+		{"718453956", NIFNonResidentWithholding, true},
+		{"727146114", NIFInvestmentFund, true},
+		{"787787825", NIFVATRefundNonResident, true},
+		{"999999990", NIFFinalConsumer, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseNIF(tt.in)
+		if err != nil {
+			t.Errorf("ParseNIF(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got.Type != tt.want || got.IsCollective() != tt.collective {
+			t.Errorf("ParseNIF(%q) = %v (collective %v), want %v (collective %v)", tt.in, got.Type, got.IsCollective(), tt.want, tt.collective)
+		}
+		if len(got.Number) != 9 {
+			t.Errorf("ParseNIF(%q).Number = %q", tt.in, got.Number)
+		}
+	}
+
+	if nif, _ := ParseNIF("999999990"); !nif.IsFinalConsumer() {
+		t.Errorf("ParseNIF(999999990).IsFinalConsumer() = false")
+	}
+
+	for _, in := range []string{"", "12655539", "126555398", "400000000", "12655539A"} {
+		if _, err := ParseNIF(in); !errors.Is(err, ErrInvalidNIFPT) {
+			t.Errorf("ParseNIF(%q) error = %v, want ErrInvalidNIFPT", in, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTaxID(t *testing.T) {
+	valid := []struct {
+		country, id string
+	}{
+		{"AT", "ATU13585627"},
+		{"BE", "BE0403019261"},
+		{"BG", "BG175074752"},
+		{"CY", "CY10259033P"},
+		{"CZ", "CZ25123891"},
+		{"DE", "DE136695976"},
+		{"DK", "DK13585628"},
+		{"EE", "EE100931558"},
+		{"ES", "ESA28015865"},
+		{"ES", "12345678Z"},
+		{"ES", "X1234567L"},
+		{"FI", "FI20774740"},
+		{"FR", "FR40303265045"},
+		{"GR", "EL094259216"},
+		{"EL", "094259216"},
+		{"HU", "HU12892312"},
+		{"IE", "IE6433435F"},
+		{"IE", "IE8Z49289F"},
+		{"HR", "HR33392005961"},
+		{"IT", "IT00743110157"},
+		{"LT", "LT119511515"},
+		{"LU", "LU15027442"},
+		{"LV", "LV40003521600"},
+		{"LV", "16117519997"},
+		{"MT", "MT11679112"},
+		{"NL", "NL004495445B01"},
+		{"PL", "PL8567346215"},
+		{"PT", "PT 501 442 600"},
+		{"PT-MA", "511234562"},
+		{"RO", "RO18547290"},
+		{"SE", "SE556012579001"},
+		{"SI", "SI50223054"},
+		{"SK", "SK2022749619"},
+		{"GB", "GB980780684"},
+		{"XI", "XI980780684"},
+		{"CH", "CHE-116.281.710 MWST"},
+		{"NO", "974760673MVA"},
+		{"BR", "529.982.247-25"},
+		{"BR", "11.222.333/0001-81"},
+		{"US", "12-3456789"},
+		// Consumidor final is accepted for any country.
+		{"ES", "999999990"},
+		{"Desconhecido", "999999990"},
+		// Countries without known rules are accepted.
+		{"AO", "5417012345"},
+	}
+	for _, tt := range valid {
+		if err := ValidateTaxID(tt.country, tt.id); err != nil {
+			t.Errorf("ValidateTaxID(%q, %q) error = %v", tt.country, tt.id, err)
+		}
+	}
+
+	invalid := []struct {
+		country, id string
+	}{
+		{"AT", "ATU13585626"},
+		{"BE", "BE0403019262"},
+		{"DE", "DE136695977"},
+		{"ES", "ESA28015866"},
+		{"ES", "12345678A"},
+		{"FR", "FR41303265045"},
+		{"IT", "IT00743110158"},
+		{"NL", "NL004495446B01"},
+		{"PL", "PL8567346216"},
+		{"PT", "501442601"},
+		{"GB", "GB980780685"},
+		{"AO", ""},
+	}
+	for _, tt := range invalid {
+		if err := ValidateTaxID(tt.country, tt.id); !errors.Is(err, ErrInvalidTaxID) {
+			t.Errorf("ValidateTaxID(%q, %q) error = %v, want ErrInvalidTaxID", tt.country, tt.id, err)
+		}
+	}
+}
+
+func TestTaxIDCountry(t *testing.T) {
+	tests := map[string]string{
+		"ES A28015865":    "ES",
+		"EL094259216":     "GR",
+		"CHE-116.281.710": "CH",
+		"PT501442600":     "PT",
+		"501442600":       "",
+		"A28015865":       "",
+		"ZZ123":           "",
+		"ES":              "",
+	}
+	for id, want := range tests {
+		if got := TaxIDCountry(id); got != want {
+			t.Errorf("TaxIDCountry(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestIsFinalConsumer(t *testing.T) {
+	if !IsFinalConsumer("PT999999990") {
+		t.Errorf("IsFinalConsumer(PT999999990) = false")
+	}
+	if IsFinalConsumer("501442600") {
+		t.Errorf("IsFinalConsumer(501442600) = true")
+	}
+}
@@ -0,0 +1,177 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Tax codes used in the SAF-T TaxTable (TaxCode) for VAT.
+const (
+	VATReduced      = "RED"
+	VATIntermediate = "INT"
+	VATNormal       = "NOR"
+	VATExempt       = "ISE"
+	VATOther        = "OUT"
+)
+
+// Tax regions used in the SAF-T TaxTable (TaxCountryRegion) for Portugal.
+const (
+	RegionMainland = "PT"
+	RegionAzores   = "PT-AC"
+	RegionMadeira  = "PT-MA"
+)
+
+var (
+	ErrUnknownVATRate = errors.New("unknown vat rate")
+)
+
+// VATRate is the percentage of a tax code in a region during a period.
+type VATRate struct {
+	// Region is the TaxCountryRegion: PT, PT-AC or PT-MA.
+	Region string
+	// Code is the TaxCode: RED, INT, NOR or ISE.
+	Code string
+	// Percentage is the rate, e.g. 23 for 23%.
+	Percentage decimal.Decimal
+	// From is the first day the rate applies.
+	From time.Time
+	// To is the first day the rate no longer applies; zero if still in force.
+	To time.Time
+}
+
+// In reports whether the rate applies on date.
+func (r VATRate) In(date time.Time) bool {
+	d := civilDate(date)
+	return !d.Before(r.From) && (r.To.IsZero() || d.Before(r.To))
+}
+
+// VATRates is a catalogue of VAT rates.
+type VATRates []VATRate
+
+// PortugueseVATRates holds the VAT rates of mainland Portugal, the Azores
+// and Madeira since 2010 (CIVA, artigo 18.º, and the regional rates set
+// under the Lei das Finanças das Regiões Autónomas).
+var PortugueseVATRates = buildVATRates(
+	vatRegion(RegionMainland,
+		vatPeriod{"2010-01-01", 5, 12, 20},
+		vatPeriod{"2010-07-01", 6, 13, 21},
+		vatPeriod{"2011-01-01", 6, 13, 23},
+	),
+	vatRegion(RegionAzores,
+		vatPeriod{"2010-01-01", 4, 8, 14},
+		vatPeriod{"2010-07-01", 4, 9, 15},
+		vatPeriod{"2011-01-01", 4, 9, 16},
+		vatPeriod{"2014-01-01", 5, 10, 18},
+		vatPeriod{"2015-07-01", 4, 9, 18},
+		vatPeriod{"2021-07-01", 4, 9, 16},
+	),
+	vatRegion(RegionMadeira,
+		vatPeriod{"2010-01-01", 4, 8, 14},
+		vatPeriod{"2010-07-01", 4, 9, 15},
+		vatPeriod{"2011-01-01", 4, 9, 16},
+		vatPeriod{"2012-04-01", 5, 12, 22},
+		vatPeriod{"2024-01-01", 4, 12, 22},
+	),
+)
+
+// VATRateAt returns the percentage of code in region on date from
+// [PortugueseVATRates].
+func VATRateAt(region, code string, date time.Time) (decimal.Decimal, error) {
+	return PortugueseVATRates.Lookup(region, code, date)
+}
+
+// Lookup returns the percentage of code in region on date.
+func (rates VATRates) Lookup(region, code string, date time.Time) (decimal.Decimal, error) {
+	for _, r := range rates {
+		if r.Region == region && r.Code == code && r.In(date) {
+			return r.Percentage, nil
+		}
+	}
+	return decimal.Decimal{}, fmt.Errorf("%w: %s %s on %s", ErrUnknownVATRate, region, code, date.Format(time.DateOnly))
+}
+
+// CodeFor returns the tax code with the given percentage in region on date,
+// e.g. NOR for 22 in PT-MA in 2024.
+func (rates VATRates) CodeFor(region string, percentage decimal.Decimal, date time.Time) (string, bool) {
+	for _, r := range rates {
+		if r.Region == region && r.In(date) && r.Percentage.Equal(percentage) {
+			return r.Code, true
+		}
+	}
+	return "", false
+}
+
+// At returns the rates in force on date, ordered by region and code, e.g. to
+// generate a SAF-T TaxTable.
+func (rates VATRates) At(date time.Time) []VATRate {
+	var out []VATRate
+	for _, r := range rates {
+		if r.In(date) {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Region != out[j].Region {
+			return out[i].Region < out[j].Region
+		}
+		return out[i].Code < out[j].Code
+	})
+	return out
+}
+
+// civilDate truncates t to midnight UTC of its calendar day.
+func civilDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+type vatPeriod struct {
+	from                       string
+	reduced, intermediate, nor int64
+}
+
+// vatRegion expands consecutive periods of a region into rates, each ending
+// when the next one starts.
+func vatRegion(region string, periods ...vatPeriod) VATRates {
+	var out VATRates
+	for i, p := range periods {
+		from, err := time.Parse(time.DateOnly, p.from)
+		if err != nil {
+			panic(err)
+		}
+		var to time.Time
+		if i+1 < len(periods) {
+			to, _ = time.Parse(time.DateOnly, periods[i+1].from)
+		}
+		for _, r := range []struct {
+			code string
+			pct  int64
+		}{
+			{VATReduced, p.reduced},
+			{VATIntermediate, p.intermediate},
+			{VATNormal, p.nor},
+			{VATExempt, 0},
+		} {
+			out = append(out, VATRate{
+				Region:     region,
+				Code:       r.code,
+				Percentage: decimal.NewFromInt(r.pct),
+				From:       from,
+				To:         to,
+			})
+		}
+	}
+	return out
+}
+
+func buildVATRates(regions ...VATRates) VATRates {
+	var out VATRates
+	for _, r := range regions {
+		out = append(out, r...)
+	}
+	return out
+}
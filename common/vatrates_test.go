@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestVATRateAt(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+
+	tests := []struct {
+		region, code, date string
+		want               int64
+	}{
+		{RegionMainland, VATNormal, "2010-06-30", 20},
+		{RegionMainland, VATNormal, "2010-07-01", 21},
+		{RegionMainland, VATNormal, "2025-01-01", 23},
+		{RegionMainland, VATIntermediate, "2025-01-01", 13},
+		{RegionMainland, VATReduced, "2025-01-01", 6},
+		{RegionMadeira, VATNormal, "2012-03-31", 16},
+		{RegionMadeira, VATNormal, "2025-01-01", 22},
+		{RegionAzores, VATNormal, "2020-01-01", 18},
+		{RegionAzores, VATNormal, "2025-01-01", 16},
+		{RegionAzores, VATExempt, "2025-01-01", 0},
+	}
+	for _, tt := range tests {
+		got, err := VATRateAt(tt.region, tt.code, date(tt.date))
+		if err != nil {
+			t.Errorf("VATRateAt(%s, %s, %s) error = %v", tt.region, tt.code, tt.date, err)
+			continue
+		}
+		if !got.Equal(decimal.NewFromInt(tt.want)) {
+			t.Errorf("VATRateAt(%s, %s, %s) = %s, want %d", tt.region, tt.code, tt.date, got, tt.want)
+		}
+	}
+
+	if _, err := VATRateAt(RegionMainland, VATNormal, date("2009-12-31")); !errors.Is(err, ErrUnknownVATRate) {
+		t.Errorf("VATRateAt before 2010 error = %v, want ErrUnknownVATRate", err)
+	}
+
+	if code, ok := PortugueseVATRates.CodeFor(RegionMadeira, decimal.NewFromInt(22), date("2024-05-01")); !ok || code != VATNormal {
+		t.Errorf("CodeFor(PT-MA, 22) = %q, %v, want NOR", code, ok)
+	}
+
+	if got := PortugueseVATRates.At(date("2025-01-01")); len(got) != 12 || got[0].Region != RegionMainland {
+		t.Errorf("At(2025-01-01) returned %d rates starting with %+v", len(got), got[0])
+	}
+}
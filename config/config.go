@@ -0,0 +1,388 @@
+// Package config builds ready-to-use clients for every AT webservice from a
+// single configuration, loaded from environment variables or from a YAML or
+// JSON file:
+//
+//	cfg, err := config.Load("at.yaml")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	clients, err := cfg.NewClients()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	resp, err := clients.SeriesWS.ConsultarSeries(req)
+//
+// An example file:
+//
+//	environment: test
+//	clientCertificate:
+//	  pfxFile: /etc/at/555555555.pfx
+//	  password: pfx_password
+//	atPublicKeyFile: /etc/at/ChaveCifraPublicaAT2027.cer
+//	atTestPublicKeyFile: /etc/at/ChavePublicaTeste.cer
+//	username: 555555555/37
+//	timeout: 30s
+//	log:
+//	  enabled: true
+//	  maskPersonalData: true
+package config
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/fatcorews"
+	"github.com/hestiatechnology/autoridadetributaria/fatcorews/types"
+	"github.com/hestiatechnology/autoridadetributaria/security"
+	"github.com/hestiatechnology/autoridadetributaria/seriesws"
+	"github.com/hestiatechnology/autoridadetributaria/soap"
+	"github.com/hestiatechnology/autoridadetributaria/workDocuments"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment selects the AT test or production endpoints.
+type Environment string
+
+const (
+	Test       Environment = "test"
+	Production Environment = "prod"
+)
+
+// Config describes how to reach and authenticate against the AT webservices.
+type Config struct {
+	// Environment is "test" or "prod". Required.
+	Environment Environment `yaml:"environment" json:"environment"`
+
+	// Endpoints overrides the default URL of individual services.
+	Endpoints Endpoints `yaml:"endpoints" json:"endpoints"`
+
+	// ClientCertificate is the software producer's mTLS certificate.
+	ClientCertificate ClientCertificate `yaml:"clientCertificate" json:"clientCertificate"`
+
+	// ATPublicKeyFile is the AT public key used to encrypt WS-Security
+	// credentials (the .cer file provided by AT).
+	ATPublicKeyFile string `yaml:"atPublicKeyFile" json:"atPublicKeyFile"`
+	// ATPublicKey is the PEM content of the AT public key, used instead of
+	// ATPublicKeyFile.
+	ATPublicKey string `yaml:"atPublicKey" json:"atPublicKey"`
+	// ATTestPublicKeyFile is an AT test public key to refuse with production
+	// endpoints, in addition to those in [security.ATTestKeyFingerprints].
+	ATTestPublicKeyFile string `yaml:"atTestPublicKeyFile" json:"atTestPublicKeyFile"`
+
+	// Username and Password are the Portal das Finanças sub-user
+	// credentials. If Username is empty, they are read from the AT_USERNAME
+	// and AT_PASSWORD environment variables on every call.
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	// Credentials, if set, takes precedence over Username and Password.
+	Credentials security.CredentialProvider `yaml:"-" json:"-"`
+
+	// Timeout is the per-request HTTP timeout, e.g. "30s".
+	Timeout Duration `yaml:"timeout" json:"timeout"`
+
+	// Retry is the number of attempts made for calls that fail because AT is
+	// unavailable. Zero or one disables retries.
+	Retry int `yaml:"retry" json:"retry"`
+
+	// Log configures structured request logging.
+	Log Log `yaml:"log" json:"log"`
+}
+
+// Endpoints holds per-service URL overrides.
+type Endpoints struct {
+	SeriesWS      string `yaml:"seriesws" json:"seriesws"`
+	FatcoreWS     string `yaml:"fatcorews" json:"fatcorews"`
+	WorkDocuments string `yaml:"workDocuments" json:"workDocuments"`
+}
+
+// ClientCertificate locates the mTLS client certificate. Set either a PFX
+// (file or base64 content) or a PEM certificate and key pair.
+type ClientCertificate struct {
+	PFXFile   string `yaml:"pfxFile" json:"pfxFile"`
+	PFXBase64 string `yaml:"pfxBase64" json:"pfxBase64"`
+	Password  string `yaml:"password" json:"password"`
+	CertFile  string `yaml:"certFile" json:"certFile"`
+	KeyFile   string `yaml:"keyFile" json:"keyFile"`
+}
+
+// Log configures a [security.SlogHTTPClient] around the HTTP client.
+type Log struct {
+	Enabled          bool    `yaml:"enabled" json:"enabled"`
+	MaskPersonalData bool    `yaml:"maskPersonalData" json:"maskPersonalData"`
+	MaxBodySize      int     `yaml:"maxBodySize" json:"maxBodySize"`
+	SampleRate       float64 `yaml:"sampleRate" json:"sampleRate"`
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger `yaml:"-" json:"-"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" or "1m".
+type Duration time.Duration
+
+// UnmarshalText parses a duration in time.ParseDuration format.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration in time.Duration.String format.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Load reads a configuration file. Files ending in .json are decoded as
+// JSON; anything else as YAML.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	cfg := &Config{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, cfg)
+	} else {
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// FromEnv builds a configuration from environment variables:
+//
+//	AT_ENVIRONMENT                test or prod
+//	AT_SERIESWS_URL               endpoint overrides
+//	AT_FATCOREWS_URL
+//	AT_WORKDOCUMENTS_URL
+//	AT_CLIENT_CERT_PFX            PFX file, or
+//	AT_CLIENT_CERT_PFX_BASE64     base64 PFX content, or
+//	AT_CLIENT_CERT_FILE           PEM certificate and
+//	AT_CLIENT_KEY_FILE            PEM key
+//	AT_CLIENT_CERT_PASSWORD       PFX password
+//	AT_PUBLIC_KEY_FILE            AT public key file, or
+//	AT_PUBLIC_KEY                 AT public key PEM
+//	AT_TEST_PUBLIC_KEY_FILE       AT test public key file
+//	AT_USERNAME, AT_PASSWORD      credentials, read on every call
+//	AT_TIMEOUT                    e.g. 30s
+//	AT_RETRY                      number of attempts
+//	AT_LOG                        true to enable logging
+//	AT_LOG_MASK_PERSONAL_DATA     true to mask NIFs and addresses
+func FromEnv() (*Config, error) {
+	cfg := &Config{
+		Environment: Environment(os.Getenv("AT_ENVIRONMENT")),
+		Endpoints: Endpoints{
+			SeriesWS:      os.Getenv("AT_SERIESWS_URL"),
+			FatcoreWS:     os.Getenv("AT_FATCOREWS_URL"),
+			WorkDocuments: os.Getenv("AT_WORKDOCUMENTS_URL"),
+		},
+		ClientCertificate: ClientCertificate{
+			PFXFile:   os.Getenv("AT_CLIENT_CERT_PFX"),
+			PFXBase64: os.Getenv("AT_CLIENT_CERT_PFX_BASE64"),
+			Password:  os.Getenv("AT_CLIENT_CERT_PASSWORD"),
+			CertFile:  os.Getenv("AT_CLIENT_CERT_FILE"),
+			KeyFile:   os.Getenv("AT_CLIENT_KEY_FILE"),
+		},
+		ATPublicKeyFile:     os.Getenv("AT_PUBLIC_KEY_FILE"),
+		ATPublicKey:         os.Getenv("AT_PUBLIC_KEY"),
+		ATTestPublicKeyFile: os.Getenv("AT_TEST_PUBLIC_KEY_FILE"),
+		// Username is left empty so credentials are read on every call.
+	}
+
+	if v := os.Getenv("AT_TIMEOUT"); v != "" {
+		if err := cfg.Timeout.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("AT_TIMEOUT: %w", err)
+		}
+	}
+	if v := os.Getenv("AT_RETRY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("AT_RETRY: %w", err)
+		}
+		cfg.Retry = n
+	}
+	for name, dst := range map[string]*bool{
+		"AT_LOG":                    &cfg.Log.Enabled,
+		"AT_LOG_MASK_PERSONAL_DATA": &cfg.Log.MaskPersonalData,
+	} {
+		if v := os.Getenv(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = b
+		}
+	}
+
+	return cfg, nil
+}
+
+// SeriesWSURL returns the series webservice endpoint.
+func (c *Config) SeriesWSURL() string {
+	return c.endpoint(c.Endpoints.SeriesWS, seriesws.TestURL, seriesws.ProdURL)
+}
+
+// FatcoreWSURL returns the invoice communication webservice endpoint.
+func (c *Config) FatcoreWSURL() string {
+	return c.endpoint(c.Endpoints.FatcoreWS, fatcorews.TestURL, fatcorews.ProdURL)
+}
+
+// WorkDocumentsURL returns the transport documents webservice endpoint.
+func (c *Config) WorkDocumentsURL() string {
+	return c.endpoint(c.Endpoints.WorkDocuments, workDocuments.TestURL, workDocuments.ProdURL)
+}
+
+func (c *Config) endpoint(override, test, prod string) string {
+	if override != "" {
+		return override
+	}
+	if c.Environment == Production {
+		return prod
+	}
+	return test
+}
+
+// Validate checks the configuration without loading any file.
+func (c *Config) Validate() error {
+	switch c.Environment {
+	case Test, Production:
+	default:
+		return fmt.Errorf("config: environment must be %q or %q, got %q", Test, Production, c.Environment)
+	}
+
+	for _, endpoint := range []string{c.SeriesWSURL(), c.FatcoreWSURL(), c.WorkDocumentsURL()} {
+		if isATEndpoint(endpoint) && security.IsProductionURL(endpoint) != (c.Environment == Production) {
+			return fmt.Errorf("config: endpoint %s does not belong to the %s environment", endpoint, c.Environment)
+		}
+	}
+
+	cc := c.ClientCertificate
+	if cc.PFXFile == "" && cc.PFXBase64 == "" && (cc.CertFile == "" || cc.KeyFile == "") {
+		return fmt.Errorf("config: missing client certificate")
+	}
+	if c.ATPublicKeyFile == "" && c.ATPublicKey == "" {
+		return fmt.Errorf("config: missing AT public key")
+	}
+	return nil
+}
+
+// isATEndpoint reports whether endpoint is served by AT itself, as opposed to
+// e.g. a local proxy or mock whose port says nothing about the environment.
+func isATEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	return err == nil && u.Hostname() == "servicos.portaldasfinancas.gov.pt"
+}
+
+// Clients holds a client for every AT webservice, sharing the same
+// certificate, AT public key and credentials.
+type Clients struct {
+	SeriesWS      seriesws.SeriesWS
+	FatcoreWS     types.FatcorewsPort
+	WorkDocuments *workDocuments.Client
+}
+
+// NewClients validates the configuration, loads the certificate and keys,
+// and builds the clients.
+func (c *Config) NewClients() (*Clients, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	cert, err := c.loadClientCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	atPubKey, err := c.loadATPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	var testKeys []*rsa.PublicKey
+	if c.ATTestPublicKeyFile != "" {
+		testKey, err := security.LoadATPublicKey(c.ATTestPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		testKeys = append(testKeys, testKey)
+	}
+	for _, endpoint := range []string{c.SeriesWSURL(), c.FatcoreWSURL(), c.WorkDocumentsURL()} {
+		if err := security.CheckATPublicKey(atPubKey, endpoint, testKeys...); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+
+	credentials := c.Credentials
+	if credentials == nil {
+		if c.Username != "" {
+			credentials = security.NewStaticCredentials(c.Username, c.Password)
+		} else {
+			credentials = security.EnvCredentials{}
+		}
+	}
+
+	opts := []soap.Option{soap.WithHTTPClient(c.httpClient(cert))}
+	if c.Retry > 1 {
+		policy := security.DefaultRetryPolicy
+		policy.MaxAttempts = c.Retry
+		opts = append(opts, soap.WithRetry(policy))
+	}
+	withSecurity := append(opts[:len(opts):len(opts)], soap.WithCredentials(credentials, atPubKey))
+
+	return &Clients{
+		SeriesWS:      seriesws.NewSeriesWS(soap.NewClient(c.SeriesWSURL(), withSecurity...)),
+		FatcoreWS:     fatcorews.NewPort(soap.NewClient(c.FatcoreWSURL(), withSecurity...)),
+		WorkDocuments: workDocuments.NewClientWithCredentials(c.WorkDocumentsURL(), credentials, cert, atPubKey, opts...),
+	}, nil
+}
+
+func (c *Config) httpClient(cert tls.Certificate) security.HTTPDoer {
+	var client security.HTTPDoer = &http.Client{
+		Timeout: time.Duration(c.Timeout),
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+		},
+	}
+	if c.Log.Enabled {
+		client = &security.SlogHTTPClient{
+			Client:           client,
+			Logger:           c.Log.Logger,
+			MaskPersonalData: c.Log.MaskPersonalData,
+			MaxBodySize:      c.Log.MaxBodySize,
+			SampleRate:       c.Log.SampleRate,
+		}
+	}
+	return client
+}
+
+func (c *Config) loadClientCertificate() (tls.Certificate, error) {
+	cc := c.ClientCertificate
+	switch {
+	case cc.PFXFile != "":
+		return security.LoadClientCertPFX(cc.PFXFile, cc.Password)
+	case cc.PFXBase64 != "":
+		return security.ClientCertFromPFXBase64(cc.PFXBase64, cc.Password)
+	default:
+		return security.LoadClientCert(cc.CertFile, cc.KeyFile)
+	}
+}
+
+func (c *Config) loadATPublicKey() (*rsa.PublicKey, error) {
+	if c.ATPublicKeyFile != "" {
+		return security.LoadATPublicKey(c.ATPublicKeyFile)
+	}
+	return security.ATPublicKeyFromString(c.ATPublicKey)
+}
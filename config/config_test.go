@@ -0,0 +1,144 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/security"
+	"github.com/hestiatechnology/autoridadetributaria/seriesws"
+	"github.com/hestiatechnology/autoridadetributaria/workDocuments"
+)
+
+// writeTestMaterial writes a self-signed client certificate, its key and an
+// AT public key certificate to dir.
+func writeTestMaterial(t *testing.T, dir string) (certFile, keyFile, atKeyFile string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "555555555"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	certFile = write("client.crt", "CERTIFICATE", der)
+	keyFile = write("client.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	atKeyFile = write("at.cer", "CERTIFICATE", der)
+	return certFile, keyFile, atKeyFile
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "at.yaml")
+	os.WriteFile(yamlFile, []byte(`
+environment: prod
+endpoints:
+  seriesws: https://proxy.internal/series
+clientCertificate:
+  pfxFile: /etc/at/555555555.pfx
+atPublicKeyFile: /etc/at/at.cer
+timeout: 45s
+log:
+  enabled: true
+`), 0o600)
+	jsonFile := filepath.Join(dir, "at.json")
+	os.WriteFile(jsonFile, []byte(`{"environment":"prod","endpoints":{"seriesws":"https://proxy.internal/series"},"clientCertificate":{"pfxFile":"/etc/at/555555555.pfx"},"atPublicKeyFile":"/etc/at/at.cer","timeout":"45s","log":{"enabled":true}}`), 0o600)
+
+	for _, path := range []string{yamlFile, jsonFile} {
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", path, err)
+		}
+		if cfg.Environment != Production || time.Duration(cfg.Timeout) != 45*time.Second || !cfg.Log.Enabled {
+			t.Errorf("Load(%s) = %+v", path, cfg)
+		}
+		if got := cfg.SeriesWSURL(); got != "https://proxy.internal/series" {
+			t.Errorf("SeriesWSURL() = %s", got)
+		}
+		if got := cfg.WorkDocumentsURL(); got != workDocuments.ProdURL {
+			t.Errorf("WorkDocumentsURL() = %s, want %s", got, workDocuments.ProdURL)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	}
+}
+
+func TestValidateEnvironmentMismatch(t *testing.T) {
+	cfg := &Config{
+		Environment:       Test,
+		Endpoints:         Endpoints{SeriesWS: seriesws.ProdURL},
+		ClientCertificate: ClientCertificate{PFXFile: "x.pfx"},
+		ATPublicKeyFile:   "at.cer",
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Validate() with a prod endpoint in test, want error")
+	}
+}
+
+func TestNewClients(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, atKeyFile := writeTestMaterial(t, dir)
+
+	cfg := &Config{
+		Environment:       Production,
+		ClientCertificate: ClientCertificate{CertFile: certFile, KeyFile: keyFile},
+		ATPublicKeyFile:   atKeyFile,
+		Username:          "555555555/1",
+		Password:          "secret",
+	}
+	clients, err := cfg.NewClients()
+	if err != nil {
+		t.Fatalf("NewClients() error = %v", err)
+	}
+	if clients.SeriesWS == nil || clients.FatcoreWS == nil || clients.WorkDocuments == nil {
+		t.Errorf("NewClients() = %+v", clients)
+	}
+
+	// The configured key is also the test key: refused in production.
+	cfg.ATTestPublicKeyFile = atKeyFile
+	if _, err := cfg.NewClients(); !errors.Is(err, security.ErrTestKeyInProduction) {
+		t.Errorf("NewClients() with the test key in prod: error = %v, want ErrTestKeyInProduction", err)
+	}
+
+	cfg.Environment = Test
+	if _, err := cfg.NewClients(); err != nil {
+		t.Errorf("NewClients() with the test key in test: error = %v", err)
+	}
+
+	// A known test key is refused in production without ATTestPublicKeyFile.
+	atKey, err := security.LoadATPublicKey(atKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := security.PublicKeyFingerprint(atKey)
+	security.ATTestKeyFingerprints[fingerprint] = true
+	defer delete(security.ATTestKeyFingerprints, fingerprint)
+	cfg.Environment, cfg.ATTestPublicKeyFile = Production, ""
+	if _, err := cfg.NewClients(); !errors.Is(err, security.ErrTestKeyInProduction) {
+		t.Errorf("NewClients() with a known test key in prod: error = %v, want ErrTestKeyInProduction", err)
+	}
+}
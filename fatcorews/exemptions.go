@@ -0,0 +1,89 @@
+package fatcorews
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/fatcorews/types"
+	"github.com/shopspring/decimal"
+)
+
+// ValidateInvoiceExemptions checks every LineSummary of an invoice against
+// [common.VatExemptionRules] before it is registered.
+func ValidateInvoiceExemptions(data *types.InvoiceDataType) error {
+	if data == nil {
+		return nil
+	}
+	var country string
+	var date time.Time
+	if h := data.InvoiceHeaderType; h != nil {
+		if h.CustomerTaxIDCountry != nil {
+			country = string(*h.CustomerTaxIDCountry)
+		}
+		if h.InvoiceDate != nil {
+			date = time.Time(*h.InvoiceDate)
+		}
+	}
+	return validateLineSummaries(data.LineSummary, country, date)
+}
+
+// ValidateWorkExemptions checks every LineSummary of a working document
+// against [common.VatExemptionRules] before it is registered.
+func ValidateWorkExemptions(data *types.WorkDataType) error {
+	if data == nil {
+		return nil
+	}
+	var country string
+	var date time.Time
+	if h := data.WorkHeaderType; h != nil {
+		if h.CustomerTaxIDCountry != nil {
+			country = string(*h.CustomerTaxIDCountry)
+		}
+		if h.WorkDate != nil {
+			date = time.Time(*h.WorkDate)
+		}
+	}
+	return validateLineSummaries(data.LineSummary, country, date)
+}
+
+func validateLineSummaries(lines []types.LineSummary, country string, date time.Time) error {
+	if country == "Desconhecido" {
+		country = ""
+	}
+	for i, line := range lines {
+		var u common.VatExemptionUse
+		var percentage decimal.Decimal
+		var taxType types.TaxType
+		if line.Tax != nil {
+			if line.Tax.TaxType != nil {
+				taxType = *line.Tax.TaxType
+			}
+			if line.Tax.TaxCode != nil {
+				u.TaxCode = string(*line.Tax.TaxCode)
+			}
+			if line.Tax.TaxPercentage != nil {
+				percentage = decimal.Decimal(*line.Tax.TaxPercentage)
+			}
+		}
+
+		if line.TaxExemptionCode == nil {
+			if line.Tax != nil && line.Tax.TaxPercentage != nil && percentage.IsZero() && taxType == types.TaxTypeIVA {
+				return fmt.Errorf("fatcorews: missing LineSummary[%d].TaxExemptionCode", i)
+			}
+			continue
+		}
+
+		u.Code = string(*line.TaxExemptionCode)
+		u.TaxPercentage = percentage
+		u.CustomerCountry = country
+		u.Date = date
+		if line.TaxPointDate != nil {
+			u.Date = time.Time(*line.TaxPointDate)
+		}
+		if err := common.ValidateVatExemption(u); err != nil {
+			return fmt.Errorf("fatcorews: invalid LineSummary[%d].TaxExemptionCode: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package fatcorews
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/fatcorews/types"
+	"github.com/shopspring/decimal"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func exemptLine(code string, pct int64) types.LineSummary {
+	line := types.LineSummary{
+		Tax: &types.Tax{
+			TaxType:       ptr(types.TaxTypeIVA),
+			TaxCode:       ptr(types.TaxCode(common.VATExempt)),
+			TaxPercentage: ptr(types.PercentageType(decimal.NewFromInt(pct))),
+		},
+	}
+	if code != "" {
+		line.TaxExemptionCode = ptr(types.TaxExemptionCode(code))
+	}
+	return line
+}
+
+func invoiceData(country string, lines ...types.LineSummary) *types.InvoiceDataType {
+	return &types.InvoiceDataType{
+		InvoiceHeaderType: &types.InvoiceHeaderType{
+			InvoiceDate:          ptr(types.InvoiceDate(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC))),
+			CustomerTaxIDCountry: ptr(types.CustomerTaxIDCountry(country)),
+		},
+		LineSummary: lines,
+	}
+}
+
+func TestValidateInvoiceExemptions(t *testing.T) {
+	tests := []struct {
+		name string
+		data *types.InvoiceDataType
+		ok   bool
+	}{
+		{"no data", nil, true},
+		{"exempt line", invoiceData("PT", exemptLine("M07", 0)), true},
+		{"intra-community supply", invoiceData("ES", exemptLine("M16", 0)), true},
+		{"unknown customer country", invoiceData("Desconhecido", exemptLine("M16", 0)), true},
+		{"missing code at 0%", invoiceData("PT", exemptLine("", 0)), false},
+		{"unknown code", invoiceData("PT", exemptLine("M03", 0)), false},
+		{"code with a rate", invoiceData("PT", exemptLine("M07", 23)), false},
+		{"intra-community supply to a domestic customer", invoiceData("PT", exemptLine("M16", 0)), false},
+	}
+	for _, tt := range tests {
+		err := ValidateInvoiceExemptions(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ValidateInvoiceExemptions() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestValidateWorkExemptions(t *testing.T) {
+	data := &types.WorkDataType{
+		WorkHeaderType: &types.WorkHeaderType{CustomerTaxIDCountry: ptr(types.CustomerTaxIDCountry("US"))},
+		LineSummary:    []types.LineSummary{exemptLine("M30", 0)},
+	}
+	if err := ValidateWorkExemptions(data); !errors.Is(err, common.ErrInvalidVatExemption) {
+		t.Errorf("ValidateWorkExemptions() = %v, want ErrInvalidVatExemption", err)
+	}
+	data.WorkHeaderType.CustomerTaxIDCountry = ptr(types.CustomerTaxIDCountry("PT"))
+	if err := ValidateWorkExemptions(data); err != nil {
+		t.Errorf("ValidateWorkExemptions() = %v", err)
+	}
+}
+
+type recordingCaller struct {
+	calls int
+}
+
+func (c *recordingCaller) CallContext(ctx context.Context, soapAction string, request, response interface{}) error {
+	c.calls++
+	return nil
+}
+
+func TestNewPortValidates(t *testing.T) {
+	caller := &recordingCaller{}
+	port := NewPort(caller)
+
+	bad := &types.RegisterInvoiceRequest{InvoiceData: invoiceData("PT", exemptLine("M03", 0))}
+	if _, err := port.RegisterInvoice(bad); !errors.Is(err, common.ErrInvalidVatExemption) {
+		t.Errorf("RegisterInvoice() = %v, want ErrInvalidVatExemption", err)
+	}
+	badWork := &types.RegisterWorkRequest{WorkData: &types.WorkDataType{LineSummary: []types.LineSummary{exemptLine("", 0)}}}
+	if _, err := port.RegisterWorkContext(context.Background(), badWork); err == nil {
+		t.Error("RegisterWorkContext() sent a line without exemption code")
+	}
+	if caller.calls != 0 {
+		t.Fatalf("%d calls sent invalid documents", caller.calls)
+	}
+
+	good := &types.RegisterInvoiceRequest{InvoiceData: invoiceData("PT", exemptLine("M07", 0))}
+	if _, err := port.RegisterInvoiceContext(context.Background(), good); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := port.DeleteInvoice(&types.DeleteInvoiceRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if caller.calls != 2 {
+		t.Errorf("%d calls, want 2", caller.calls)
+	}
+}
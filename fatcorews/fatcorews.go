@@ -1,13 +1,58 @@
 // Package fatcorews implements a client for the AT FatCore webservice
 // (e-Fatura — comunicação de documentos em tempo real).
 //
-// Use [types.NewFatcorewsPort] with a [soap.Client] configured with WS-Security
-// (see [security.Build]) and mutual TLS to call the service.
+// Use [NewPort] with a [soap.Client] configured with WS-Security (see
+// [soap.WithWSSecurity]) and mutual TLS to call the service.
 package fatcorews
 
+import (
+	"context"
+
+	"github.com/hestiatechnology/autoridadetributaria/fatcorews/types"
+	"github.com/hestiatechnology/autoridadetributaria/soap"
+)
+
 const (
 	// TestURL is the AT staging endpoint for invoice communication.
 	TestURL = "https://servicos.portaldasfinancas.gov.pt:723/fatcorews/ws/"
 	// ProdURL is the AT production endpoint for invoice communication.
 	ProdURL = "https://servicos.portaldasfinancas.gov.pt:423/fatcorews/ws/"
 )
+
+// NewPort returns a client of the service that checks the exemption codes of
+// the invoices and working documents it registers with
+// [ValidateInvoiceExemptions] and [ValidateWorkExemptions], and does not
+// send them if the check fails.
+func NewPort(client soap.Caller) types.FatcorewsPort {
+	return validatingPort{types.NewFatcorewsPort(client)}
+}
+
+type validatingPort struct {
+	types.FatcorewsPort
+}
+
+func (p validatingPort) RegisterInvoice(request *types.RegisterInvoiceRequest) (*types.RegisterInvoiceResponse, error) {
+	return p.RegisterInvoiceContext(context.Background(), request)
+}
+
+func (p validatingPort) RegisterInvoiceContext(ctx context.Context, request *types.RegisterInvoiceRequest) (*types.RegisterInvoiceResponse, error) {
+	if request != nil {
+		if err := ValidateInvoiceExemptions(request.InvoiceData); err != nil {
+			return nil, err
+		}
+	}
+	return p.FatcorewsPort.RegisterInvoiceContext(ctx, request)
+}
+
+func (p validatingPort) RegisterWork(request *types.RegisterWorkRequest) (*types.RegisterWorkResponse, error) {
+	return p.RegisterWorkContext(context.Background(), request)
+}
+
+func (p validatingPort) RegisterWorkContext(ctx context.Context, request *types.RegisterWorkRequest) (*types.RegisterWorkResponse, error) {
+	if request != nil {
+		if err := ValidateWorkExemptions(request.WorkData); err != nil {
+			return nil, err
+		}
+	}
+	return p.FatcorewsPort.RegisterWorkContext(ctx, request)
+}
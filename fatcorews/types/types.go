@@ -7,7 +7,7 @@ import (
 	"encoding/xml"
 	"time"
 
-	"github.com/hooklift/gowsdl/soap"
+	atsoap "github.com/hestiatechnology/autoridadetributaria/soap"
 	"github.com/shopspring/decimal"
 )
 
@@ -707,10 +707,10 @@ type FatcorewsPort interface {
 }
 
 type fatcorewsPort struct {
-	client *soap.Client
+	client atsoap.Caller
 }
 
-func NewFatcorewsPort(client *soap.Client) FatcorewsPort {
+func NewFatcorewsPort(client atsoap.Caller) FatcorewsPort {
 	return &fatcorewsPort{
 		client: client,
 	}
@@ -13,6 +13,7 @@ require (
 	github.com/hooklift/gowsdl v0.5.0
 	golang.org/x/crypto v0.38.0
 	golang.org/x/text v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/stretchr/testify v1.10.0 // indirect
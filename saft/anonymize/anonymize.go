@@ -0,0 +1,378 @@
+// Package anonymize replaces the personal data in a SAF-T audit file with
+// pseudonyms, so that files received from clients can be shared for
+// support or kept as test fixtures.
+//
+// Names, contacts, street addresses, emails, phone numbers, websites and
+// tax IDs of the company, customers and suppliers are replaced. Each value
+// gets the same pseudonym wherever it appears, and Portuguese NIFs are
+// replaced by fake NIFs of the same entity type with a valid check digit.
+// Record IDs, document numbers, amounts, dates, postal codes, cities and
+// countries are kept, so totals, references and tax region checks still
+// hold. Free text, such as descriptions and comments, is kept as is.
+package anonymize
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/signature"
+	"github.com/shopspring/decimal"
+)
+
+// Options configures an [Anonymizer].
+type Options struct {
+	// Key, if set, signs the document hashes again, so that the chains
+	// verify with its public key. The original hashes still verify with
+	// the producer's key, since they do not cover the replaced data.
+	Key *rsa.PrivateKey
+	// KeyVersion is the HashControl of the signed documents; "1" by
+	// default.
+	KeyVersion string
+}
+
+// Anonymizer remembers the pseudonyms it has given; anonymizing several
+// files with one Anonymizer replaces a value by the same pseudonym in all
+// of them.
+type Anonymizer struct {
+	opts   Options
+	values map[string]string
+	counts map[string]int
+}
+
+// New returns an Anonymizer.
+func New(opts Options) *Anonymizer {
+	if opts.KeyVersion == "" {
+		opts.KeyVersion = "1"
+	}
+	return &Anonymizer{opts: opts, values: map[string]string{}, counts: map[string]int{}}
+}
+
+// Anonymize replaces the personal data in a with a new Anonymizer.
+func Anonymize(a *saft.AuditFile, opts Options) error {
+	return New(opts).Anonymize(a)
+}
+
+// Anonymize replaces the personal data in a and, with [Options.Key], signs
+// its documents again.
+func (an *Anonymizer) Anonymize(a *saft.AuditFile) error {
+	h := &a.Header
+	if h.TaxRegistrationNumber != 0 {
+		nif := strconv.FormatUint(uint64(h.TaxRegistrationNumber), 10)
+		fake := an.taxID("PT", nif)
+		if n, err := strconv.ParseUint(fake, 10, 64); err == nil {
+			h.TaxRegistrationNumber = saft.SafptportugueseVatNumber(n)
+		}
+		// CompanyID is the NIF, or the registry office and the NIF.
+		h.CompanyId = strings.ReplaceAll(h.CompanyId, nif, fake)
+	}
+	h.CompanyName = replace(h.CompanyName, an.name("Empresa"))
+	h.BusinessName = replaceOptional(h.BusinessName, an.name("Empresa"))
+	an.address(&h.CompanyAddress.AddressDetail, h.CompanyAddress.StreetName)
+	h.Telephone = replaceOptional(h.Telephone, an.phone)
+	h.Fax = replaceOptional(h.Fax, an.phone)
+	h.Email = replaceOptional(h.Email, an.email)
+	h.Website = replaceOptional(h.Website, an.website)
+
+	for i := range a.MasterFiles.Customer {
+		c := &a.MasterFiles.Customer[i]
+		if common.IsFinalConsumer(string(c.CustomerTaxId)) {
+			continue
+		}
+		c.CustomerTaxId = replace(c.CustomerTaxId, func(s string) string {
+			return an.taxID(string(c.BillingAddress.Country), s)
+		})
+		c.CompanyName = replace(c.CompanyName, an.name("Cliente"))
+		c.Contact = replaceOptional(c.Contact, an.contact)
+		an.address(&c.BillingAddress.AddressDetail, c.BillingAddress.StreetName)
+		for j := range c.ShipToAddress {
+			an.address(&c.ShipToAddress[j].AddressDetail, c.ShipToAddress[j].StreetName)
+		}
+		c.Telephone = replaceOptional(c.Telephone, an.phone)
+		c.Fax = replaceOptional(c.Fax, an.phone)
+		c.Email = replaceOptional(c.Email, an.email)
+		c.Website = replaceOptional(c.Website, an.website)
+	}
+
+	for i := range a.MasterFiles.Supplier {
+		s := &a.MasterFiles.Supplier[i]
+		s.SupplierTaxId = replace(s.SupplierTaxId, func(id string) string {
+			return an.taxID(string(s.BillingAddress.Country), id)
+		})
+		s.CompanyName = replace(s.CompanyName, an.name("Fornecedor"))
+		s.Contact = replaceOptional(s.Contact, an.contact)
+		an.address(&s.BillingAddress.AddressDetail, s.BillingAddress.StreetName)
+		for j := range s.ShipFromAddress {
+			an.address(&s.ShipFromAddress[j].AddressDetail, s.ShipFromAddress[j].StreetName)
+		}
+		s.Telephone = replaceOptional(s.Telephone, an.phone)
+		s.Fax = replaceOptional(s.Fax, an.phone)
+		s.Email = replaceOptional(s.Email, an.email)
+		s.Website = replaceOptional(s.Website, an.website)
+	}
+
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			for i := range sd.SalesInvoices.Invoice {
+				inv := &sd.SalesInvoices.Invoice[i]
+				an.shippingPoint(inv.ShipTo)
+				an.shippingPoint(inv.ShipFrom)
+			}
+		}
+		if sd.MovementOfGoods != nil {
+			for i := range sd.MovementOfGoods.StockMovement {
+				m := &sd.MovementOfGoods.StockMovement[i]
+				an.shippingPoint(m.ShipTo)
+				an.shippingPoint(m.ShipFrom)
+			}
+		}
+	}
+
+	if an.opts.Key != nil {
+		return an.sign(a)
+	}
+	return nil
+}
+
+// pseudonym returns the pseudonym of value of the given kind, calling
+// next for a new one the first time value is seen. Empty and
+// "Desconhecido" values are kept.
+func (an *Anonymizer) pseudonym(kind, value string, next func() string) string {
+	v := common.NormalizeSpace(value)
+	if v == "" || common.IsUnknown(v) {
+		return value
+	}
+	key := kind + "\x00" + strings.ToUpper(v)
+	if p, ok := an.values[key]; ok {
+		return p
+	}
+	p := next()
+	an.values[key] = p
+	return p
+}
+
+// next returns the next number of counter, from 1.
+func (an *Anonymizer) next(counter string) int {
+	an.counts[counter]++
+	return an.counts[counter]
+}
+
+// name returns a function that replaces names by label and a number. A
+// name keeps the label it was first seen with, so a customer that is also
+// a supplier has one pseudonym.
+func (an *Anonymizer) name(label string) func(string) string {
+	return func(s string) string {
+		return an.pseudonym("name", s, func() string {
+			return fmt.Sprintf("%s %d", label, an.next(label))
+		})
+	}
+}
+
+func (an *Anonymizer) contact(s string) string {
+	return an.pseudonym("contact", s, func() string {
+		return fmt.Sprintf("Contacto %d", an.next("contact"))
+	})
+}
+
+func (an *Anonymizer) street(s string) string {
+	return an.pseudonym("street", s, func() string {
+		return fmt.Sprintf("Rua %d", an.next("street"))
+	})
+}
+
+func (an *Anonymizer) email(s string) string {
+	return an.pseudonym("email", s, func() string {
+		return fmt.Sprintf("contacto%d@example.com", an.next("email"))
+	})
+}
+
+// phone replaces phone and fax numbers by 9-digit numbers starting with 2.
+func (an *Anonymizer) phone(s string) string {
+	return an.pseudonym("phone", s, func() string {
+		return fmt.Sprintf("2%08d", an.next("phone"))
+	})
+}
+
+func (an *Anonymizer) website(s string) string {
+	return an.pseudonym("website", s, func() string {
+		return fmt.Sprintf("www.example%d.com", an.next("website"))
+	})
+}
+
+// taxID replaces a Portuguese NIF by a fake NIF of the same entity type,
+// and other tax IDs by one with the same letters and other digits. The
+// final consumer NIF is kept.
+func (an *Anonymizer) taxID(country, s string) string {
+	if common.IsFinalConsumer(s) {
+		return s
+	}
+	id := common.NormalizeTaxID(country, s)
+	return an.pseudonym("taxid", id, func() string {
+		n := an.next("taxid")
+		if nif, err := common.ParseNIF(id); err == nil {
+			return fakeNIF(nif.Number, n)
+		}
+		return fakeTaxID(country, id, n)
+	})
+}
+
+// fakeNIF returns the NIF with the type prefix of nif, n in the following
+// digits and a valid check digit.
+func fakeNIF(nif string, n int) string {
+	prefix := nif[:1]
+	// Types starting with 4, 7 and 9 have two-digit prefixes.
+	if strings.ContainsAny(prefix, "479") {
+		prefix = nif[:2]
+	}
+	s := fmt.Sprintf("%s%0*d", prefix, 8-len(prefix), n)
+	sum := 0
+	for i := range 8 {
+		sum += int(s[i]-'0') * (9 - i)
+	}
+	check := 0
+	if r := sum % 11; r > 1 {
+		check = 11 - r
+	}
+	return s + strconv.Itoa(check)
+}
+
+// fakeTaxID returns id with the digits of n. If id is a valid tax ID of
+// country, the last character is chosen so the result is valid too, which
+// covers the formats with a trailing check character.
+func fakeTaxID(country, id string, n int) string {
+	fake := fakeDigits(id, n)
+	if fake == "" || common.ValidateTaxID(country, id) != nil {
+		return fake
+	}
+	for _, c := range "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ" {
+		if s := fake[:len(fake)-1] + string(c); common.ValidateTaxID(country, s) == nil {
+			return s
+		}
+	}
+	return fake
+}
+
+// fakeDigits replaces the digits of s by those of n, padded with zeros.
+func fakeDigits(s string, n int) string {
+	count := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			count++
+		}
+	}
+	digits := fmt.Sprintf("%0*d", count, n)
+	digits = digits[len(digits)-count:]
+	b := []byte(s)
+	j := 0
+	for i, c := range b {
+		if c >= '0' && c <= '9' {
+			b[i] = digits[j]
+			j++
+		}
+	}
+	return string(b)
+}
+
+// address replaces the street of an address. The building number, city,
+// postal code and country are kept.
+func (an *Anonymizer) address(detail *saft.SafpttextTypeMandatoryMax210Car, street *saft.SafpttextTypeMandatoryMax200Car) {
+	*detail = replace(*detail, an.street)
+	if street != nil {
+		*street = replace(*street, an.street)
+	}
+}
+
+func (an *Anonymizer) shippingPoint(p *saft.ShippingPointStructure) {
+	if p == nil || p.Address == nil {
+		return
+	}
+	an.address(&p.Address.AddressDetail, p.Address.StreetName)
+}
+
+func replace[T ~string](v T, fn func(string) string) T {
+	return T(fn(string(v)))
+}
+
+func replaceOptional[T ~string](p *T, fn func(string) string) *T {
+	if p == nil {
+		return nil
+	}
+	v := replace(*p, fn)
+	return &v
+}
+
+type document struct {
+	number     string
+	date       time.Time
+	systemDate time.Time
+	grossTotal decimal.Decimal
+	hash       *saft.SafpttextTypeMandatoryMax172Car
+	control    *saft.SafpthashControl
+}
+
+// sign signs the documents of a again, chaining each to the one before it
+// in its series, as [check.VerifyHashes] reads them.
+func (an *Anonymizer) sign(a *saft.AuditFile) error {
+	sd := a.SourceDocuments
+	if sd == nil {
+		return nil
+	}
+	series := map[string][]document{}
+	add := func(d document) {
+		s, _ := splitNumber(d.number)
+		series[s] = append(series[s], d)
+	}
+	if sd.SalesInvoices != nil {
+		for i := range sd.SalesInvoices.Invoice {
+			d := &sd.SalesInvoices.Invoice[i]
+			add(document{d.InvoiceNo, d.InvoiceDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, &d.Hash, &d.HashControl})
+		}
+	}
+	if sd.MovementOfGoods != nil {
+		for i := range sd.MovementOfGoods.StockMovement {
+			d := &sd.MovementOfGoods.StockMovement[i]
+			add(document{d.DocumentNumber, d.MovementDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, &d.Hash, &d.HashControl})
+		}
+	}
+	if sd.WorkingDocuments != nil {
+		for i := range sd.WorkingDocuments.WorkDocument {
+			d := &sd.WorkingDocuments.WorkDocument[i]
+			add(document{d.DocumentNumber, d.WorkDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, &d.Hash, &d.HashControl})
+		}
+	}
+
+	for _, list := range series {
+		sort.SliceStable(list, func(i, j int) bool {
+			_, ni := splitNumber(list[i].number)
+			_, nj := splitNumber(list[j].number)
+			return ni < nj
+		})
+		previous := ""
+		for _, d := range list {
+			hash, err := signature.SignFiscalDocument(an.opts.Key, d.date, d.systemDate, d.number, d.grossTotal, previous)
+			if err != nil {
+				return fmt.Errorf("anonymize: signing %s: %w", d.number, err)
+			}
+			previous = string(hash)
+			*d.hash = saft.SafpttextTypeMandatoryMax172Car(hash)
+			*d.control = saft.SafpthashControl(an.opts.KeyVersion)
+		}
+	}
+	return nil
+}
+
+// splitNumber splits a document number into its series, "type series",
+// and its number.
+func splitNumber(number string) (string, int) {
+	i := strings.LastIndex(number, "/")
+	if i < 0 {
+		return number, 0
+	}
+	n, _ := strconv.Atoi(number[i+1:])
+	return number[:i], n
+}
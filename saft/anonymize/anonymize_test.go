@@ -0,0 +1,133 @@
+package anonymize
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/builder"
+	"github.com/hestiatechnology/autoridadetributaria/saft/check"
+	"github.com/shopspring/decimal"
+)
+
+func testFile(t *testing.T, key *rsa.PrivateKey) *saft.AuditFile {
+	t.Helper()
+	addr := common.Address{AddressDetail: "Rua do Comércio 1", City: "LISBOA", PostalCode: "1100148", Country: "pt"}
+	b := builder.New(
+		builder.Company{TaxID: 501442600, Name: "Empresa Real, Lda", Address: addr},
+		builder.Software{CompanyTaxID: "123456789", ProductID: "Faturador/Empresa", Version: "1.0", CertificateNumber: 9999},
+	).Sign(key, "1")
+	b.Customer(builder.Customer{ID: "C1", TaxID: "123456789", Name: "Maria Silva", Address: addr}).
+		Customer(builder.Customer{ID: "C2", TaxID: "501442600", Name: "Empresa Real, Lda", Address: addr}).
+		Customer(builder.FinalConsumer("CF")).
+		Product(builder.Product{Code: "P1", Description: "Parafuso"})
+	day := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	for i, no := range []string{"FT A/1", "FT A/2", "FS A/1"} {
+		customer := []string{"C1", "C2", "CF"}[i]
+		b.Invoice(builder.Invoice{No: no, Date: day, CustomerID: customer, SourceID: "admin"}).
+			Line(builder.Line{ProductCode: "P1", Quantity: decimal.NewFromInt(2), UnitPrice: decimal.RequireFromString("4.99")})
+	}
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAnonymize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testFile(t, key)
+	hash := a.SourceDocuments.SalesInvoices.Invoice[0].Hash
+
+	if err := Anonymize(a, Options{Key: testKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.Header
+	if h.CompanyName != "Empresa 1" || h.CompanyAddress.AddressDetail != "Rua 1" || h.CompanyAddress.City != "Lisboa" {
+		t.Errorf("Header = %s, %s, %s", h.CompanyName, h.CompanyAddress.AddressDetail, h.CompanyAddress.City)
+	}
+	nif := h.TaxRegistrationNumber
+	if nif == 501442600 || !common.ValidateNIFPT(h.CompanyId) || h.CompanyId[0] != '5' {
+		t.Errorf("TaxRegistrationNumber = %d, CompanyID = %s, want a fake NIF starting with 5", nif, h.CompanyId)
+	}
+
+	c := a.MasterFiles.Customer
+	if c[0].CompanyName != "Cliente 1" || c[0].CustomerTaxId == "123456789" || !common.ValidateNIFPT(string(c[0].CustomerTaxId)) {
+		t.Errorf("Customer C1 = %s, %s", c[0].CompanyName, c[0].CustomerTaxId)
+	}
+	// The company is also a customer.
+	if c[1].CompanyName != h.CompanyName || c[1].CustomerTaxId != saft.SafpttextTypeMandatoryMax30Car(h.CompanyId) {
+		t.Errorf("Customer C2 = %s, %s, want %s, %s", c[1].CompanyName, c[1].CustomerTaxId, h.CompanyName, h.CompanyId)
+	}
+	if c[2].CustomerTaxId != common.FinalConsumerNIF || c[2].CompanyName != "Consumidor final" {
+		t.Errorf("final consumer = %s, %s", c[2].CompanyName, c[2].CustomerTaxId)
+	}
+
+	inv := a.SourceDocuments.SalesInvoices.Invoice[0]
+	if inv.CustomerId != "C1" || inv.DocumentTotals.GrossTotal.String() != "12.28" || inv.Hash == hash {
+		t.Errorf("invoice = %s, %s, %.10s", inv.CustomerId, inv.DocumentTotals.GrossTotal, inv.Hash)
+	}
+	if r := check.VerifyHashes(a, &testKey.PublicKey); r.Verified != 3 || len(r.Findings) > 0 {
+		t.Errorf("VerifyHashes() = %d verified, findings %v", r.Verified, r.Findings)
+	}
+	if findings := check.Validate(a); len(findings) > 0 {
+		t.Errorf("Validate() = %v", findings)
+	}
+}
+
+func TestAnonymizerConsistent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := testFile(t, key), testFile(t, key)
+	// The second file lists its customers in another order.
+	b.MasterFiles.Customer[0], b.MasterFiles.Customer[1] = b.MasterFiles.Customer[1], b.MasterFiles.Customer[0]
+
+	an := New(Options{})
+	if err := an.Anonymize(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := an.Anonymize(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.TaxRegistrationNumber != b.Header.TaxRegistrationNumber {
+		t.Errorf("company NIF %d and %d", a.Header.TaxRegistrationNumber, b.Header.TaxRegistrationNumber)
+	}
+	if ca, cb := a.MasterFiles.Customer[0], b.MasterFiles.Customer[1]; ca.CompanyName != cb.CompanyName || ca.CustomerTaxId != cb.CustomerTaxId {
+		t.Errorf("customer C1 = %s %s and %s %s", ca.CompanyName, ca.CustomerTaxId, cb.CompanyName, cb.CustomerTaxId)
+	}
+	// Without a key the hashes are kept.
+	if r := check.VerifyHashes(a, &key.PublicKey); r.Verified != 3 || len(r.Findings) > 0 {
+		t.Errorf("VerifyHashes() = %d verified, findings %v", r.Verified, r.Findings)
+	}
+}
+
+func TestFakeNIF(t *testing.T) {
+	for nif, prefix := range map[string]string{"123456789": "1", "501442600": "5", "980000000": "98", "450000000": "45"} {
+		for n := 1; n < 200; n++ {
+			fake := fakeNIF(nif, n)
+			if _, err := common.ParseNIF(fake); err != nil || !strings.HasPrefix(fake, prefix) {
+				t.Fatalf("fakeNIF(%s, %d) = %s: %v", nif, n, fake, err)
+			}
+		}
+	}
+	if got := fakeDigits("B-12345678", 42); got != "B-00000042" {
+		t.Errorf("fakeDigits() = %s", got)
+	}
+	if got := fakeTaxID("ES", "A58818501", 3); !strings.HasPrefix(got, "A0000000") || common.ValidateTaxID("ES", got) != nil {
+		t.Errorf("fakeTaxID() = %s, want a valid Spanish tax ID", got)
+	}
+}
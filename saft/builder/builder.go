@@ -0,0 +1,618 @@
+// Package builder constructs SAF-T audit files from plain Go values.
+//
+// Callers add customers, products and invoices; the builder fills in the
+// SAF-T wrapper types, computes the line and document totals, the tax table
+// and the control totals, and validates the result:
+//
+//	b := builder.New(company, software)
+//	b.Customer(builder.Customer{ID: "C1", TaxID: "123456789", Name: "Cliente, Lda", Address: addr})
+//	b.Product(builder.Product{Code: "P1", Description: "Parafuso"})
+//	b.Invoice(builder.Invoice{No: "FT A/1", Date: day, CustomerID: "C1", SourceID: "admin"}).
+//		Line(builder.Line{ProductCode: "P1", Quantity: decimal.NewFromInt(2), UnitPrice: decimal.RequireFromString("1.50")})
+//	a, err := b.Build()
+package builder
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/check"
+	"github.com/hestiatechnology/autoridadetributaria/saft/signature"
+	"github.com/shopspring/decimal"
+)
+
+// Header defaults.
+const (
+	AuditFileVersion = "1.04_01"
+	CurrencyCode     = "EUR"
+)
+
+// Company is the taxpayer the file belongs to.
+type Company struct {
+	// TaxID is the Portuguese NIF.
+	TaxID uint64
+	// CompanyID is the commercial registry office and number; the NIF by
+	// default.
+	CompanyID string
+	Name      string
+	Address   common.Address
+	// TaxEntity is the establishment the file covers; "Global" by default.
+	TaxEntity string
+}
+
+// Software is the certified invoicing program that produces the file.
+type Software struct {
+	// CompanyTaxID is the NIF of the software producer.
+	CompanyTaxID string
+	// ProductID is "product name/producer name".
+	ProductID         string
+	Version           string
+	CertificateNumber uint64
+}
+
+// Customer is a customer; the address is normalized.
+type Customer struct {
+	ID    string
+	TaxID string
+	Name  string
+	// AccountID is the customer's general ledger account; "Desconhecido"
+	// by default.
+	AccountID   string
+	Address     common.Address
+	SelfBilling bool
+}
+
+// FinalConsumer returns the customer of sales to unidentified final
+// consumers, with the NIF 999999990 and unknown address.
+func FinalConsumer(id string) Customer {
+	return Customer{
+		ID:    id,
+		TaxID: common.FinalConsumerNIF,
+		Name:  "Consumidor final",
+		Address: common.Address{
+			AddressDetail: common.Unknown,
+			City:          common.Unknown,
+			PostalCode:    common.Unknown,
+			Country:       common.Unknown,
+		},
+	}
+}
+
+// Product is a product or service.
+type Product struct {
+	Code        string
+	Description string
+	// Type is P (product), S (service), O, E or I; P by default.
+	Type  string
+	Group string
+	// NumberCode is the EAN or other code; the Code by default.
+	NumberCode string
+}
+
+// Invoice is the header of a sales invoice.
+type Invoice struct {
+	// No is the invoice number, "type series/number", e.g. "FT A/1".
+	No string
+	// ATCUD is the unique document code; "0" by default.
+	ATCUD string
+	// Type is FT, FS, FR, ND or NC; the type in No by default.
+	Type string
+	Date time.Time
+	// SystemEntryDate is when the invoice was recorded; Date by default.
+	SystemEntryDate time.Time
+	CustomerID      string
+	// SourceID is the user who issued the invoice.
+	SourceID string
+	// Status is N (normal) by default, or A (cancelled) or F (invoiced).
+	Status string
+}
+
+// Line is an invoice line. Amounts are rounded to cents.
+type Line struct {
+	ProductCode string
+	// Description defaults to the product description.
+	Description string
+	Quantity    decimal.Decimal
+	// UnitOfMeasure is "UN" by default.
+	UnitOfMeasure string
+	UnitPrice     decimal.Decimal
+	// TaxCode is RED, INT, NOR, ISE or OUT; NOR by default.
+	TaxCode string
+	// TaxRegion is PT, PT-AC or PT-MA; PT by default.
+	TaxRegion string
+	// TaxPercentage defaults to the rate of TaxCode in TaxRegion on the
+	// invoice date, or 0 for ISE.
+	TaxPercentage *decimal.Decimal
+	// ExemptionCode is the M code of exempt lines; the reason is filled in
+	// from [common.VatExemptionRules].
+	ExemptionCode string
+	// Reference is the document a credit or debit note corrects.
+	Reference string
+}
+
+// Builder accumulates the contents of an audit file. Its methods record
+// errors instead of returning them; [Builder.Build] reports them all.
+type Builder struct {
+	a        *saft.AuditFile
+	start    time.Time
+	end      time.Time
+	key      *rsa.PrivateKey
+	keyID    string
+	products map[string]*saft.Product
+	invoices []*InvoiceBuilder
+	errs     []error
+}
+
+// InvoiceBuilder adds lines to an invoice.
+type InvoiceBuilder struct {
+	b       *Builder
+	invoice Invoice
+	lines   []Line
+}
+
+// New returns a builder of a SAF-T invoicing file (TaxAccountingBasis F)
+// for company, produced by software.
+func New(company Company, software Software) *Builder {
+	companyID := company.CompanyID
+	if companyID == "" {
+		companyID = strconv.FormatUint(company.TaxID, 10)
+	}
+	taxEntity := company.TaxEntity
+	if taxEntity == "" {
+		taxEntity = saft.GlobalTaxEntity
+	}
+
+	addr := company.Address.Normalize()
+	if addr.Country == "" {
+		addr.Country = "PT"
+	}
+
+	return &Builder{
+		a: &saft.AuditFile{
+			Header: saft.Header{
+				AuditFileVersion:          AuditFileVersion,
+				CompanyId:                 companyID,
+				TaxRegistrationNumber:     saft.SafptportugueseVatNumber(company.TaxID),
+				TaxAccountingBasis:        saft.SaftInvoicing,
+				CompanyName:               saft.SafpttextTypeMandatoryMax100Car(company.Name),
+				CompanyAddress:            addressStructure(addr),
+				CurrencyCode:              CurrencyCode,
+				DateCreated:               saft.SafptdateSpan(day(time.Now())),
+				TaxEntity:                 saft.SafpttextTypeMandatoryMax20Car(taxEntity),
+				ProductCompanyTaxId:       saft.SafpttextTypeMandatoryMax30Car(software.CompanyTaxID),
+				SoftwareCertificateNumber: software.CertificateNumber,
+				ProductId:                 saft.SafptproductId(software.ProductID),
+				ProductVersion:            saft.SafpttextTypeMandatoryMax30Car(software.Version),
+			},
+			SourceDocuments: &saft.SourceDocuments{
+				SalesInvoices: &saft.SourceDocumentsSalesInvoices{},
+			},
+		},
+		products: map[string]*saft.Product{},
+	}
+}
+
+// Period sets the Header StartDate and EndDate; invoices dated outside
+// them are an error. By default they span the invoice dates.
+func (b *Builder) Period(start, end time.Time) *Builder {
+	b.start, b.end = day(start), day(end)
+	return b
+}
+
+// TaxAccountingBasis overrides the default F (invoicing).
+func (b *Builder) TaxAccountingBasis(basis string) *Builder {
+	b.a.Header.TaxAccountingBasis = basis
+	return b
+}
+
+// Sign signs the invoices with the software's private key, chaining the
+// hashes of each series in the order the invoices were added. keyVersion
+// is the HashControl, the version of the key. Unsigned invoices have the
+// Hash and HashControl "0".
+func (b *Builder) Sign(key *rsa.PrivateKey, keyVersion string) *Builder {
+	b.key, b.keyID = key, keyVersion
+	return b
+}
+
+// Customer adds a customer.
+func (b *Builder) Customer(c Customer) *Builder {
+	if c.ID == "" {
+		b.errs = append(b.errs, errors.New("builder: customer without ID"))
+		return b
+	}
+	account := c.AccountID
+	if account == "" {
+		account = common.Unknown
+	}
+	var selfBilling uint32
+	if c.SelfBilling {
+		selfBilling = saft.IndicatorYes
+	}
+
+	addr := c.Address.Normalize()
+	b.a.MasterFiles.Customer = append(b.a.MasterFiles.Customer, saft.Customer{
+		CustomerId:           saft.SafpttextTypeMandatoryMax30Car(c.ID),
+		AccountId:            account,
+		CustomerTaxId:        saft.SafpttextTypeMandatoryMax30Car(common.NormalizeTaxID(addr.Country, c.TaxID)),
+		CompanyName:          saft.SafpttextTypeMandatoryMax100Car(c.Name),
+		BillingAddress:       customerAddress(addr),
+		SelfBillingIndicator: selfBilling,
+	})
+	return b
+}
+
+// Product adds a product.
+func (b *Builder) Product(p Product) *Builder {
+	if p.Code == "" {
+		b.errs = append(b.errs, errors.New("builder: product without code"))
+		return b
+	}
+	productType := p.Type
+	if productType == "" {
+		productType = "P"
+	}
+	numberCode := p.NumberCode
+	if numberCode == "" {
+		numberCode = p.Code
+	}
+
+	b.a.MasterFiles.Product = append(b.a.MasterFiles.Product, saft.Product{
+		ProductType:        productType,
+		ProductCode:        saft.SafpttextTypeMandatoryMax60Car(p.Code),
+		ProductGroup:       optional[saft.SafpttextTypeMandatoryMax50Car](p.Group),
+		ProductDescription: saft.SafptproductDescription(p.Description),
+		ProductNumberCode:  saft.SafpttextTypeMandatoryMax60Car(numberCode),
+	})
+	return b
+}
+
+// Invoice starts an invoice; add its lines with [InvoiceBuilder.Line].
+func (b *Builder) Invoice(inv Invoice) *InvoiceBuilder {
+	ib := &InvoiceBuilder{b: b, invoice: inv}
+	b.invoices = append(b.invoices, ib)
+	return ib
+}
+
+// Line adds a line to the invoice.
+func (ib *InvoiceBuilder) Line(l Line) *InvoiceBuilder {
+	ib.lines = append(ib.lines, l)
+	return ib
+}
+
+// Invoice starts the next invoice.
+func (ib *InvoiceBuilder) Invoice(inv Invoice) *InvoiceBuilder {
+	return ib.b.Invoice(inv)
+}
+
+// Build computes the invoices, the tax table and the totals and returns the
+// file if it passes every check of [check.Validate].
+//
+// Every call builds a new file from everything added so far, so Build may
+// be called again after adding more invoices; the files returned earlier
+// are not modified.
+func (b *Builder) Build() (*saft.AuditFile, error) {
+	a := b.file()
+	b.products = map[string]*saft.Product{}
+	for i := range a.MasterFiles.Product {
+		p := &a.MasterFiles.Product[i]
+		b.products[string(p.ProductCode)] = p
+	}
+
+	errs := slices.Clone(b.errs)
+	start, end := b.start, b.end
+	explicit := !start.IsZero()
+	taxes := &taxTable{}
+	hashes := map[string]string{}
+	for _, ib := range b.invoices {
+		inv, err := b.invoice(ib, taxes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		date := inv.InvoiceDate.Time
+		if explicit && (date.Before(start) || date.After(end)) {
+			errs = append(errs, fmt.Errorf("builder: invoice %s dated %s is outside the period %s to %s",
+				inv.InvoiceNo, date.Format(time.DateOnly), start.Format(time.DateOnly), end.Format(time.DateOnly)))
+			continue
+		}
+		if err := b.sign(inv, hashes); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		a.SourceDocuments.SalesInvoices.Invoice = append(a.SourceDocuments.SalesInvoices.Invoice, *inv)
+		start, end = span(start, end, date)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if start.IsZero() {
+		return nil, errors.New("builder: no period and no invoices")
+	}
+	a.Header.StartDate = saft.SafptdateSpan(start)
+	a.Header.EndDate = saft.SafptdateSpan(end)
+	a.Header.FiscalYear = strconv.Itoa(start.Year())
+	if len(taxes.entries) > 0 {
+		a.MasterFiles.TaxTable = &saft.TaxTable{TaxTableEntry: taxes.entries}
+	}
+	a.RecomputeTotals()
+
+	for _, f := range check.Validate(a) {
+		errs = append(errs, fmt.Errorf("builder: %s: %w", f.Check, f.Err))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return a, nil
+}
+
+// file returns a new file with the header and master files added so far.
+func (b *Builder) file() *saft.AuditFile {
+	a := *b.a
+	a.MasterFiles.Customer = slices.Clone(b.a.MasterFiles.Customer)
+	a.MasterFiles.Product = slices.Clone(b.a.MasterFiles.Product)
+	a.SourceDocuments = &saft.SourceDocuments{
+		SalesInvoices: &saft.SourceDocumentsSalesInvoices{},
+	}
+	return &a
+}
+
+// invoice computes the SAF-T invoice of ib.
+func (b *Builder) invoice(ib *InvoiceBuilder, taxes *taxTable) (*saft.SalesInvoicesInvoice, error) {
+	in := ib.invoice
+	if in.No == "" {
+		return nil, errors.New("builder: invoice without number")
+	}
+	if in.Date.IsZero() {
+		return nil, fmt.Errorf("builder: invoice %s without date", in.No)
+	}
+	if len(ib.lines) == 0 {
+		return nil, fmt.Errorf("builder: invoice %s without lines", in.No)
+	}
+
+	invoiceType := in.Type
+	if invoiceType == "" {
+		invoiceType, _, _ = strings.Cut(in.No, " ")
+	}
+	// Credit notes debit the sales accounts.
+	credit := invoiceType != "NC"
+	atcud := in.ATCUD
+	if atcud == "" {
+		atcud = "0"
+	}
+	entry := in.SystemEntryDate
+	if entry.IsZero() {
+		entry = in.Date
+	}
+	status := in.Status
+	if status == "" {
+		status = "N"
+	}
+
+	inv := &saft.SalesInvoicesInvoice{
+		InvoiceNo: in.No,
+		Atcud:     saft.SafpttextTypeMandatoryMax100Car(atcud),
+		DocumentStatus: saft.InvoiceDocumentStatus{
+			InvoiceStatus:     status,
+			InvoiceStatusDate: saft.SafdateTimeType(entry),
+			SourceId:          saft.SafpttextTypeMandatoryMax30Car(in.SourceID),
+			SourceBilling:     saft.SaftptsourceBillingP,
+		},
+		Hash:            "0",
+		HashControl:     "0",
+		InvoiceDate:     saft.SafdateType{Time: day(in.Date)},
+		InvoiceType:     invoiceType,
+		SourceId:        saft.SafpttextTypeMandatoryMax30Car(in.SourceID),
+		SystemEntryDate: saft.SafdateTimeType(entry),
+		CustomerId:      saft.SafpttextTypeMandatoryMax30Car(in.CustomerID),
+	}
+
+	var net, tax decimal.Decimal
+	for i, l := range ib.lines {
+		line, err := b.line(in, l, credit, taxes)
+		if err != nil {
+			return nil, fmt.Errorf("builder: invoice %s line %d: %w", in.No, i+1, err)
+		}
+		line.LineNumber = uint64(i + 1)
+
+		amount := amountOf(line)
+		net = net.Add(amount)
+		tax = tax.Add(amount.Mul(line.Tax.TaxPercentage.Decimal).Div(decimal.NewFromInt(100)))
+		inv.Line = append(inv.Line, *line)
+	}
+
+	tax = tax.Round(2)
+	inv.DocumentTotals = saft.InvoiceDocumentTotals{
+		TaxPayable: saft.SafmonetaryType{Decimal: tax},
+		NetTotal:   saft.SafmonetaryType{Decimal: net},
+		GrossTotal: saft.SafmonetaryType{Decimal: net.Add(tax)},
+	}
+	return inv, nil
+}
+
+func (b *Builder) line(in Invoice, l Line, credit bool, taxes *taxTable) (*saft.InvoiceLine, error) {
+	p, ok := b.products[l.ProductCode]
+	if !ok {
+		return nil, fmt.Errorf("unknown product %s", l.ProductCode)
+	}
+	description := l.Description
+	if description == "" {
+		description = string(p.ProductDescription)
+	}
+	unit := l.UnitOfMeasure
+	if unit == "" {
+		unit = "UN"
+	}
+	code := l.TaxCode
+	if code == "" {
+		code = common.VATNormal
+	}
+	region := l.TaxRegion
+	if region == "" {
+		region = common.RegionMainland
+	}
+
+	var pct decimal.Decimal
+	switch {
+	case l.TaxPercentage != nil:
+		pct = *l.TaxPercentage
+	case code != common.VATExempt:
+		var err error
+		if pct, err = common.VATRateAt(region, code, in.Date); err != nil {
+			return nil, err
+		}
+	}
+
+	amount := saft.SafmonetaryType{Decimal: l.Quantity.Mul(l.UnitPrice).Round(2)}
+	line := &saft.InvoiceLine{
+		ProductCode:        saft.SafpttextTypeMandatoryMax60Car(l.ProductCode),
+		ProductDescription: p.ProductDescription,
+		Quantity:           saft.SafdecimalType{Decimal: l.Quantity},
+		UnitOfMeasure:      saft.SafpttextTypeMandatoryMax20Car(unit),
+		UnitPrice:          saft.SafmonetaryType{Decimal: l.UnitPrice},
+		TaxPointDate:       saft.SafdateType{Time: day(in.Date)},
+		Description:        saft.SafpttextTypeMandatoryMax200Car(description),
+		Tax: saft.Tax{
+			TaxType:          saft.TaxTypeIVA,
+			TaxCountryRegion: region,
+			TaxCode:          code,
+			TaxPercentage:    &saft.SafdecimalType{Decimal: pct},
+		},
+	}
+	if credit {
+		line.CreditAmount = &amount
+	} else {
+		line.DebitAmount = &amount
+	}
+	if l.Reference != "" {
+		line.References = []saft.References{{Reference: optional[saft.SafpttextTypeMandatoryMax60Car](l.Reference)}}
+	}
+
+	if l.ExemptionCode != "" {
+		c, rule, ok := common.LookupVatExemption(l.ExemptionCode)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown code %s", common.ErrInvalidVatExemption, l.ExemptionCode)
+		}
+		reason := rule.InvoiceText
+		if reason == "" {
+			reason = c.Description
+		}
+		line.TaxExemptionCode = optional[saft.SafptportugueseTaxExemptionCode](l.ExemptionCode)
+		line.TaxExemptionReason = optional[saft.SafptportugueseTaxExemptionReason](reason)
+	}
+
+	taxes.add(region, code, pct)
+	return line, nil
+}
+
+// sign sets the Hash of inv, chaining it to the previous invoice of its
+// series in hashes.
+func (b *Builder) sign(inv *saft.SalesInvoicesInvoice, hashes map[string]string) error {
+	if b.key == nil {
+		return nil
+	}
+	series := inv.InvoiceNo
+	if i := strings.LastIndex(series, "/"); i >= 0 {
+		series = series[:i]
+	}
+	hash, err := signature.SignFiscalDocument(b.key, inv.InvoiceDate.Time, time.Time(inv.SystemEntryDate), inv.InvoiceNo, inv.DocumentTotals.GrossTotal.Decimal, hashes[series])
+	if err != nil {
+		return fmt.Errorf("builder: signing %s: %w", inv.InvoiceNo, err)
+	}
+	hashes[series] = string(hash)
+	inv.Hash = saft.SafpttextTypeMandatoryMax172Car(hash)
+	inv.HashControl = saft.SafpthashControl(b.keyID)
+	return nil
+}
+
+// span widens the period from start to end to include date.
+func span(start, end, date time.Time) (time.Time, time.Time) {
+	date = day(date)
+	if start.IsZero() || date.Before(start) {
+		start = date
+	}
+	if end.IsZero() || date.After(end) {
+		end = date
+	}
+	return start, end
+}
+
+// taxTable collects one entry per VAT region, code and percentage used.
+type taxTable struct {
+	entries []saft.TaxTableEntry
+}
+
+var taxDescriptions = map[string]string{
+	common.VATReduced:      "Taxa Reduzida",
+	common.VATIntermediate: "Taxa Intermédia",
+	common.VATNormal:       "Taxa Normal",
+	common.VATExempt:       "Isenta",
+	common.VATOther:        "Outros",
+}
+
+func (t *taxTable) add(region, code string, pct decimal.Decimal) {
+	for _, e := range t.entries {
+		if e.TaxCountryRegion == region && string(e.TaxCode) == code && e.TaxPercentage.Equal(pct) {
+			return
+		}
+	}
+	t.entries = append(t.entries, saft.TaxTableEntry{
+		TaxType:          saft.TaxTypeIVA,
+		TaxCountryRegion: region,
+		TaxCode:          saft.TaxTableEntryTaxCode(code),
+		Description:      saft.SafpttextTypeMandatoryMax255Car(taxDescriptions[code]),
+		TaxPercentage:    &saft.SafdecimalType{Decimal: pct},
+	})
+}
+
+func amountOf(l *saft.InvoiceLine) decimal.Decimal {
+	if l.CreditAmount != nil {
+		return l.CreditAmount.Decimal
+	}
+	return l.DebitAmount.Decimal
+}
+
+func addressStructure(a common.Address) saft.AddressStructure {
+	return saft.AddressStructure{
+		BuildingNumber: optional[saft.SafpttextTypeMandatoryMax10Car](a.BuildingNumber),
+		StreetName:     optional[saft.SafpttextTypeMandatoryMax200Car](a.StreetName),
+		AddressDetail:  saft.SafpttextTypeMandatoryMax210Car(a.AddressDetail),
+		City:           saft.SafpttextTypeMandatoryMax50Car(a.City),
+		PostalCode:     saft.SafpttextTypeMandatoryMax20Car(a.PostalCode),
+		Region:         optional[saft.SafpttextTypeMandatoryMax50Car](a.Region),
+		Country:        a.Country,
+	}
+}
+
+func customerAddress(a common.Address) saft.CustomerAddressStructure {
+	s := addressStructure(a)
+	return saft.CustomerAddressStructure{
+		BuildingNumber: s.BuildingNumber,
+		StreetName:     s.StreetName,
+		AddressDetail:  s.AddressDetail,
+		City:           s.City,
+		PostalCode:     s.PostalCode,
+		Region:         s.Region,
+		Country:        saft.CustomerCountry(a.Country),
+	}
+}
+
+// optional returns nil for an empty s, for optional elements.
+func optional[T ~string](s string) *T {
+	if s == "" {
+		return nil
+	}
+	v := T(s)
+	return &v
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
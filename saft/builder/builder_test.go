@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft/check"
+	"github.com/shopspring/decimal"
+)
+
+func testBuilder() *Builder {
+	addr := common.Address{AddressDetail: "Rua do Comércio 1", City: "LISBOA", PostalCode: "1100148", Country: "pt"}
+	b := New(
+		Company{TaxID: 123456789, Name: "Empresa, Lda", Address: addr},
+		Software{CompanyTaxID: "123456789", ProductID: "Faturador/Empresa", Version: "1.0", CertificateNumber: 9999},
+	)
+	b.Customer(Customer{ID: "C1", TaxID: "123456789", Name: "Cliente, Lda", Address: addr}).
+		Customer(FinalConsumer("CF")).
+		Product(Product{Code: "P1", Description: "Parafuso"}).
+		Product(Product{Code: "S1", Description: "Montagem", Type: "S"})
+	return b
+}
+
+func TestBuild(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	b := testBuilder()
+	b.Invoice(Invoice{No: "FT A/1", Date: day, CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(3), UnitPrice: decimal.RequireFromString("1.99")}).
+		Line(Line{ProductCode: "S1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10), TaxCode: common.VATExempt, ExemptionCode: "M07"}).
+		Invoice(Invoice{No: "FS A/1", Date: day.AddDate(0, 0, 2), CustomerID: "CF", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(100), TaxCode: common.VATReduced})
+
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := a.Header
+	if h.AuditFileVersion != AuditFileVersion || h.CurrencyCode != CurrencyCode || h.FiscalYear != "2024" {
+		t.Errorf("Header = %s %s %s", h.AuditFileVersion, h.CurrencyCode, h.FiscalYear)
+	}
+	if start, end := time.Time(h.StartDate), time.Time(h.EndDate); !start.Equal(day) || end.Day() != 7 {
+		t.Errorf("period = %s to %s", start, end)
+	}
+
+	inv := a.SourceDocuments.SalesInvoices.Invoice[0]
+	// 5.97 at 23% and 10.00 exempt.
+	if tot := inv.DocumentTotals; tot.NetTotal.String() != "15.97" || tot.TaxPayable.String() != "1.37" || tot.GrossTotal.String() != "17.34" {
+		t.Errorf("totals = %s + %s = %s, want 15.97 + 1.37 = 17.34", tot.NetTotal, tot.TaxPayable, tot.GrossTotal)
+	}
+	if inv.Line[1].TaxExemptionReason == nil {
+		t.Error("exempt line without TaxExemptionReason")
+	}
+	if n := len(a.MasterFiles.TaxTable.TaxTableEntry); n != 3 {
+		t.Errorf("%d tax table entries, want 3", n)
+	}
+	if s := a.SourceDocuments.SalesInvoices; s.NumberOfEntries != 2 || s.TotalCredit.String() != "115.97" {
+		t.Errorf("NumberOfEntries = %d, TotalCredit = %s, want 2 and 115.97", s.NumberOfEntries, s.TotalCredit)
+	}
+}
+
+func TestBuildSigned(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	b := testBuilder().Sign(key, "1")
+	for _, no := range []string{"FT A/1", "FT A/2", "FT B/1"} {
+		b.Invoice(Invoice{No: no, Date: day, CustomerID: "C1", SourceID: "admin"}).
+			Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(5)})
+	}
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := check.VerifyHashes(a, &key.PublicKey)
+	if r.Verified != 3 || len(r.Findings) > 0 {
+		t.Errorf("VerifyHashes() = %d verified, findings %v", r.Verified, r.Findings)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	b := testBuilder()
+	b.Invoice(Invoice{No: "FT A/1", Date: day, CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "X", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(1)}).
+		Invoice(Invoice{No: "FT A/2", Date: day, CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(1), TaxCode: common.VATExempt, ExemptionCode: "M00"})
+
+	_, err := b.Build()
+	if err == nil {
+		t.Fatal("Build() succeeded with an unknown product and exemption code")
+	}
+	if !errors.Is(err, common.ErrInvalidVatExemption) {
+		t.Errorf("Build() error = %v, want it to include ErrInvalidVatExemption", err)
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("%d errors, want 2: %v", n, err)
+	}
+}
+
+func TestBuildSplitMonthly(t *testing.T) {
+	b := testBuilder().Period(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC))
+	for i, day := range []time.Time{
+		time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		b.Invoice(Invoice{No: []string{"FT A/1", "FT A/2", "FS A/1"}[i], Date: day, CustomerID: []string{"C1", "C1", "CF"}[i], SourceID: "admin"}).
+			Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10)})
+	}
+	a, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := a.SplitMonthly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("%d files, want 3", len(files))
+	}
+	for i, f := range files {
+		if findings := check.Validate(f); len(findings) > 0 {
+			t.Errorf("month %d: Validate() = %v", i+1, findings)
+		}
+	}
+	if n := files[1].SourceDocuments.SalesInvoices.NumberOfEntries; n != 1 || len(files[1].MasterFiles.Customer) != 1 {
+		t.Errorf("February: %d invoices, customers %+v, want FS A/1 to CF", n, files[1].MasterFiles.Customer)
+	}
+}
+
+func TestBuildTwice(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	b := testBuilder()
+	b.Invoice(Invoice{No: "FT A/1", Date: day, CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10)})
+	first, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == first || len(again.SourceDocuments.SalesInvoices.Invoice) != 1 {
+		t.Errorf("second Build() = %d invoices, same file %v; want a new file with 1 invoice", len(again.SourceDocuments.SalesInvoices.Invoice), again == first)
+	}
+
+	b.Invoice(Invoice{No: "FT A/2", Date: day.AddDate(0, 0, 1), CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10)})
+	b.Customer(FinalConsumer("C2"))
+	more, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(more.SourceDocuments.SalesInvoices.Invoice); n != 2 || time.Time(more.Header.EndDate).Day() != 6 {
+		t.Errorf("third Build() = %d invoices to %s, want 2 to March 6", n, time.Time(more.Header.EndDate))
+	}
+	if n, c := len(first.SourceDocuments.SalesInvoices.Invoice), len(first.MasterFiles.Customer); n != 1 || c != 2 || time.Time(first.Header.EndDate).Day() != 5 {
+		t.Errorf("first file changed: %d invoices, %d customers", n, c)
+	}
+}
+
+func TestBuildOutsidePeriod(t *testing.T) {
+	b := testBuilder().Period(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC))
+	b.Invoice(Invoice{No: "FT A/1", Date: time.Date(2024, time.March, 31, 18, 0, 0, 0, time.UTC), CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10)}).
+		Invoice(Invoice{No: "FT A/2", Date: time.Date(2024, time.April, 1, 9, 0, 0, 0, time.UTC), CustomerID: "C1", SourceID: "admin"}).
+		Line(Line{ProductCode: "P1", Quantity: decimal.NewFromInt(1), UnitPrice: decimal.NewFromInt(10)})
+
+	_, err := b.Build()
+	if err == nil {
+		t.Fatal("Build() accepted an invoice dated after the period")
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 1 || !strings.Contains(err.Error(), "FT A/2") {
+		t.Errorf("Build() error = %v, want one error about FT A/2", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package check runs every SAF-T validation on an audit file and reports all
+// problems found, instead of stopping at the first one, and verifies the
+// document hash chains.
+package check
+
+import (
+	"crypto/rsa"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/internal/validation"
+	"github.com/hestiatechnology/autoridadetributaria/saft/internal/validation/masterfiles"
+	sourcedocuments "github.com/hestiatechnology/autoridadetributaria/saft/internal/validation/sourcedocuments"
+	"github.com/hestiatechnology/autoridadetributaria/saft/signature"
+	"github.com/shopspring/decimal"
+)
+
+// Finding is a problem reported by one check.
+type Finding struct {
+	Check string
+	Err   error
+}
+
+func (f Finding) String() string {
+	return f.Check + ": " + f.Err.Error()
+}
+
+var checks = []struct {
+	name string
+	fn   func(*saft.AuditFile) error
+}{
+	{"Header", validation.ValidateHeader},
+	{"Profile", (*saft.AuditFile).CheckProfile},
+	{"Customers", masterfiles.ValidateCustomers},
+	{"TaxTable", masterfiles.ValidateTaxTable},
+	{"Payments", sourcedocuments.ValidatePayments},
+	{"InvoiceExemptions", sourcedocuments.ValidateInvoiceExemptions},
+	{"InvoiceTaxRegions", sourcedocuments.ValidateInvoiceTaxRegions},
+	{"Structure", (*saft.AuditFile).Validate},
+}
+
+// Validate runs all checks on a and returns their findings; none means the
+// file is valid.
+func Validate(a *saft.AuditFile) []Finding {
+	var findings []Finding
+	for _, c := range checks {
+		if err := c.fn(a); err != nil {
+			findings = append(findings, Finding{Check: c.name, Err: err})
+		}
+	}
+	return findings
+}
+
+// HashReport is the result of [VerifyHashes].
+type HashReport struct {
+	// Verified counts the documents whose hash was checked.
+	Verified int
+	// Skipped counts the documents that could not be checked because the
+	// previous document of their series is not in the file.
+	Skipped  int
+	Findings []Finding
+}
+
+type hashedDocument struct {
+	section    string
+	number     string
+	date       time.Time
+	systemDate time.Time
+	grossTotal decimal.Decimal
+	hash       string
+}
+
+// VerifyHashes checks the Hash of every sales invoice, stock movement and
+// working document against the software producer's public key, following
+// the chain of each series in document number order.
+func VerifyHashes(a *saft.AuditFile, pub *rsa.PublicKey) HashReport {
+	var docs []hashedDocument
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			for _, d := range sd.SalesInvoices.Invoice {
+				docs = append(docs, hashedDocument{"SalesInvoices", d.InvoiceNo, d.InvoiceDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, string(d.Hash)})
+			}
+		}
+		if sd.MovementOfGoods != nil {
+			for _, d := range sd.MovementOfGoods.StockMovement {
+				docs = append(docs, hashedDocument{"MovementOfGoods", d.DocumentNumber, d.MovementDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, string(d.Hash)})
+			}
+		}
+		if sd.WorkingDocuments != nil {
+			for _, d := range sd.WorkingDocuments.WorkDocument {
+				docs = append(docs, hashedDocument{"WorkingDocuments", d.DocumentNumber, d.WorkDate.Time, time.Time(d.SystemEntryDate), d.DocumentTotals.GrossTotal.Decimal, string(d.Hash)})
+			}
+		}
+	}
+
+	series := map[string][]hashedDocument{}
+	for _, d := range docs {
+		s, _ := splitNumber(d.number)
+		series[s] = append(series[s], d)
+	}
+
+	var report HashReport
+	names := make([]string, 0, len(series))
+	for s := range series {
+		names = append(names, s)
+	}
+	sort.Strings(names)
+	for _, s := range names {
+		list := series[s]
+		sort.SliceStable(list, func(i, j int) bool {
+			_, ni := splitNumber(list[i].number)
+			_, nj := splitNumber(list[j].number)
+			return ni < nj
+		})
+
+		for i, d := range list {
+			_, n := splitNumber(d.number)
+			var previous string
+			switch {
+			case i > 0:
+				if _, p := splitNumber(list[i-1].number); p != n-1 {
+					report.Skipped++
+					continue
+				}
+				previous = list[i-1].hash
+			case n != 1:
+				report.Skipped++
+				continue
+			}
+
+			report.Verified++
+			if err := signature.VerifyFiscalDocument(pub, d.date, d.systemDate, d.number, d.grossTotal, previous, d.hash); err != nil {
+				report.Findings = append(report.Findings, Finding{Check: d.section, Err: err})
+			}
+		}
+	}
+	return report
+}
+
+// splitNumber splits a document number such as "FT A/12" into its series
+// ("FT A") and sequential number (12).
+func splitNumber(number string) (string, int) {
+	i := strings.LastIndex(number, "/")
+	if i < 0 {
+		return number, 0
+	}
+	n, _ := strconv.Atoi(number[i+1:])
+	return number[:i], n
+}
@@ -0,0 +1,171 @@
+package check
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/hestiatechnology/autoridadetributaria/saft/signature"
+	"github.com/shopspring/decimal"
+)
+
+func TestValidateReportsAllChecks(t *testing.T) {
+	findings := Validate(&saft.AuditFile{})
+	if len(findings) == 0 {
+		t.Fatal("Validate(empty file) found nothing")
+	}
+	for _, f := range findings {
+		if f.Check == "" || f.Err == nil {
+			t.Errorf("incomplete finding %+v", f)
+		}
+	}
+}
+
+// TestValidateIncompleteFiles checks that the validators report missing
+// sections and records instead of dereferencing them.
+func TestValidateIncompleteFiles(t *testing.T) {
+	files := map[string]*saft.AuditFile{
+		"empty":           {},
+		"no documents":    {SourceDocuments: &saft.SourceDocuments{}},
+		"empty tax table": {MasterFiles: saft.AuditFileMasterFiles{TaxTable: &saft.TaxTable{TaxTableEntry: []saft.TaxTableEntry{{}}}}},
+		"empty customer":  {MasterFiles: saft.AuditFileMasterFiles{Customer: []saft.Customer{{}}}},
+		"empty invoice":   {SourceDocuments: &saft.SourceDocuments{SalesInvoices: &saft.SourceDocumentsSalesInvoices{Invoice: []saft.SalesInvoicesInvoice{{Line: []saft.InvoiceLine{{}}}}}}},
+		"empty payment":   {SourceDocuments: &saft.SourceDocuments{Payments: &saft.SourceDocumentsPayments{Payment: []saft.PaymentsPayment{{Line: []saft.PaymentLine{{}}}}}}},
+		"payment tax":     {SourceDocuments: &saft.SourceDocuments{Payments: &saft.SourceDocumentsPayments{Payment: []saft.PaymentsPayment{{Line: []saft.PaymentLine{{Tax: &saft.PaymentTax{TaxType: saft.TaxTypeIS}}}}}}}},
+		"empty movement":  {SourceDocuments: &saft.SourceDocuments{MovementOfGoods: &saft.SourceDocumentsMovementOfGoods{StockMovement: []saft.MovementOfGoodsStockMovement{{Line: []saft.StockMovementLine{{}}}}}}},
+		"empty work":      {SourceDocuments: &saft.SourceDocuments{WorkingDocuments: &saft.SourceDocumentsWorkingDocuments{WorkDocument: []saft.WorkingDocumentsWorkDocument{{Line: []saft.WorkDocumentLine{{}}}}}}},
+		"empty ledger":    {GeneralLedgerEntries: &saft.GeneralLedgerEntries{Journal: []saft.GeneralLedgerEntriesJournal{{Transaction: []saft.JournalTransaction{{}}}}}},
+		"empty accounts":  {MasterFiles: saft.AuditFileMasterFiles{GeneralLedgerAccounts: &saft.GeneralLedgerAccounts{Account: []saft.GeneralLedgerAccountsAccount{{}}}}},
+	}
+	for name, a := range files {
+		t.Run(name, func(t *testing.T) {
+			if findings := Validate(a); len(findings) == 0 {
+				t.Error("Validate() found nothing")
+			}
+		})
+	}
+}
+
+func TestValidateTaxTableRates(t *testing.T) {
+	entry := func(pct int64) saft.TaxTableEntry {
+		p := saft.SafdecimalType{Decimal: decimal.NewFromInt(pct)}
+		return saft.TaxTableEntry{TaxType: "IVA", TaxCountryRegion: "PT", TaxCode: "NOR", Description: "Normal", TaxPercentage: &p}
+	}
+	line := func(pct int64) saft.InvoiceLine {
+		p := saft.SafdecimalType{Decimal: decimal.NewFromInt(pct)}
+		return saft.InvoiceLine{Tax: saft.Tax{TaxType: "IVA", TaxCountryRegion: "PT", TaxCode: "NOR", TaxPercentage: &p}}
+	}
+	for _, tc := range []struct {
+		year int
+		pct  int64
+		used bool
+		ok   bool
+	}{
+		{2024, 23, true, true},
+		{2024, 21, true, false},
+		// Superseded rates no line uses are left alone.
+		{2024, 21, false, true},
+		// 20% until June 2010, then 21%.
+		{2010, 20, true, true},
+		{2010, 21, true, true},
+		{2010, 23, true, false},
+	} {
+		a := &saft.AuditFile{}
+		a.Header.StartDate = saft.SafptdateSpan(time.Date(tc.year, time.January, 1, 0, 0, 0, 0, time.UTC))
+		a.Header.EndDate = saft.SafptdateSpan(time.Date(tc.year, time.December, 31, 0, 0, 0, 0, time.UTC))
+		a.MasterFiles.TaxTable = &saft.TaxTable{TaxTableEntry: []saft.TaxTableEntry{entry(tc.pct)}}
+		invoice := saft.SalesInvoicesInvoice{Line: []saft.InvoiceLine{line(23)}}
+		if tc.used {
+			invoice.Line = append(invoice.Line, line(tc.pct))
+		}
+		a.SourceDocuments = &saft.SourceDocuments{SalesInvoices: &saft.SourceDocumentsSalesInvoices{Invoice: []saft.SalesInvoicesInvoice{invoice}}}
+		found := false
+		for _, f := range Validate(a) {
+			found = found || f.Check == "TaxTable"
+		}
+		if found == tc.ok {
+			t.Errorf("NOR %d%% in %d (used %v): TaxTable finding = %v, want %v", tc.pct, tc.year, tc.used, found, !tc.ok)
+		}
+	}
+}
+
+func TestValidateCustomerTaxIDs(t *testing.T) {
+	customer := func(taxID, country, postalCode string) saft.Customer {
+		return saft.Customer{
+			CustomerId: "C1", AccountId: "Desconhecido", CustomerTaxId: saft.SafpttextTypeMandatoryMax30Car(taxID), CompanyName: "Cliente",
+			BillingAddress: saft.CustomerAddressStructure{AddressDetail: "Rua 1", City: "Cidade", PostalCode: saft.SafpttextTypeMandatoryMax20Car(postalCode), Country: saft.CustomerCountry(country)},
+		}
+	}
+	tests := []struct {
+		name     string
+		customer saft.Customer
+		ok       bool
+	}{
+		{"NIF, Portuguese address", customer("501442600", "PT", "1000-001"), true},
+		{"invalid NIF, Portuguese address", customer("501442601", "PT", "1000-001"), false},
+		{"NIF, foreign address", customer("501442600", "ES", "28001"), true},
+		{"prefixed VAT ID, Portuguese address", customer("ESA28015865", "PT", "1000-001"), true},
+		{"invalid prefixed VAT ID, Portuguese address", customer("ESA28015866", "PT", "1000-001"), false},
+		{"unprefixed VAT ID, foreign address", customer("A28015865", "ES", "28001"), true},
+		{"invalid unprefixed VAT ID, foreign address", customer("A28015866", "ES", "28001"), false},
+		{"invalid unprefixed VAT ID, Greek address", customer("094259217", "GR", "10431"), false},
+		{"tax ID outside the EU", customer("12-3456789", "AO", "0000"), true},
+	}
+	for _, tt := range tests {
+		a := &saft.AuditFile{}
+		a.MasterFiles.Customer = []saft.Customer{tt.customer}
+		found := false
+		for _, f := range Validate(a) {
+			found = found || f.Check == "Customers"
+		}
+		if found == tt.ok {
+			t.Errorf("%s: Customers finding = %v, want %v", tt.name, found, !tt.ok)
+		}
+	}
+}
+
+func TestVerifyHashes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, time.December, 2, 0, 0, 0, 0, time.UTC)
+	var invoices []saft.SalesInvoicesInvoice
+	previous := ""
+	for i, number := range []string{"FT A/1", "FT A/2", "FT A/3"} {
+		system := day.Add(time.Duration(i) * time.Hour)
+		total := decimal.NewFromInt(int64(10 * (i + 1)))
+		hash, err := signature.SignFiscalDocument(key, day, system, number, total, previous)
+		if err != nil {
+			t.Fatal(err)
+		}
+		previous = string(hash)
+		invoices = append(invoices, saft.SalesInvoicesInvoice{
+			InvoiceNo:       number,
+			InvoiceDate:     saft.SafdateType{Time: day},
+			SystemEntryDate: saft.SafdateTimeType(system),
+			Hash:            saft.SafpttextTypeMandatoryMax172Car(hash),
+			DocumentTotals:  saft.InvoiceDocumentTotals{GrossTotal: saft.SafmonetaryType{Decimal: total}},
+		})
+	}
+	a := &saft.AuditFile{SourceDocuments: &saft.SourceDocuments{
+		SalesInvoices: &saft.SourceDocumentsSalesInvoices{Invoice: invoices},
+	}}
+
+	if r := VerifyHashes(a, &key.PublicKey); r.Verified != 3 || len(r.Findings) != 0 {
+		t.Errorf("VerifyHashes() = %+v, want 3 verified", r)
+	}
+
+	invoices[1].DocumentTotals.GrossTotal = saft.SafmonetaryType{Decimal: decimal.NewFromInt(99)}
+	if r := VerifyHashes(a, &key.PublicKey); len(r.Findings) != 1 || r.Findings[0].Check != "SalesInvoices" {
+		t.Errorf("VerifyHashes() after tampering = %+v, want one finding", r)
+	}
+
+	a.SourceDocuments.SalesInvoices.Invoice = invoices[1:]
+	if r := VerifyHashes(a, &key.PublicKey); r.Skipped != 1 || r.Verified != 1 {
+		t.Errorf("VerifyHashes() without the first invoice = %+v, want 1 skipped and 1 verified", r)
+	}
+}
@@ -0,0 +1,311 @@
+// Package diff compares two SAF-T audit files, such as two exports of the
+// same period, record by record.
+package diff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+)
+
+// Kind is the kind of a [Change].
+type Kind string
+
+const (
+	Added    Kind = "added"
+	Removed  Kind = "removed"
+	Modified Kind = "modified"
+)
+
+// FieldChange is a field that differs between the two versions of a record.
+// Field is the path from the record, e.g. "Line[0].Tax.TaxPercentage"; an
+// absent element has the empty value.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Change is a record added, removed or modified by the newer file.
+type Change struct {
+	// Section is Customer, Supplier, Product, Transaction, SalesInvoices,
+	// MovementOfGoods, WorkingDocuments or Payments.
+	Section string `json:"section"`
+	// Key is the record's natural key: CustomerID, SupplierID,
+	// ProductCode, TransactionID, InvoiceNo, DocumentNumber or
+	// PaymentRefNo.
+	Key    string        `json:"key"`
+	Kind   Kind          `json:"kind"`
+	Fields []FieldChange `json:"fields,omitempty"`
+	// HashChanged is set on modified documents whose Hash differs: the
+	// number was signed again for a different document, which an issued
+	// document never allows and is evidence of tampering.
+	HashChanged bool `json:"hashChanged,omitempty"`
+}
+
+// Report lists the changes from one file to another, by section in
+// the order of [Change.Section] and then in file order.
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Compare compares the master file records, transactions and documents of
+// the files from and to by their natural keys.
+func Compare(from, to *saft.AuditFile) *Report {
+	r := &Report{}
+
+	compare(r, "Customer", from.MasterFiles.Customer, to.MasterFiles.Customer,
+		func(c *saft.Customer) string { return string(c.CustomerId) }, nil)
+	compare(r, "Supplier", from.MasterFiles.Supplier, to.MasterFiles.Supplier,
+		func(s *saft.Supplier) string { return string(s.SupplierId) }, nil)
+	compare(r, "Product", from.MasterFiles.Product, to.MasterFiles.Product,
+		func(p *saft.Product) string { return string(p.ProductCode) }, nil)
+	compare(r, "Transaction", transactions(from), transactions(to),
+		func(t *saft.JournalTransaction) string { return string(t.TransactionId) }, nil)
+
+	fromDocs, toDocs := documents(from), documents(to)
+	compare(r, "SalesInvoices", fromDocs.SalesInvoices.Invoice, toDocs.SalesInvoices.Invoice,
+		func(i *saft.SalesInvoicesInvoice) string { return i.InvoiceNo },
+		func(i *saft.SalesInvoicesInvoice) string { return string(i.Hash) })
+	compare(r, "MovementOfGoods", fromDocs.MovementOfGoods.StockMovement, toDocs.MovementOfGoods.StockMovement,
+		func(m *saft.MovementOfGoodsStockMovement) string { return m.DocumentNumber },
+		func(m *saft.MovementOfGoodsStockMovement) string { return string(m.Hash) })
+	compare(r, "WorkingDocuments", fromDocs.WorkingDocuments.WorkDocument, toDocs.WorkingDocuments.WorkDocument,
+		func(w *saft.WorkingDocumentsWorkDocument) string { return w.DocumentNumber },
+		func(w *saft.WorkingDocumentsWorkDocument) string { return string(w.Hash) })
+	compare(r, "Payments", fromDocs.Payments.Payment, toDocs.Payments.Payment,
+		func(p *saft.PaymentsPayment) string { return p.PaymentRefNo }, nil)
+
+	return r
+}
+
+// Empty reports whether the files have no differences.
+func (r *Report) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// Tampered returns the modified documents whose hash changed.
+func (r *Report) Tampered() []Change {
+	var changes []Change
+	for _, c := range r.Changes {
+		if c.HashChanged {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// WriteText writes r for people: one line per record, marked +, - or ~,
+// followed by the changed fields of modified records.
+func (r *Report) WriteText(w io.Writer) error {
+	var added, removed, modified int
+	for _, c := range r.Changes {
+		mark := "~"
+		switch c.Kind {
+		case Added:
+			mark = "+"
+			added++
+		case Removed:
+			mark = "-"
+			removed++
+		default:
+			modified++
+		}
+		line := fmt.Sprintf("%s %s %s", mark, c.Section, c.Key)
+		if c.HashChanged {
+			line += " (hash changed: possible tampering)"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for _, f := range c.Fields {
+			if _, err := fmt.Fprintf(w, "    %s: %q -> %q\n", f.Field, f.Old, f.New); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d modified\n", added, removed, modified)
+	return err
+}
+
+// compare appends the changes between the records of a section in the
+// older and newer files, matched by key. hash, when not nil, returns a
+// document's Hash.
+func compare[T any](r *Report, section string, older, newer []T, key func(*T) string, hash func(*T) string) {
+	before := make(map[string]*T, len(older))
+	for i := range older {
+		before[key(&older[i])] = &older[i]
+	}
+
+	seen := make(map[string]bool, len(newer))
+	for i := range newer {
+		n := &newer[i]
+		k := key(n)
+		seen[k] = true
+		o, ok := before[k]
+		if !ok {
+			r.Changes = append(r.Changes, Change{Section: section, Key: k, Kind: Added})
+			continue
+		}
+
+		var fields []FieldChange
+		walk("", reflect.ValueOf(o).Elem(), reflect.ValueOf(n).Elem(), &fields)
+		if len(fields) == 0 {
+			continue
+		}
+		r.Changes = append(r.Changes, Change{
+			Section:     section,
+			Key:         k,
+			Kind:        Modified,
+			Fields:      fields,
+			HashChanged: hash != nil && hash(o) != hash(n),
+		})
+	}
+
+	for i := range older {
+		if k := key(&older[i]); !seen[k] {
+			r.Changes = append(r.Changes, Change{Section: section, Key: k, Kind: Removed})
+		}
+	}
+}
+
+// walk appends the leaf fields that differ between a and b, which have the
+// same type.
+func walk(path string, a, b reflect.Value, fields *[]FieldChange) {
+	if x, ok := format(a); ok {
+		if y, _ := format(b); x != y {
+			*fields = append(*fields, FieldChange{Field: path, Old: x, New: y})
+		}
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer:
+		if a.IsNil() && b.IsNil() {
+			return
+		}
+		walk(path, deref(a), deref(b), fields)
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Name == "XMLName" {
+				continue
+			}
+			name := f.Name
+			if path != "" {
+				name = path + "." + name
+			}
+			walk(name, a.Field(i), b.Field(i), fields)
+		}
+	case reflect.Slice:
+		for i := 0; i < max(a.Len(), b.Len()); i++ {
+			walk(path+"["+strconv.Itoa(i)+"]", index(a, i), index(b, i), fields)
+		}
+	}
+}
+
+// format returns the text of a leaf value: a SAF-T date, time or number,
+// an element the SAF-T structs do not model, as XML, or a basic type. A nil
+// pointer to a leaf is "".
+func format(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			_, ok := format(reflect.Zero(v.Type().Elem()))
+			return "", ok
+		}
+		return format(v.Elem())
+	}
+
+	switch x := v.Interface().(type) {
+	case saft.SafdateType:
+		return formatTime(x.Time, time.DateOnly), true
+	case saft.SafdateTimeType:
+		return formatTime(time.Time(x), "2006-01-02T15:04:05"), true
+	case saft.SafptdateSpan:
+		return formatTime(time.Time(x), time.DateOnly), true
+	case saft.SafmonetaryType:
+		return x.String(), true
+	case saft.SafdecimalType:
+		return x.String(), true
+	case saft.UnknownElement:
+		if x.XMLName.Local == "" {
+			return "", true
+		}
+		b, err := xml.Marshal(x)
+		if err != nil {
+			return err.Error(), true
+		}
+		return string(b), true
+	}
+
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), true
+	}
+	return "", false
+}
+
+func formatTime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// deref returns the value p points to, or the zero value when p is nil.
+func deref(p reflect.Value) reflect.Value {
+	if p.IsNil() {
+		return reflect.Zero(p.Type().Elem())
+	}
+	return p.Elem()
+}
+
+// index returns s[i], or the zero element when s is shorter.
+func index(s reflect.Value, i int) reflect.Value {
+	if i < s.Len() {
+		return s.Index(i)
+	}
+	return reflect.Zero(s.Type().Elem())
+}
+
+func transactions(a *saft.AuditFile) []saft.JournalTransaction {
+	if a.GeneralLedgerEntries == nil {
+		return nil
+	}
+	var ts []saft.JournalTransaction
+	for _, j := range a.GeneralLedgerEntries.Journal {
+		ts = append(ts, j.Transaction...)
+	}
+	return ts
+}
+
+// documents returns the source documents of a with every section present,
+// so that absent sections compare as empty.
+func documents(a *saft.AuditFile) saft.SourceDocuments {
+	var sd saft.SourceDocuments
+	if a.SourceDocuments != nil {
+		sd = *a.SourceDocuments
+	}
+	if sd.SalesInvoices == nil {
+		sd.SalesInvoices = &saft.SourceDocumentsSalesInvoices{}
+	}
+	if sd.MovementOfGoods == nil {
+		sd.MovementOfGoods = &saft.SourceDocumentsMovementOfGoods{}
+	}
+	if sd.WorkingDocuments == nil {
+		sd.WorkingDocuments = &saft.SourceDocumentsWorkingDocuments{}
+	}
+	if sd.Payments == nil {
+		sd.Payments = &saft.SourceDocumentsPayments{}
+	}
+	return sd
+}
@@ -0,0 +1,174 @@
+package diff
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/shopspring/decimal"
+)
+
+func testFile() *saft.AuditFile {
+	day := saft.SafdateType{Time: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	invoice := func(no, hash string, gross int64) saft.SalesInvoicesInvoice {
+		return saft.SalesInvoicesInvoice{
+			InvoiceNo:   no,
+			InvoiceDate: day,
+			Hash:        saft.SafpttextTypeMandatoryMax172Car(hash),
+			Line: []saft.InvoiceLine{{
+				ProductCode:  "P1",
+				CreditAmount: &saft.SafmonetaryType{Decimal: decimal.NewFromInt(gross)},
+			}},
+			DocumentTotals: saft.InvoiceDocumentTotals{GrossTotal: saft.SafmonetaryType{Decimal: decimal.NewFromInt(gross)}},
+		}
+	}
+	return &saft.AuditFile{
+		MasterFiles: saft.AuditFileMasterFiles{
+			Customer: []saft.Customer{{CustomerId: "C1", CompanyName: "Cliente"}, {CustomerId: "C2"}},
+		},
+		SourceDocuments: &saft.SourceDocuments{
+			SalesInvoices: &saft.SourceDocumentsSalesInvoices{Invoice: []saft.SalesInvoicesInvoice{
+				invoice("FT A/1", "h1", 10),
+				invoice("FT A/2", "h2", 20),
+			}},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if r := Compare(testFile(), testFile()); !r.Empty() {
+		t.Fatalf("Compare(same) = %+v", r.Changes)
+	}
+
+	from, to := testFile(), testFile()
+	to.MasterFiles.Customer[0].CompanyName = "Cliente, Lda"
+	to.MasterFiles.Customer = to.MasterFiles.Customer[:1]
+	inv := &to.SourceDocuments.SalesInvoices.Invoice[1]
+	inv.Hash = "forged"
+	inv.Line[0].CreditAmount = &saft.SafmonetaryType{Decimal: decimal.RequireFromString("25.00")}
+	inv.Line = append(inv.Line, saft.InvoiceLine{ProductCode: "P2"})
+	to.SourceDocuments.SalesInvoices.Invoice = append(to.SourceDocuments.SalesInvoices.Invoice, testFile().SourceDocuments.SalesInvoices.Invoice[0])
+	to.SourceDocuments.SalesInvoices.Invoice[2].InvoiceNo = "FT A/3"
+	to.SourceDocuments.SalesInvoices.Invoice[0].Unknown = []saft.UnknownElement{{
+		XMLName: xml.Name{Space: "urn:vendor", Local: "Loja"},
+		Content: []xml.Token{xml.CharData("Porto")},
+	}}
+
+	r := Compare(from, to)
+	var got []string
+	for _, c := range r.Changes {
+		got = append(got, string(c.Kind)+" "+c.Section+" "+c.Key)
+	}
+	want := []string{
+		"modified Customer C1",
+		"removed Customer C2",
+		"modified SalesInvoices FT A/1",
+		"modified SalesInvoices FT A/2",
+		"added SalesInvoices FT A/3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if f := r.Changes[0].Fields; len(f) != 1 || f[0] != (FieldChange{"CompanyName", "Cliente", "Cliente, Lda"}) {
+		t.Errorf("customer fields = %+v", f)
+	}
+	fields := map[string]FieldChange{}
+	for _, f := range r.Changes[3].Fields {
+		fields[f.Field] = f
+	}
+	if f := fields["Line[0].CreditAmount"]; f.Old != "20" || f.New != "25" {
+		t.Errorf("Line[0].CreditAmount = %+v", f)
+	}
+	if f := fields["Line[1].ProductCode"]; f.Old != "" || f.New != "P2" {
+		t.Errorf("Line[1].ProductCode = %+v", f)
+	}
+	if _, ok := fields["Line[1].CreditAmount"]; ok {
+		t.Error("absent amounts reported as changed")
+	}
+	if tampered := r.Tampered(); len(tampered) != 1 || tampered[0].Key != "FT A/2" {
+		t.Errorf("Tampered() = %+v", tampered)
+	}
+
+	var text strings.Builder
+	if err := r.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "~ SalesInvoices FT A/2 (hash changed") || !strings.HasSuffix(text.String(), "1 added, 1 removed, 3 modified\n") {
+		t.Errorf("WriteText() =\n%s", text.String())
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantJSON {
+		t.Errorf("JSON =\n%s\nwant:\n%s", data, wantJSON)
+	}
+}
+
+// wantJSON is the report of TestCompare, indented.
+const wantJSON = `{
+  "changes": [
+    {
+      "section": "Customer",
+      "key": "C1",
+      "kind": "modified",
+      "fields": [
+        {
+          "field": "CompanyName",
+          "old": "Cliente",
+          "new": "Cliente, Lda"
+        }
+      ]
+    },
+    {
+      "section": "Customer",
+      "key": "C2",
+      "kind": "removed"
+    },
+    {
+      "section": "SalesInvoices",
+      "key": "FT A/1",
+      "kind": "modified",
+      "fields": [
+        {
+          "field": "Unknown[0]",
+          "old": "",
+          "new": "\u003cLoja xmlns=\"urn:vendor\"\u003ePorto\u003c/Loja\u003e"
+        }
+      ]
+    },
+    {
+      "section": "SalesInvoices",
+      "key": "FT A/2",
+      "kind": "modified",
+      "fields": [
+        {
+          "field": "Hash",
+          "old": "h2",
+          "new": "forged"
+        },
+        {
+          "field": "Line[0].CreditAmount",
+          "old": "20",
+          "new": "25"
+        },
+        {
+          "field": "Line[1].ProductCode",
+          "old": "",
+          "new": "P2"
+        }
+      ],
+      "hashChanged": true
+    },
+    {
+      "section": "SalesInvoices",
+      "key": "FT A/3",
+      "kind": "added"
+    }
+  ]
+}`
@@ -0,0 +1,336 @@
+// Package export flattens SAF-T audit files into normalized tables, such as
+// invoices and invoice lines, written as CSV or newline-delimited JSON for
+// spreadsheets and BI tools.
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/shopspring/decimal"
+	"golang.org/x/net/html/charset"
+)
+
+// Writer receives the rows of the exported tables, in document order and
+// with the tables interleaved. The values of a row follow t.Columns.
+type Writer interface {
+	WriteRow(t *Table, values []string) error
+	Close() error
+}
+
+// Export writes the rows of every table of a to w. It does not close w.
+func Export(a *saft.AuditFile, w Writer) error {
+	e := exporter{w: w}
+	mf := &a.MasterFiles
+	for i := range mf.Customer {
+		e.customer(&mf.Customer[i])
+	}
+	for i := range mf.Product {
+		e.product(&mf.Product[i])
+	}
+	if mf.TaxTable != nil {
+		for i := range mf.TaxTable.TaxTableEntry {
+			e.taxTableEntry(&mf.TaxTable.TaxTableEntry[i])
+		}
+	}
+	if gl := a.GeneralLedgerEntries; gl != nil {
+		for _, j := range gl.Journal {
+			for i := range j.Transaction {
+				e.transaction(string(j.JournalId), &j.Transaction[i])
+			}
+		}
+	}
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			for i := range sd.SalesInvoices.Invoice {
+				e.invoice(&sd.SalesInvoices.Invoice[i])
+			}
+		}
+		if sd.MovementOfGoods != nil {
+			for i := range sd.MovementOfGoods.StockMovement {
+				e.stockMovement(&sd.MovementOfGoods.StockMovement[i])
+			}
+		}
+		if sd.Payments != nil {
+			for i := range sd.Payments.Payment {
+				e.payment(&sd.Payments.Payment[i])
+			}
+		}
+	}
+	return e.err
+}
+
+// Stream is like [Export] for a SAF-T XML document read from r, decoding
+// one record at a time so that files of any size use little memory.
+func Stream(r io.Reader, w Writer) error {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+	e := exporter{w: w}
+
+	var path []string
+	var journalID string
+	for e.err == nil {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// Records are decoded whole, so path only holds their ancestors.
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := ""
+			if len(path) > 0 {
+				parent = path[len(path)-1]
+			}
+			switch parent + "/" + t.Name.Local {
+			case "MasterFiles/Customer":
+				var v saft.Customer
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.customer(&v)
+				}
+			case "MasterFiles/Product":
+				var v saft.Product
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.product(&v)
+				}
+			case "TaxTable/TaxTableEntry":
+				var v saft.TaxTableEntry
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.taxTableEntry(&v)
+				}
+			case "Journal/JournalID":
+				err = d.DecodeElement(&journalID, &t)
+			case "Journal/Transaction":
+				var v saft.JournalTransaction
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.transaction(journalID, &v)
+				}
+			case "SalesInvoices/Invoice":
+				var v saft.SalesInvoicesInvoice
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.invoice(&v)
+				}
+			case "MovementOfGoods/StockMovement":
+				var v saft.MovementOfGoodsStockMovement
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.stockMovement(&v)
+				}
+			case "Payments/Payment":
+				var v saft.PaymentsPayment
+				if err = d.DecodeElement(&v, &t); err == nil {
+					e.payment(&v)
+				}
+			default:
+				path = append(path, t.Name.Local)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+	return e.err
+}
+
+// exporter turns records into rows, keeping the first write error.
+type exporter struct {
+	w   Writer
+	err error
+}
+
+func (e *exporter) row(t *Table, values ...string) {
+	if e.err != nil {
+		return
+	}
+	if len(values) != len(t.Columns) {
+		panic(fmt.Sprintf("export: %s row has %d values for %d columns", t.Name, len(values), len(t.Columns)))
+	}
+	e.err = e.w.WriteRow(t, values)
+}
+
+func (e *exporter) customer(c *saft.Customer) {
+	a := &c.BillingAddress
+	e.row(Customers,
+		string(c.CustomerId), c.AccountId, string(c.CustomerTaxId), string(c.CompanyName),
+		string(a.AddressDetail), string(a.City), string(a.PostalCode), str(a.Region), string(a.Country),
+		str(c.Telephone), str(c.Email), uintText(uint64(c.SelfBillingIndicator)),
+	)
+}
+
+func (e *exporter) product(p *saft.Product) {
+	e.row(Products,
+		p.ProductType, string(p.ProductCode), str(p.ProductGroup), string(p.ProductDescription), string(p.ProductNumberCode),
+	)
+}
+
+func (e *exporter) taxTableEntry(t *saft.TaxTableEntry) {
+	var expiration string
+	if t.TaxExpirationDate != nil {
+		expiration = date(t.TaxExpirationDate.Time)
+	}
+	e.row(TaxTableEntries,
+		t.TaxType, t.TaxCountryRegion, string(t.TaxCode), string(t.Description), expiration,
+		decimalText(t.TaxPercentage), money(t.TaxAmount),
+	)
+}
+
+func (e *exporter) transaction(journalID string, t *saft.JournalTransaction) {
+	line := func(recordID string, account saft.SafptglaccountId, sourceDoc *saft.SafpttextTypeMandatoryMax60Car, entry saft.SafdateTimeType, description string, debit, credit *saft.SafmonetaryType) {
+		e.row(GeneralLedgerLines,
+			journalID, string(t.TransactionId), uintText(uint64(t.Period)), date(t.TransactionDate.Time), t.TransactionType,
+			str(t.CustomerId), str(t.SupplierId), string(t.DocArchivalNumber),
+			recordID, string(account), str(sourceDoc), dateTime(time.Time(entry)), description,
+			money(debit), money(credit),
+		)
+	}
+	for _, l := range t.Lines.DebitLine {
+		line(string(l.RecordId), l.AccountId, l.SourceDocumentId, l.SystemEntryDate, string(l.Description), &l.DebitAmount, nil)
+	}
+	for _, l := range t.Lines.CreditLine {
+		line(string(l.RecordId), l.AccountId, l.SourceDocumentId, l.SystemEntryDate, string(l.Description), nil, &l.CreditAmount)
+	}
+}
+
+func (e *exporter) invoice(inv *saft.SalesInvoicesInvoice) {
+	t := &inv.DocumentTotals
+	var period string
+	if inv.Period != 0 {
+		period = uintText(uint64(inv.Period))
+	}
+	var currency, currencyAmount string
+	if t.Currency != nil {
+		currency, currencyAmount = t.Currency.CurrencyCode, t.Currency.CurrencyAmount.String()
+	}
+	e.row(Invoices,
+		inv.InvoiceNo, string(inv.Atcud), inv.DocumentStatus.InvoiceStatus, dateTime(time.Time(inv.DocumentStatus.InvoiceStatusDate)),
+		date(inv.InvoiceDate.Time), inv.InvoiceType, period,
+		string(inv.SourceId), dateTime(time.Time(inv.SystemEntryDate)), string(inv.CustomerId),
+		t.TaxPayable.String(), t.NetTotal.String(), t.GrossTotal.String(),
+		currency, currencyAmount, withholding(inv.WithholdingTax),
+	)
+
+	for i := range inv.Line {
+		l := &inv.Line[i]
+		var reference string
+		for _, r := range l.References {
+			if r.Reference != nil {
+				reference = string(*r.Reference)
+				break
+			}
+		}
+		e.row(InvoiceLines,
+			inv.InvoiceNo, uintText(l.LineNumber), string(l.ProductCode), string(l.ProductDescription),
+			l.Quantity.String(), string(l.UnitOfMeasure), l.UnitPrice.String(),
+			date(l.TaxPointDate.Time), reference, string(l.Description),
+			money(l.DebitAmount), money(l.CreditAmount),
+			l.Tax.TaxType, l.Tax.TaxCountryRegion, l.Tax.TaxCode, decimalText(l.Tax.TaxPercentage), money(l.Tax.TaxAmount),
+			str(l.TaxExemptionCode), str(l.TaxExemptionReason), money(l.SettlementAmount),
+		)
+	}
+}
+
+func (e *exporter) stockMovement(m *saft.MovementOfGoodsStockMovement) {
+	t := &m.DocumentTotals
+	e.row(StockMovements,
+		m.DocumentNumber, string(m.Atcud), m.DocumentStatus.MovementStatus, date(m.MovementDate.Time), m.MovementType,
+		dateTime(time.Time(m.SystemEntryDate)), str(m.CustomerId), str(m.SupplierId), dateTime(time.Time(m.MovementStartTime)),
+		strconv.Itoa(len(m.Line)), t.TaxPayable.String(), t.NetTotal.String(), t.GrossTotal.String(),
+	)
+}
+
+func (e *exporter) payment(p *saft.PaymentsPayment) {
+	var mechanisms []string
+	for _, m := range p.PaymentMethod {
+		if m.PaymentMechanism != nil {
+			mechanisms = append(mechanisms, *m.PaymentMechanism)
+		}
+	}
+	t := &p.DocumentTotals
+	e.row(Payments,
+		p.PaymentRefNo, string(p.Atcud), date(p.TransactionDate.Time), string(p.PaymentType),
+		p.DocumentStatus.PaymentStatus, dateTime(time.Time(p.DocumentStatus.PaymentStatusDate)),
+		string(p.SourceId), dateTime(time.Time(p.SystemEntryDate)), string(p.CustomerId), strings.Join(mechanisms, ";"),
+		t.TaxPayable.String(), t.NetTotal.String(), t.GrossTotal.String(), withholding(p.WithholdingTax),
+	)
+
+	for i := range p.Line {
+		l := &p.Line[i]
+		var origins, dates []string
+		for _, s := range l.SourceDocumentId {
+			origins = append(origins, string(s.OriginatingOn))
+			dates = append(dates, date(s.InvoiceDate.Time))
+		}
+		var taxType, region, code, pct, amount string
+		if l.Tax != nil {
+			taxType, region, code = l.Tax.TaxType, l.Tax.TaxCountryRegion, string(l.Tax.TaxCode)
+			pct, amount = decimalText(l.Tax.TaxPercentage), money(l.Tax.TaxAmount)
+		}
+		e.row(PaymentLines,
+			p.PaymentRefNo, uintText(l.LineNumber), strings.Join(origins, ";"), strings.Join(dates, ";"),
+			money(l.SettlementAmount), money(l.DebitAmount), money(l.CreditAmount),
+			taxType, region, code, pct, amount, str(l.TaxExemptionCode),
+		)
+	}
+}
+
+func withholding(taxes []saft.WithholdingTax) string {
+	if len(taxes) == 0 {
+		return ""
+	}
+	var total decimal.Decimal
+	for _, t := range taxes {
+		total = total.Add(t.WithholdingTaxAmount.Decimal)
+	}
+	return total.String()
+}
+
+// str returns the value of an optional text element, or "".
+func str[T ~string](p *T) string {
+	if p == nil {
+		return ""
+	}
+	return string(*p)
+}
+
+func money(m *saft.SafmonetaryType) string {
+	if m == nil {
+		return ""
+	}
+	return m.String()
+}
+
+func decimalText(d *saft.SafdecimalType) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+func uintText(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
+
+func date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+func dateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
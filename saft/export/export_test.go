@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/shopspring/decimal"
+)
+
+func testFile() *saft.AuditFile {
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	money := func(s string) *saft.SafmonetaryType {
+		return &saft.SafmonetaryType{Decimal: decimal.RequireFromString(s)}
+	}
+	return &saft.AuditFile{
+		Header: saft.Header{
+			StartDate:   saft.SafptdateSpan(day),
+			EndDate:     saft.SafptdateSpan(day),
+			DateCreated: saft.SafptdateSpan(day),
+		},
+		MasterFiles: saft.AuditFileMasterFiles{
+			Customer: []saft.Customer{{CustomerId: "C1", CompanyName: "Café \"Central\", Lda"}},
+		},
+		SourceDocuments: &saft.SourceDocuments{
+			SalesInvoices: &saft.SourceDocumentsSalesInvoices{Invoice: []saft.SalesInvoicesInvoice{{
+				InvoiceNo:       "FT A/1",
+				InvoiceDate:     saft.SafdateType{Time: day},
+				SystemEntryDate: saft.SafdateTimeType(day.Add(10 * time.Hour)),
+				CustomerId:      "C1",
+				Line: []saft.InvoiceLine{{
+					LineNumber:   1,
+					ProductCode:  "P1",
+					Quantity:     saft.SafdecimalType{Decimal: decimal.NewFromInt(2)},
+					UnitPrice:    *money("5.50"),
+					CreditAmount: money("11.00"),
+					Tax:          saft.Tax{TaxType: "IVA", TaxCountryRegion: "PT", TaxCode: "NOR", TaxPercentage: &saft.SafdecimalType{Decimal: decimal.NewFromInt(23)}},
+				}},
+				DocumentTotals: saft.InvoiceDocumentTotals{
+					TaxPayable: *money("2.53"),
+					NetTotal:   *money("11.00"),
+					GrossTotal: *money("13.53"),
+				},
+			}}},
+		},
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	dir := t.TempDir()
+	w := NewCSVWriter(dir)
+	if err := Export(testFile(), w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "invoices.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "InvoiceNo,ATCUD,") {
+		t.Fatalf("invoices.csv =\n%s", b)
+	}
+	if want := "FT A/1,,,,2024-03-01,,,,2024-03-01T10:00:00,C1,2.53,11,13.53,,,"; lines[1] != want {
+		t.Errorf("invoice row = %s, want %s", lines[1], want)
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "customers.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Café ""Central"", Lda"`) {
+		t.Errorf("customers.csv =\n%s", b)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "payments.csv")); !os.IsNotExist(err) {
+		t.Errorf("payments.csv created without rows: %v", err)
+	}
+}
+
+func TestStreamMatchesExport(t *testing.T) {
+	a := testFile()
+	var want bytes.Buffer
+	w := NewNDJSONWriter(&want)
+	if err := Export(a, w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	var doc bytes.Buffer
+	if err := a.WriteXML(&doc, saft.XMLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	w = NewNDJSONWriter(&got)
+	if err := Stream(&doc, w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if got.String() != want.String() {
+		t.Errorf("Stream() =\n%s\nExport() =\n%s", got.String(), want.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(got.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("%d rows, want 3", len(lines))
+	}
+	var line map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["table"] != "invoice_lines" || line["CreditAmount"] != 11.0 || line["DebitAmount"] != nil || line["ProductCode"] != "P1" {
+		t.Errorf("invoice line = %v", line)
+	}
+}
@@ -0,0 +1,107 @@
+package export
+
+// Column is a column of a [Table]. Numeric columns hold decimal numbers,
+// written unquoted in JSON; empty numeric values are null.
+type Column struct {
+	Name    string
+	Numeric bool
+}
+
+// Table is a normalized table of SAF-T data. Line tables repeat their
+// document's number; amounts are plain decimals, dates are YYYY-MM-DD and
+// times YYYY-MM-DDThh:mm:ss.
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+func text(names ...string) []Column {
+	cols := make([]Column, len(names))
+	for i, n := range names {
+		cols[i] = Column{Name: n}
+	}
+	return cols
+}
+
+func numeric(names ...string) []Column {
+	cols := text(names...)
+	for i := range cols {
+		cols[i].Numeric = true
+	}
+	return cols
+}
+
+func columns(groups ...[]Column) []Column {
+	var cols []Column
+	for _, g := range groups {
+		cols = append(cols, g...)
+	}
+	return cols
+}
+
+// The exported tables, in the order [Tables] lists them.
+var (
+	Customers = &Table{"customers", columns(
+		text("CustomerID", "AccountID", "CustomerTaxID", "CompanyName", "AddressDetail", "City", "PostalCode", "Region", "Country", "Telephone", "Email"),
+		numeric("SelfBillingIndicator"),
+	)}
+	Products = &Table{"products", text(
+		"ProductType", "ProductCode", "ProductGroup", "ProductDescription", "ProductNumberCode",
+	)}
+	TaxTableEntries = &Table{"tax_table", columns(
+		text("TaxType", "TaxCountryRegion", "TaxCode", "Description", "TaxExpirationDate"),
+		numeric("TaxPercentage", "TaxAmount"),
+	)}
+	Invoices = &Table{"invoices", columns(
+		text("InvoiceNo", "ATCUD", "InvoiceStatus", "InvoiceStatusDate", "InvoiceDate", "InvoiceType"),
+		numeric("Period"),
+		text("SourceID", "SystemEntryDate", "CustomerID"),
+		numeric("TaxPayable", "NetTotal", "GrossTotal"),
+		text("CurrencyCode"),
+		numeric("CurrencyAmount", "WithholdingTaxAmount"),
+	)}
+	InvoiceLines = &Table{"invoice_lines", columns(
+		text("InvoiceNo"),
+		numeric("LineNumber"),
+		text("ProductCode", "ProductDescription"),
+		numeric("Quantity"),
+		text("UnitOfMeasure"),
+		numeric("UnitPrice"),
+		text("TaxPointDate", "Reference", "Description"),
+		numeric("DebitAmount", "CreditAmount"),
+		text("TaxType", "TaxCountryRegion", "TaxCode"),
+		numeric("TaxPercentage", "TaxAmount"),
+		text("TaxExemptionCode", "TaxExemptionReason"),
+		numeric("SettlementAmount"),
+	)}
+	Payments = &Table{"payments", columns(
+		text("PaymentRefNo", "ATCUD", "TransactionDate", "PaymentType", "PaymentStatus", "PaymentStatusDate", "SourceID", "SystemEntryDate", "CustomerID", "PaymentMechanism"),
+		numeric("TaxPayable", "NetTotal", "GrossTotal", "WithholdingTaxAmount"),
+	)}
+	PaymentLines = &Table{"payment_lines", columns(
+		text("PaymentRefNo"),
+		numeric("LineNumber"),
+		text("OriginatingON", "InvoiceDate"),
+		numeric("SettlementAmount", "DebitAmount", "CreditAmount"),
+		text("TaxType", "TaxCountryRegion", "TaxCode"),
+		numeric("TaxPercentage", "TaxAmount"),
+		text("TaxExemptionCode"),
+	)}
+	StockMovements = &Table{"stock_movements", columns(
+		text("DocumentNumber", "ATCUD", "MovementStatus", "MovementDate", "MovementType", "SystemEntryDate", "CustomerID", "SupplierID", "MovementStartTime"),
+		numeric("Lines", "TaxPayable", "NetTotal", "GrossTotal"),
+	)}
+	GeneralLedgerLines = &Table{"gl_lines", columns(
+		text("JournalID", "TransactionID"),
+		numeric("Period"),
+		text("TransactionDate", "TransactionType", "CustomerID", "SupplierID", "DocArchivalNumber", "RecordID", "AccountID", "SourceDocumentID", "SystemEntryDate", "Description"),
+		numeric("DebitAmount", "CreditAmount"),
+	)}
+)
+
+// Tables lists every exported table.
+var Tables = []*Table{
+	Customers, Products, TaxTableEntries,
+	Invoices, InvoiceLines, Payments, PaymentLines, StockMovements,
+	GeneralLedgerLines,
+}
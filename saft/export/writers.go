@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CSVWriter writes each table to its own CSV file, <dir>/<table>.csv, with
+// a header row. A file is created when its table gets its first row.
+type CSVWriter struct {
+	// Comma is the field delimiter; the default is ','. Spreadsheets set
+	// up for Portuguese expect ';'.
+	Comma rune
+	// BOM starts each file with a UTF-8 byte order mark, which spreadsheets
+	// need to recognise the encoding.
+	BOM bool
+
+	dir   string
+	files map[*Table]*csvFile
+}
+
+type csvFile struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// NewCSVWriter returns a writer of CSV files in dir, which must exist.
+func NewCSVWriter(dir string) *CSVWriter {
+	return &CSVWriter{dir: dir, files: map[*Table]*csvFile{}}
+}
+
+func (c *CSVWriter) WriteRow(t *Table, values []string) error {
+	file, ok := c.files[t]
+	if !ok {
+		f, err := os.Create(filepath.Join(c.dir, t.Name+".csv"))
+		if err != nil {
+			return err
+		}
+		if c.BOM {
+			if _, err := f.WriteString("\uFEFF"); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		file = &csvFile{f: f, w: csv.NewWriter(f)}
+		if c.Comma != 0 {
+			file.w.Comma = c.Comma
+		}
+		c.files[t] = file
+
+		header := make([]string, len(t.Columns))
+		for i, col := range t.Columns {
+			header[i] = col.Name
+		}
+		if err := file.w.Write(header); err != nil {
+			return err
+		}
+	}
+	return file.w.Write(values)
+}
+
+// Close flushes and closes every file.
+func (c *CSVWriter) Close() error {
+	var errs []error
+	for _, file := range c.files {
+		file.w.Flush()
+		errs = append(errs, file.w.Error(), file.f.Close())
+	}
+	c.files = map[*Table]*csvFile{}
+	return errors.Join(errs...)
+}
+
+// NDJSONWriter writes every row as a JSON object on its own line, with the
+// table name under "table" followed by the columns in table order:
+//
+//	{"table":"invoices","InvoiceNo":"FT A/1",...,"GrossTotal":123.00,...}
+type NDJSONWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewNDJSONWriter returns a writer of newline-delimited JSON to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: bufio.NewWriter(w)}
+}
+
+func (n *NDJSONWriter) WriteRow(t *Table, values []string) error {
+	b := append(n.buf[:0], `{"table":`...)
+	b = appendString(b, t.Name)
+	for i, col := range t.Columns {
+		b = append(b, ',')
+		b = appendString(b, col.Name)
+		b = append(b, ':')
+		switch {
+		case !col.Numeric:
+			b = appendString(b, values[i])
+		case values[i] == "":
+			b = append(b, "null"...)
+		default:
+			b = append(b, values[i]...)
+		}
+	}
+	b = append(b, "}\n"...)
+	n.buf = b
+	_, err := n.w.Write(b)
+	return err
+}
+
+// Close flushes the output; it does not close the underlying writer.
+func (n *NDJSONWriter) Close() error {
+	return n.w.Flush()
+}
+
+func appendString(b []byte, s string) []byte {
+	quoted, _ := json.Marshal(s)
+	return append(b, quoted...)
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"time"
@@ -42,7 +43,7 @@ func ValidateHeader(a *saft.AuditFile) error {
 	}
 
 	// Check if CompanyAddress is empty
-	if a.Header.CompanyAddress == (saft.AddressStructure{}) {
+	if reflect.ValueOf(a.Header.CompanyAddress).IsZero() {
 		return fmt.Errorf("saft: missing Header.CompanyAddress")
 	}
 
@@ -62,6 +63,10 @@ func ValidateHeader(a *saft.AuditFile) error {
 		return fmt.Errorf("saft: invalid Header.CompanyAddress.Country, must be PR, current value: %s", a.Header.CompanyAddress.Country)
 	}
 
+	if err := addressOf(a.Header.CompanyAddress).Validate(false); err != nil {
+		return fmt.Errorf("saft: invalid Header.CompanyAddress: %w", err)
+	}
+
 	if a.Header.FiscalYear == "" {
 		return fmt.Errorf("saft: missing Header.FiscalYear")
 	}
@@ -135,3 +140,22 @@ func ValidateHeader(a *saft.AuditFile) error {
 	return nil
 
 }
+
+func addressOf(a saft.AddressStructure) common.Address {
+	addr := common.Address{
+		AddressDetail: string(a.AddressDetail),
+		City:          string(a.City),
+		PostalCode:    string(a.PostalCode),
+		Country:       a.Country,
+	}
+	if a.BuildingNumber != nil {
+		addr.BuildingNumber = string(*a.BuildingNumber)
+	}
+	if a.StreetName != nil {
+		addr.StreetName = string(*a.StreetName)
+	}
+	if a.Region != nil {
+		addr.Region = string(*a.Region)
+	}
+	return addr
+}
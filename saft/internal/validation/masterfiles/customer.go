@@ -2,6 +2,7 @@ package masterfiles
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/hestiatechnology/autoridadetributaria/common"
 	"github.com/hestiatechnology/autoridadetributaria/saft"
@@ -25,7 +26,7 @@ func ValidateCustomers(a *saft.AuditFile) error {
 			return fmt.Errorf("saft: missing Customer.CompanyName")
 		}
 
-		if customer.BillingAddress == (saft.CustomerAddressStructure{}) {
+		if reflect.ValueOf(customer.BillingAddress).IsZero() {
 			return fmt.Errorf("saft: missing Customer.BillingAddress")
 		}
 
@@ -45,11 +46,12 @@ func ValidateCustomers(a *saft.AuditFile) error {
 			return fmt.Errorf("saft: missing Customer.BillingAddress.Country")
 		}
 
-		if len(customer.CustomerTaxId) == 9 && customer.BillingAddress.Country == "PT" {
-			ok := common.ValidateNIFPT(string(customer.CustomerTaxId))
-			if !ok {
-				return fmt.Errorf("saft: invalid NIF in Customer.CustomerTaxId: %s", customer.CustomerTaxId)
-			}
+		if err := customerAddress(customer.BillingAddress).Validate(true); err != nil {
+			return fmt.Errorf("saft: invalid Customer.BillingAddress: %w", err)
+		}
+
+		if err := validateTaxID(customer); err != nil {
+			return fmt.Errorf("saft: invalid Customer.CustomerTaxId: %w", err)
 		}
 
 		for _, shipTo := range customer.ShipToAddress {
@@ -68,6 +70,10 @@ func ValidateCustomers(a *saft.AuditFile) error {
 			if shipTo.Country == "" {
 				return fmt.Errorf("saft: missing ShipTo.Country")
 			}
+
+			if err := customerAddress(shipTo).Validate(true); err != nil {
+				return fmt.Errorf("saft: invalid ShipTo: %w", err)
+			}
 		}
 
 		if customer.SelfBillingIndicator != saft.IndicatorNo && customer.SelfBillingIndicator != saft.IndicatorYes {
@@ -77,3 +83,48 @@ func ValidateCustomers(a *saft.AuditFile) error {
 
 	return nil
 }
+
+// validateTaxID checks the customer's tax ID by the rules of the country
+// of its prefix or, without one, of the billing address country: a 9
+// character ID with a Portuguese address is a NIF, and an ID with an
+// address elsewhere in the EU is checked as that country's VAT number.
+// The address alone does not decide it, as a Portuguese NIF may have a
+// foreign address, so a valid NIF is accepted whatever the country. IDs
+// of countries outside the EU are not checked.
+func validateTaxID(customer saft.Customer) error {
+	id := string(customer.CustomerTaxId)
+	if country := common.TaxIDCountry(id); country != "" {
+		return common.ValidateTaxID(country, id)
+	}
+	country := string(customer.BillingAddress.Country)
+	switch {
+	case country == "PT" || country == "PT-AC" || country == "PT-MA":
+		if len(id) == 9 {
+			return common.ValidateTaxID("PT", id)
+		}
+	case common.IsEUCountry(country):
+		if err := common.ValidateTaxID(country, id); err != nil && !common.ValidateNIFPT(id) {
+			return err
+		}
+	}
+	return nil
+}
+
+func customerAddress(a saft.CustomerAddressStructure) common.Address {
+	addr := common.Address{
+		AddressDetail: string(a.AddressDetail),
+		City:          string(a.City),
+		PostalCode:    string(a.PostalCode),
+		Country:       string(a.Country),
+	}
+	if a.BuildingNumber != nil {
+		addr.BuildingNumber = string(*a.BuildingNumber)
+	}
+	if a.StreetName != nil {
+		addr.StreetName = string(*a.StreetName)
+	}
+	if a.Region != nil {
+		addr.Region = string(*a.Region)
+	}
+	return addr
+}
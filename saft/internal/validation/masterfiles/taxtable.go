@@ -1 +1,128 @@
 package masterfiles
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+	"github.com/shopspring/decimal"
+)
+
+// ValidateTaxTable checks the VAT percentages of the Portuguese regions
+// against [common.PortugueseVATRates] for the period of the file. A rate in
+// force on any day from StartDate to EndDate is accepted. Only the entries
+// used by a document line are checked, as software often keeps superseded
+// rates in the table.
+func ValidateTaxTable(a *saft.AuditFile) error {
+	if a.MasterFiles.TaxTable == nil {
+		return nil
+	}
+
+	start, end := time.Time(a.Header.StartDate), time.Time(a.Header.EndDate)
+	used := usedVATRates(a)
+	for _, entry := range a.MasterFiles.TaxTable.TaxTableEntry {
+		if entry.TaxType == "" {
+			return fmt.Errorf("saft: missing TaxTableEntry.TaxType")
+		}
+
+		if entry.TaxCountryRegion == "" {
+			return fmt.Errorf("saft: missing TaxTableEntry.TaxCountryRegion")
+		}
+
+		if entry.TaxCode == "" {
+			return fmt.Errorf("saft: missing TaxTableEntry.TaxCode")
+		}
+
+		if entry.TaxType != "IVA" || entry.TaxPercentage == nil {
+			continue
+		}
+
+		code := string(entry.TaxCode)
+		if !used[vatRateKey(entry.TaxCountryRegion, code, entry.TaxPercentage.Decimal)] {
+			continue
+		}
+		want, err := common.VATRateAt(entry.TaxCountryRegion, code, end)
+		if err != nil {
+			// Not a catalogued region or code
+			continue
+		}
+		if !rateInForce(entry.TaxCountryRegion, code, entry.TaxPercentage.Decimal, start, end) {
+			return fmt.Errorf("saft: invalid TaxTableEntry.TaxPercentage for %s %s: %s, want %s", entry.TaxCountryRegion, code, entry.TaxPercentage.String(), want)
+		}
+	}
+
+	return nil
+}
+
+// rateInForce reports whether percentage was the rate of code in region on
+// some day from start to end: on start, or on a day the rate changed.
+func rateInForce(region, code string, percentage decimal.Decimal, start, end time.Time) bool {
+	days := []time.Time{start}
+	for _, r := range common.PortugueseVATRates {
+		if r.Region == region && r.Code == code && r.From.After(start) && !r.From.After(end) {
+			days = append(days, r.From)
+		}
+	}
+	for _, day := range days {
+		if rate, err := common.VATRateAt(region, code, day); err == nil && rate.Equal(percentage) {
+			return true
+		}
+	}
+	return false
+}
+
+func vatRateKey(region, code string, percentage decimal.Decimal) string {
+	return region + " " + code + " " + percentage.String()
+}
+
+// usedVATRates returns the VAT rates applied by the lines of the source
+// documents, keyed by [vatRateKey].
+func usedVATRates(a *saft.AuditFile) map[string]bool {
+	used := map[string]bool{}
+	add := func(taxType, region, code string, percentage *saft.SafdecimalType) {
+		if taxType == "IVA" && percentage != nil {
+			used[vatRateKey(region, code, percentage.Decimal)] = true
+		}
+	}
+
+	sd := a.SourceDocuments
+	if sd == nil {
+		return used
+	}
+	if sd.SalesInvoices != nil {
+		for _, inv := range sd.SalesInvoices.Invoice {
+			for _, l := range inv.Line {
+				add(l.Tax.TaxType, l.Tax.TaxCountryRegion, l.Tax.TaxCode, l.Tax.TaxPercentage)
+			}
+		}
+	}
+	if sd.MovementOfGoods != nil {
+		for _, m := range sd.MovementOfGoods.StockMovement {
+			for _, l := range m.Line {
+				if l.Tax != nil {
+					add(string(l.Tax.TaxType), l.Tax.TaxCountryRegion, string(l.Tax.TaxCode), &l.Tax.TaxPercentage)
+				}
+			}
+		}
+	}
+	if sd.WorkingDocuments != nil {
+		for _, w := range sd.WorkingDocuments.WorkDocument {
+			for _, l := range w.Line {
+				if l.Tax != nil {
+					add(l.Tax.TaxType, l.Tax.TaxCountryRegion, l.Tax.TaxCode, l.Tax.TaxPercentage)
+				}
+			}
+		}
+	}
+	if sd.Payments != nil {
+		for _, p := range sd.Payments.Payment {
+			for _, l := range p.Line {
+				if l.Tax != nil {
+					add(l.Tax.TaxType, l.Tax.TaxCountryRegion, string(l.Tax.TaxCode), l.Tax.TaxPercentage)
+				}
+			}
+		}
+	}
+	return used
+}
@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/common"
+	"github.com/hestiatechnology/autoridadetributaria/saft"
+)
+
+// ValidateInvoiceExemptions checks the TaxExemptionCode and
+// TaxExemptionReason of every invoice line against
+// [common.VatExemptionRules].
+func ValidateInvoiceExemptions(a *saft.AuditFile) error {
+	if a.SourceDocuments == nil || a.SourceDocuments.SalesInvoices == nil {
+		return nil
+	}
+
+	countries := make(map[string]string, len(a.MasterFiles.Customer))
+	for _, customer := range a.MasterFiles.Customer {
+		countries[string(customer.CustomerId)] = string(customer.BillingAddress.Country)
+	}
+
+	for _, invoice := range a.SourceDocuments.SalesInvoices.Invoice {
+		for _, line := range invoice.Line {
+			zero := line.Tax.TaxPercentage != nil && line.Tax.TaxPercentage.IsZero()
+			if line.TaxExemptionCode == nil {
+				if zero && line.Tax.TaxType == "IVA" {
+					return fmt.Errorf("saft: missing Invoice.Line.TaxExemptionCode in %s line %d", invoice.InvoiceNo, line.LineNumber)
+				}
+				continue
+			}
+
+			if line.TaxExemptionReason == nil {
+				return fmt.Errorf("saft: missing Invoice.Line.TaxExemptionReason in %s line %d", invoice.InvoiceNo, line.LineNumber)
+			}
+
+			u := common.VatExemptionUse{
+				Code:            string(*line.TaxExemptionCode),
+				Reason:          string(*line.TaxExemptionReason),
+				TaxCode:         line.Tax.TaxCode,
+				CustomerCountry: countries[string(invoice.CustomerId)],
+				Date:            lineDate(line.TaxPointDate.Time, invoice.InvoiceDate.Time),
+			}
+			if line.Tax.TaxPercentage != nil {
+				u.TaxPercentage = line.Tax.TaxPercentage.Decimal
+			}
+			if err := common.ValidateVatExemption(u); err != nil {
+				return fmt.Errorf("saft: invalid Invoice.Line.TaxExemptionCode in %s line %d: %w", invoice.InvoiceNo, line.LineNumber, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateInvoiceTaxRegions checks that the Portuguese VAT region of every
+// invoice line (PT, PT-AC or PT-MA) matches the postal code the goods were
+// shipped from. Invoices without a Portuguese ShipFrom address are skipped.
+func ValidateInvoiceTaxRegions(a *saft.AuditFile) error {
+	if a.SourceDocuments == nil || a.SourceDocuments.SalesInvoices == nil {
+		return nil
+	}
+
+	for _, invoice := range a.SourceDocuments.SalesInvoices.Invoice {
+		if invoice.ShipFrom == nil || invoice.ShipFrom.Address == nil {
+			continue
+		}
+		from := invoice.ShipFrom.Address
+		if from.Country != "PT" || common.IsUnknown(string(from.PostalCode)) {
+			continue
+		}
+
+		for _, line := range invoice.Line {
+			switch line.Tax.TaxCountryRegion {
+			case common.RegionMainland, common.RegionAzores, common.RegionMadeira:
+			default:
+				continue
+			}
+			if line.Tax.TaxType != "IVA" {
+				continue
+			}
+			if err := common.CheckTaxRegion(string(from.PostalCode), line.Tax.TaxCountryRegion); err != nil {
+				return fmt.Errorf("saft: invalid Invoice.Line.Tax.TaxCountryRegion in %s line %d: %w", invoice.InvoiceNo, line.LineNumber, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// lineDate returns the tax point date of a line, or the document date when
+// the line has none.
+func lineDate(taxPoint, document time.Time) time.Time {
+	if taxPoint.IsZero() {
+		return document
+	}
+	return taxPoint
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"reflect"
 	"slices"
 	"time"
 
@@ -12,7 +13,7 @@ import (
 
 func ValidatePayments(a *saft.AuditFile) error {
 
-	if a.SourceDocuments == nil && a.SourceDocuments.Payments == nil {
+	if a.SourceDocuments == nil || a.SourceDocuments.Payments == nil {
 		return nil
 	}
 
@@ -79,7 +80,7 @@ func ValidatePayments(a *saft.AuditFile) error {
 			return fmt.Errorf("saft: invalid Payment.PaymentType")
 		}
 
-		if payment.DocumentStatus == (saft.PaymentDocumentStatus{}) {
+		if reflect.ValueOf(payment.DocumentStatus).IsZero() {
 			return fmt.Errorf("saft: missing Payment.DocumentStatus")
 		}
 
@@ -216,7 +217,7 @@ func ValidatePayments(a *saft.AuditFile) error {
 						return fmt.Errorf("saft: invalid PaymentLine.Tax: TaxAmount present but TaxType is not IS")
 					}
 
-					if (*line.Tax.TaxAmount).LessThan(decimal.NewFromInt(0)) {
+					if line.Tax.TaxAmount != nil && (*line.Tax.TaxAmount).LessThan(decimal.NewFromInt(0)) {
 						return fmt.Errorf("saft: invalid PaymentLine.Tax.TaxAmount: %s", line.Tax.TaxAmount)
 					}
 
@@ -241,18 +242,25 @@ func ValidatePayments(a *saft.AuditFile) error {
 
 				// Check if TaxExemption is valid
 				if line.TaxExemptionCode != nil && line.TaxExemptionReason != nil {
-					for _, exemption := range common.VatExemptionCodes {
-						if saft.SafptportugueseTaxExemptionCode(exemption.Code) == *line.TaxExemptionCode && saft.SafptportugueseTaxExemptionReason(exemption.Description) == *line.TaxExemptionReason {
-							break
+					u := common.VatExemptionUse{
+						Code:   string(*line.TaxExemptionCode),
+						Reason: string(*line.TaxExemptionReason),
+					}
+					if line.Tax != nil {
+						u.TaxCode = string(line.Tax.TaxCode)
+						if line.Tax.TaxPercentage != nil {
+							u.TaxPercentage = line.Tax.TaxPercentage.Decimal
 						}
 					}
-					return fmt.Errorf("saft: invalid PaymentLine.TaxExemptionCode or PaymentLine.TaxExemptionReason")
+					if err := common.ValidateVatExemption(u); err != nil {
+						return fmt.Errorf("saft: invalid PaymentLine.TaxExemptionCode or PaymentLine.TaxExemptionReason: %w", err)
+					}
 				}
 			}
 
 		}
 
-		if payment.DocumentTotals == (saft.PaymentDocumentTotals{}) {
+		if reflect.ValueOf(payment.DocumentTotals).IsZero() {
 			return fmt.Errorf("saft: missing Payment.DocumentTotals")
 		}
 
@@ -0,0 +1,280 @@
+package saft
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrMergeConflict = errors.New("saft: merge conflict")
+
+// GlobalTaxEntity is the Header TaxEntity of a file covering every
+// establishment of the company.
+const GlobalTaxEntity = "Global"
+
+// Merge consolidates the audit files of one company and fiscal year, such
+// as those of each establishment or point of sale, into a single file.
+//
+// Customers, suppliers, products and general ledger accounts are unified
+// by their ID; the same ID defined differently in two files is a conflict.
+// The balances of an account are the sums of its balances in each file.
+// Tax table entries are unified by type, region, code and percentage.
+// Documents and transactions are concatenated in file order, and a number
+// used in more than one file is a conflict. Conflicts are reported
+// together, each wrapping [ErrMergeConflict].
+//
+// The Header is that of the first file with the TaxEntity set to
+// [GlobalTaxEntity] and the dates spanning all files. The control totals
+// are recomputed.
+func Merge(files ...*AuditFile) (*AuditFile, error) {
+	if len(files) == 0 {
+		return nil, errors.New("saft: nothing to merge")
+	}
+
+	m := &merger{
+		out:       &AuditFile{Header: files[0].Header},
+		customers: map[string]definition{},
+		suppliers: map[string]definition{},
+		products:  map[string]definition{},
+		accounts:  map[string]definition{},
+		taxes:     map[[4]string]bool{},
+		journals:  map[string]int{},
+		numbers:   map[string]int{},
+	}
+	for i, f := range files {
+		m.header(i, f)
+		m.masterFiles(i, f)
+		m.generalLedgerEntries(i, f)
+		m.sourceDocuments(i, f)
+	}
+	if len(m.errs) > 0 {
+		return nil, errors.Join(m.errs...)
+	}
+
+	h := &m.out.Header
+	h.TaxEntity = GlobalTaxEntity
+	h.DateCreated = SafptdateSpan(civilDay(time.Now()))
+	m.out.RecomputeTotals()
+	return m.out, nil
+}
+
+// merger accumulates the merged file. journals maps journal IDs to their
+// index in the output and numbers maps document and transaction numbers to
+// the file that used them first.
+type merger struct {
+	out *AuditFile
+
+	customers map[string]definition
+	suppliers map[string]definition
+	products  map[string]definition
+	accounts  map[string]definition
+	taxes     map[[4]string]bool
+	journals  map[string]int
+	numbers   map[string]int
+
+	errs []error
+}
+
+func (m *merger) conflict(format string, args ...any) {
+	m.errs = append(m.errs, fmt.Errorf("%w: "+format, append([]any{ErrMergeConflict}, args...)...))
+}
+
+func (m *merger) header(i int, f *AuditFile) {
+	h, in := &m.out.Header, &f.Header
+	if in.TaxRegistrationNumber != h.TaxRegistrationNumber {
+		m.conflict("file %d is for company %d, not %d", i+1, in.TaxRegistrationNumber, h.TaxRegistrationNumber)
+	}
+	if in.FiscalYear != h.FiscalYear {
+		m.conflict("file %d is for fiscal year %s, not %s", i+1, in.FiscalYear, h.FiscalYear)
+	}
+	if in.TaxAccountingBasis != h.TaxAccountingBasis {
+		m.conflict("file %d has TaxAccountingBasis %s, not %s", i+1, in.TaxAccountingBasis, h.TaxAccountingBasis)
+	}
+	if time.Time(in.StartDate).Before(time.Time(h.StartDate)) {
+		h.StartDate = in.StartDate
+	}
+	if time.Time(in.EndDate).After(time.Time(h.EndDate)) {
+		h.EndDate = in.EndDate
+	}
+}
+
+// definition locates a master file record in the output: its index and
+// the file that defined it.
+type definition struct {
+	index, file int
+}
+
+func (m *merger) masterFiles(i int, f *AuditFile) {
+	out, in := &m.out.MasterFiles, &f.MasterFiles
+
+	if in.GeneralLedgerAccounts != nil {
+		if out.GeneralLedgerAccounts == nil {
+			out.GeneralLedgerAccounts = &GeneralLedgerAccounts{TaxonomyReference: in.GeneralLedgerAccounts.TaxonomyReference}
+		}
+		for _, a := range in.GeneralLedgerAccounts.Account {
+			m.account(i, a, &out.GeneralLedgerAccounts.Account)
+		}
+	}
+	for _, c := range in.Customer {
+		unify(m, "customer", m.customers, string(c.CustomerId), i, c, &out.Customer)
+	}
+	for _, s := range in.Supplier {
+		unify(m, "supplier", m.suppliers, string(s.SupplierId), i, s, &out.Supplier)
+	}
+	for _, p := range in.Product {
+		unify(m, "product", m.products, string(p.ProductCode), i, p, &out.Product)
+	}
+
+	if in.TaxTable != nil {
+		if out.TaxTable == nil {
+			out.TaxTable = &TaxTable{}
+		}
+		for _, e := range in.TaxTable.TaxTableEntry {
+			key := [4]string{e.TaxType, e.TaxCountryRegion, string(e.TaxCode), ""}
+			if e.TaxPercentage != nil {
+				key[3] = e.TaxPercentage.String()
+			}
+			if !m.taxes[key] {
+				m.taxes[key] = true
+				out.TaxTable.TaxTableEntry = append(out.TaxTable.TaxTableEntry, e)
+			}
+		}
+	}
+}
+
+// unify appends v, defined by file i, to records unless its id is already
+// defined; a different definition of the same id is a conflict.
+func unify[T any](m *merger, kind string, ids map[string]definition, id string, i int, v T, records *[]T) {
+	d, ok := ids[id]
+	if !ok {
+		ids[id] = definition{index: len(*records), file: i}
+		*records = append(*records, v)
+		return
+	}
+	if !sameXML((*records)[d.index], v) {
+		m.conflict("%s %s differs between files %d and %d", kind, id, d.file+1, i+1)
+	}
+}
+
+// account is [unify] for general ledger accounts, whose balances differ
+// between the files of each establishment: only the other fields must match,
+// and the balances are added up.
+func (m *merger) account(i int, a GeneralLedgerAccountsAccount, accounts *[]GeneralLedgerAccountsAccount) {
+	id := string(a.AccountId)
+	d, ok := m.accounts[id]
+	if !ok {
+		m.accounts[id] = definition{index: len(*accounts), file: i}
+		*accounts = append(*accounts, a)
+		return
+	}
+
+	out := &(*accounts)[d.index]
+	described := a
+	described.OpeningDebitBalance, described.OpeningCreditBalance = out.OpeningDebitBalance, out.OpeningCreditBalance
+	described.ClosingDebitBalance, described.ClosingCreditBalance = out.ClosingDebitBalance, out.ClosingCreditBalance
+	if !sameXML(*out, described) {
+		m.conflict("account %s differs between files %d and %d", id, d.file+1, i+1)
+		return
+	}
+	out.OpeningDebitBalance.Decimal = out.OpeningDebitBalance.Add(a.OpeningDebitBalance.Decimal)
+	out.OpeningCreditBalance.Decimal = out.OpeningCreditBalance.Add(a.OpeningCreditBalance.Decimal)
+	out.ClosingDebitBalance.Decimal = out.ClosingDebitBalance.Add(a.ClosingDebitBalance.Decimal)
+	out.ClosingCreditBalance.Decimal = out.ClosingCreditBalance.Add(a.ClosingCreditBalance.Decimal)
+}
+
+// sameXML reports whether a and b marshal to the same XML, which ignores
+// differences such as trailing zeros in decimals.
+func sameXML(a, b any) bool {
+	x, err := xml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := xml.Marshal(b)
+	return err == nil && bytes.Equal(x, y)
+}
+
+// number records that file i uses a document or transaction number,
+// reporting a conflict when another file used it first.
+func (m *merger) number(kind, no string, i int) {
+	key := kind + " " + no
+	if j, ok := m.numbers[key]; ok {
+		if j != i {
+			m.conflict("%s %s is in files %d and %d", kind, no, j+1, i+1)
+		}
+		return
+	}
+	m.numbers[key] = i
+}
+
+func (m *merger) generalLedgerEntries(i int, f *AuditFile) {
+	in := f.GeneralLedgerEntries
+	if in == nil {
+		return
+	}
+	if m.out.GeneralLedgerEntries == nil {
+		m.out.GeneralLedgerEntries = &GeneralLedgerEntries{}
+	}
+	out := m.out.GeneralLedgerEntries
+	for _, j := range in.Journal {
+		for _, t := range j.Transaction {
+			m.number("transaction", string(t.TransactionId), i)
+		}
+		if k, ok := m.journals[string(j.JournalId)]; ok {
+			out.Journal[k].Transaction = append(out.Journal[k].Transaction, j.Transaction...)
+			continue
+		}
+		m.journals[string(j.JournalId)] = len(out.Journal)
+		j.Transaction = append([]JournalTransaction(nil), j.Transaction...)
+		out.Journal = append(out.Journal, j)
+	}
+}
+
+func (m *merger) sourceDocuments(i int, f *AuditFile) {
+	in := f.SourceDocuments
+	if in == nil {
+		return
+	}
+	if m.out.SourceDocuments == nil {
+		m.out.SourceDocuments = &SourceDocuments{}
+	}
+	out := m.out.SourceDocuments
+
+	if in.SalesInvoices != nil {
+		if out.SalesInvoices == nil {
+			out.SalesInvoices = &SourceDocumentsSalesInvoices{}
+		}
+		for _, inv := range in.SalesInvoices.Invoice {
+			m.number("invoice", inv.InvoiceNo, i)
+			out.SalesInvoices.Invoice = append(out.SalesInvoices.Invoice, inv)
+		}
+	}
+	if in.MovementOfGoods != nil {
+		if out.MovementOfGoods == nil {
+			out.MovementOfGoods = &SourceDocumentsMovementOfGoods{}
+		}
+		for _, mv := range in.MovementOfGoods.StockMovement {
+			m.number("movement", mv.DocumentNumber, i)
+			out.MovementOfGoods.StockMovement = append(out.MovementOfGoods.StockMovement, mv)
+		}
+	}
+	if in.WorkingDocuments != nil {
+		if out.WorkingDocuments == nil {
+			out.WorkingDocuments = &SourceDocumentsWorkingDocuments{}
+		}
+		for _, w := range in.WorkingDocuments.WorkDocument {
+			m.number("work document", w.DocumentNumber, i)
+			out.WorkingDocuments.WorkDocument = append(out.WorkingDocuments.WorkDocument, w)
+		}
+	}
+	if in.Payments != nil {
+		if out.Payments == nil {
+			out.Payments = &SourceDocumentsPayments{}
+		}
+		for _, p := range in.Payments.Payment {
+			m.number("payment", p.PaymentRefNo, i)
+			out.Payments.Payment = append(out.Payments.Payment, p)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package saft
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestMerge(t *testing.T) {
+	a, b := periodTestFile(), periodTestFile()
+	a.Header.TaxEntity = "Loja 1"
+	b.Header.TaxEntity = "Loja 2"
+	b.Header.EndDate = SafptdateSpan(time.Date(2024, time.April, 30, 0, 0, 0, 0, time.UTC))
+	for i := range b.SourceDocuments.SalesInvoices.Invoice {
+		b.SourceDocuments.SalesInvoices.Invoice[i].InvoiceNo += "B"
+	}
+	b.MasterFiles.Customer = append(b.MasterFiles.Customer, Customer{CustomerId: "C4"})
+
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Header.TaxEntity != GlobalTaxEntity {
+		t.Errorf("TaxEntity = %s, want %s", m.Header.TaxEntity, GlobalTaxEntity)
+	}
+	if end := time.Time(m.Header.EndDate); end.Month() != time.April {
+		t.Errorf("EndDate = %s, want April", end)
+	}
+	if n := len(m.MasterFiles.Customer); n != 4 {
+		t.Errorf("%d customers, want 4", n)
+	}
+	if n := len(m.MasterFiles.TaxTable.TaxTableEntry); n != 2 {
+		t.Errorf("%d tax table entries, want 2", n)
+	}
+	if s := m.SourceDocuments.SalesInvoices; s.NumberOfEntries != 6 || s.TotalCredit.IntPart() != 400 {
+		t.Errorf("NumberOfEntries = %d, TotalCredit = %s, want 6 and 400", s.NumberOfEntries, s.TotalCredit)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	a, b := periodTestFile(), periodTestFile()
+	b.MasterFiles.Customer[0].CompanyName = "Other"
+
+	_, err := Merge(a, b)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("Merge() error = %v, want a conflict", err)
+	}
+	// One customer and three invoice numbers.
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 4 {
+		t.Errorf("%d conflicts, want 4: %v", n, err)
+	}
+}
+
+func TestMergeAccounts(t *testing.T) {
+	account := func(description string, opening, closing int64) GeneralLedgerAccountsAccount {
+		return GeneralLedgerAccountsAccount{
+			AccountId: "211", AccountDescription: SafpttextTypeMandatoryMax100Car(description), GroupingCategory: "GM",
+			OpeningDebitBalance: SafmonetaryType{Decimal: decimal.NewFromInt(opening)},
+			ClosingDebitBalance: SafmonetaryType{Decimal: decimal.NewFromInt(closing)},
+		}
+	}
+	a, b := periodTestFile(), periodTestFile()
+	for i := range b.SourceDocuments.SalesInvoices.Invoice {
+		b.SourceDocuments.SalesInvoices.Invoice[i].InvoiceNo += "B"
+	}
+	a.MasterFiles.GeneralLedgerAccounts = &GeneralLedgerAccounts{Account: []GeneralLedgerAccountsAccount{account("Clientes", 100, 250)}}
+	b.MasterFiles.GeneralLedgerAccounts = &GeneralLedgerAccounts{Account: []GeneralLedgerAccountsAccount{account("Clientes", 20, 0)}}
+
+	m, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := m.MasterFiles.GeneralLedgerAccounts.Account
+	if len(accounts) != 1 || accounts[0].OpeningDebitBalance.IntPart() != 120 || accounts[0].ClosingDebitBalance.IntPart() != 250 {
+		t.Errorf("accounts = %+v, want 211 with balances 120 and 250", accounts)
+	}
+	if a.MasterFiles.GeneralLedgerAccounts.Account[0].OpeningDebitBalance.IntPart() != 100 {
+		t.Error("Merge() modified the first file")
+	}
+
+	b.MasterFiles.GeneralLedgerAccounts.Account[0].AccountDescription = "Fornecedores"
+	if _, err := Merge(a, b); !errors.Is(err, ErrMergeConflict) {
+		t.Errorf("Merge() with different descriptions: error = %v, want a conflict", err)
+	}
+}
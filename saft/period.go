@@ -0,0 +1,439 @@
+package saft
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidPeriod = errors.New("saft: invalid period")
+
+// Filter returns a copy of a with the documents and transactions dated from
+// start to end, both inclusive. Customers, suppliers, products and tax table
+// entries no longer referenced are dropped, the control totals are
+// recomputed and the Header StartDate and EndDate are set to the range.
+//
+// The copy shares the documents it keeps with a; clone a first if either
+// will be modified.
+func (a *AuditFile) Filter(start, end time.Time) (*AuditFile, error) {
+	start, end = civilDay(start), civilDay(end)
+	if end.Before(start) {
+		return nil, fmt.Errorf("%w: %s is before %s", ErrInvalidPeriod, end.Format(time.DateOnly), start.Format(time.DateOnly))
+	}
+	return a.filter(start, end, func(_ uint, date time.Time) bool {
+		date = civilDay(date)
+		return !date.Before(start) && !date.After(end)
+	}), nil
+}
+
+// FilterPeriod is like [AuditFile.Filter] for the accounting period (month)
+// 1 to 12 of the fiscal year. Documents are selected by their Period
+// element, or by date when it is absent.
+//
+// The fiscal year is taken to start in January of Header.FiscalYear, or of
+// the year of Header.StartDate when FiscalYear is empty, so period 3 is
+// March whatever month the file starts in. The result covers the part of
+// the period within the file; a period outside it is an error. Files of
+// fiscal years starting in another month must be split with
+// [AuditFile.Filter] instead.
+func (a *AuditFile) FilterPeriod(period uint) (*AuditFile, error) {
+	if period < 1 || period > 12 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPeriod, period)
+	}
+	first, last, err := a.span()
+	if err != nil {
+		return nil, err
+	}
+	year := first.Year()
+	if a.Header.FiscalYear != "" {
+		if year, err = strconv.Atoi(a.Header.FiscalYear); err != nil {
+			return nil, fmt.Errorf("%w: FiscalYear %q", ErrInvalidPeriod, a.Header.FiscalYear)
+		}
+	}
+
+	start := time.Date(year, time.Month(period), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, -1)
+	if start.After(last) || end.Before(first) {
+		return nil, fmt.Errorf("%w: period %d of %d is outside %s to %s", ErrInvalidPeriod, period, year, first.Format(time.DateOnly), last.Format(time.DateOnly))
+	}
+	start, end = maxDate(start, first), minDate(end, last)
+
+	return a.filter(start, end, func(p uint, date time.Time) bool {
+		if p != 0 {
+			return p == period
+		}
+		date = civilDay(date)
+		return !date.Before(start) && !date.After(end)
+	}), nil
+}
+
+// SplitMonthly splits a into one file per calendar month between
+// Header.StartDate and Header.EndDate, as for the monthly communication of
+// invoices.
+func (a *AuditFile) SplitMonthly() ([]*AuditFile, error) {
+	first, last, err := a.span()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*AuditFile
+	for month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(last); month = month.AddDate(0, 1, 0) {
+		f, err := a.Filter(maxDate(month, first), minDate(month.AddDate(0, 1, -1), last))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// RecomputeTotals sets the control totals of every section from its
+// documents. Cancelled (A) and, except for payments, invoiced (F) documents
+// are counted in NumberOfEntries but left out of the debit and credit
+// totals and of the movement lines and quantities.
+func (a *AuditFile) RecomputeTotals() {
+	if gl := a.GeneralLedgerEntries; gl != nil {
+		gl.NumberOfEntries = 0
+		var debit, credit decimal.Decimal
+		for _, j := range gl.Journal {
+			gl.NumberOfEntries += uint64(len(j.Transaction))
+			for _, t := range j.Transaction {
+				for _, l := range t.Lines.DebitLine {
+					debit = debit.Add(l.DebitAmount.Decimal)
+				}
+				for _, l := range t.Lines.CreditLine {
+					credit = credit.Add(l.CreditAmount.Decimal)
+				}
+			}
+		}
+		gl.TotalDebit = SafmonetaryType{Decimal: debit}
+		gl.TotalCredit = SafmonetaryType{Decimal: credit}
+	}
+
+	sd := a.SourceDocuments
+	if sd == nil {
+		return
+	}
+
+	if s := sd.SalesInvoices; s != nil {
+		var debit, credit decimal.Decimal
+		for _, inv := range s.Invoice {
+			if excludedStatus(inv.DocumentStatus.InvoiceStatus) {
+				continue
+			}
+			for _, l := range inv.Line {
+				debit, credit = addAmounts(debit, credit, l.DebitAmount, l.CreditAmount)
+			}
+		}
+		s.NumberOfEntries = uint64(len(s.Invoice))
+		s.TotalDebit = SafmonetaryType{Decimal: debit}
+		s.TotalCredit = SafmonetaryType{Decimal: credit}
+	}
+
+	if s := sd.MovementOfGoods; s != nil {
+		var lines uint64
+		var quantity decimal.Decimal
+		for _, m := range s.StockMovement {
+			if excludedStatus(m.DocumentStatus.MovementStatus) {
+				continue
+			}
+			lines += uint64(len(m.Line))
+			for _, l := range m.Line {
+				quantity = quantity.Add(l.Quantity.Decimal)
+			}
+		}
+		s.NumberOfMovementLines = lines
+		s.TotalQuantityIssued = SafdecimalType{Decimal: quantity}
+	}
+
+	if s := sd.WorkingDocuments; s != nil {
+		var debit, credit decimal.Decimal
+		for _, w := range s.WorkDocument {
+			if excludedStatus(w.DocumentStatus.WorkStatus) {
+				continue
+			}
+			for _, l := range w.Line {
+				debit, credit = addAmounts(debit, credit, l.DebitAmount, l.CreditAmount)
+			}
+		}
+		s.NumberOfEntries = uint64(len(s.WorkDocument))
+		s.TotalDebit = SafmonetaryType{Decimal: debit}
+		s.TotalCredit = SafmonetaryType{Decimal: credit}
+	}
+
+	if s := sd.Payments; s != nil {
+		var debit, credit decimal.Decimal
+		for _, p := range s.Payment {
+			if p.DocumentStatus.PaymentStatus == PaymentStatusCancelled {
+				continue
+			}
+			for _, l := range p.Line {
+				debit, credit = addAmounts(debit, credit, l.DebitAmount, l.CreditAmount)
+			}
+		}
+		s.NumberOfEntries = uint64(len(s.Payment))
+		s.TotalDebit = SafmonetaryType{Decimal: debit}
+		s.TotalCredit = SafmonetaryType{Decimal: credit}
+	}
+}
+
+// filter copies a keeping the documents and transactions for which keep,
+// called with their Period (0 when absent) and date, returns true.
+func (a *AuditFile) filter(start, end time.Time, keep func(period uint, date time.Time) bool) *AuditFile {
+	b := *a
+	b.Header.StartDate = SafptdateSpan(start)
+	b.Header.EndDate = SafptdateSpan(end)
+
+	if gl := a.GeneralLedgerEntries; gl != nil {
+		entries := *gl
+		entries.Journal = nil
+		for _, j := range gl.Journal {
+			journal := j
+			journal.Transaction = nil
+			for _, t := range j.Transaction {
+				if keep(uint(t.Period), t.TransactionDate.Time) {
+					journal.Transaction = append(journal.Transaction, t)
+				}
+			}
+			if len(journal.Transaction) > 0 {
+				entries.Journal = append(entries.Journal, journal)
+			}
+		}
+		b.GeneralLedgerEntries = &entries
+	}
+
+	if sd := a.SourceDocuments; sd != nil {
+		docs := *sd
+		if s := sd.SalesInvoices; s != nil {
+			section := *s
+			section.Invoice = nil
+			for _, inv := range s.Invoice {
+				if keep(inv.Period, inv.InvoiceDate.Time) {
+					section.Invoice = append(section.Invoice, inv)
+				}
+			}
+			docs.SalesInvoices = &section
+		}
+		if s := sd.MovementOfGoods; s != nil {
+			section := *s
+			section.StockMovement = nil
+			for _, m := range s.StockMovement {
+				if keep(m.Period, m.MovementDate.Time) {
+					section.StockMovement = append(section.StockMovement, m)
+				}
+			}
+			docs.MovementOfGoods = &section
+		}
+		if s := sd.WorkingDocuments; s != nil {
+			section := *s
+			section.WorkDocument = nil
+			for _, w := range s.WorkDocument {
+				if keep(w.Period, w.WorkDate.Time) {
+					section.WorkDocument = append(section.WorkDocument, w)
+				}
+			}
+			docs.WorkingDocuments = &section
+		}
+		if s := sd.Payments; s != nil {
+			section := *s
+			section.Payment = nil
+			for _, p := range s.Payment {
+				if keep(p.Period, p.TransactionDate.Time) {
+					section.Payment = append(section.Payment, p)
+				}
+			}
+			docs.Payments = &section
+		}
+		b.SourceDocuments = &docs
+	}
+
+	b.pruneMasterFiles()
+	b.RecomputeTotals()
+	return &b
+}
+
+// pruneMasterFiles drops the customers, suppliers, products and tax table
+// entries that no transaction or document references. The general ledger
+// accounts are kept, as their balances cover the whole fiscal year.
+func (a *AuditFile) pruneMasterFiles() {
+	customers := map[string]bool{}
+	suppliers := map[string]bool{}
+	products := map[string]bool{}
+	taxes := taxUsage{}
+
+	if gl := a.GeneralLedgerEntries; gl != nil {
+		for _, j := range gl.Journal {
+			for _, t := range j.Transaction {
+				if t.CustomerId != nil {
+					customers[string(*t.CustomerId)] = true
+				}
+				if t.SupplierId != nil {
+					suppliers[string(*t.SupplierId)] = true
+				}
+			}
+		}
+	}
+
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			for _, inv := range sd.SalesInvoices.Invoice {
+				customers[string(inv.CustomerId)] = true
+				for _, l := range inv.Line {
+					products[string(l.ProductCode)] = true
+					taxes.add(l.Tax.TaxType, l.Tax.TaxCountryRegion, l.Tax.TaxCode, l.Tax.TaxPercentage)
+				}
+			}
+		}
+		if sd.MovementOfGoods != nil {
+			for _, m := range sd.MovementOfGoods.StockMovement {
+				if m.CustomerId != nil {
+					customers[string(*m.CustomerId)] = true
+				}
+				if m.SupplierId != nil {
+					suppliers[string(*m.SupplierId)] = true
+				}
+				for _, l := range m.Line {
+					products[string(l.ProductCode)] = true
+					if l.Tax != nil {
+						pct := l.Tax.TaxPercentage
+						taxes.add(string(l.Tax.TaxType), l.Tax.TaxCountryRegion, string(l.Tax.TaxCode), &pct)
+					}
+				}
+			}
+		}
+		if sd.WorkingDocuments != nil {
+			for _, w := range sd.WorkingDocuments.WorkDocument {
+				customers[string(w.CustomerId)] = true
+				for _, l := range w.Line {
+					products[string(l.ProductCode)] = true
+					if l.Tax != nil {
+						taxes.add(l.Tax.TaxType, l.Tax.TaxCountryRegion, l.Tax.TaxCode, l.Tax.TaxPercentage)
+					}
+				}
+			}
+		}
+		if sd.Payments != nil {
+			for _, p := range sd.Payments.Payment {
+				customers[string(p.CustomerId)] = true
+				for _, l := range p.Line {
+					if l.Tax != nil {
+						taxes.add(l.Tax.TaxType, l.Tax.TaxCountryRegion, string(l.Tax.TaxCode), l.Tax.TaxPercentage)
+					}
+				}
+			}
+		}
+	}
+
+	mf := &a.MasterFiles
+	var keptCustomers []Customer
+	for _, c := range mf.Customer {
+		if customers[string(c.CustomerId)] {
+			keptCustomers = append(keptCustomers, c)
+		}
+	}
+	mf.Customer = keptCustomers
+
+	var keptSuppliers []Supplier
+	for _, s := range mf.Supplier {
+		if suppliers[string(s.SupplierId)] {
+			keptSuppliers = append(keptSuppliers, s)
+		}
+	}
+	mf.Supplier = keptSuppliers
+
+	var keptProducts []Product
+	for _, p := range mf.Product {
+		if products[string(p.ProductCode)] {
+			keptProducts = append(keptProducts, p)
+		}
+	}
+	mf.Product = keptProducts
+
+	if mf.TaxTable != nil {
+		var entries []TaxTableEntry
+		for _, e := range mf.TaxTable.TaxTableEntry {
+			if taxes.uses(e) {
+				entries = append(entries, e)
+			}
+		}
+		mf.TaxTable = nil
+		if len(entries) > 0 {
+			mf.TaxTable = &TaxTable{TaxTableEntry: entries}
+		}
+	}
+}
+
+// taxUsage records the tax percentages used by each tax type, region and
+// code; "" stands for lines without a percentage, such as stamp duty
+// amounts.
+type taxUsage map[[3]string]map[string]bool
+
+func (u taxUsage) add(taxType, region, code string, pct *SafdecimalType) {
+	key := [3]string{taxType, region, code}
+	if u[key] == nil {
+		u[key] = map[string]bool{}
+	}
+	p := ""
+	if pct != nil {
+		p = pct.String()
+	}
+	u[key][p] = true
+}
+
+// uses reports whether a line uses e. An entry without a percentage matches
+// any line of its code, and so does any entry of a code whose lines have no
+// percentage.
+func (u taxUsage) uses(e TaxTableEntry) bool {
+	pcts, ok := u[[3]string{e.TaxType, e.TaxCountryRegion, string(e.TaxCode)}]
+	if !ok {
+		return false
+	}
+	if e.TaxPercentage == nil || pcts[""] {
+		return true
+	}
+	return pcts[e.TaxPercentage.String()]
+}
+
+// span returns the Header StartDate and EndDate.
+func (a *AuditFile) span() (time.Time, time.Time, error) {
+	first, last := time.Time(a.Header.StartDate), time.Time(a.Header.EndDate)
+	if first.IsZero() || last.IsZero() || last.Before(first) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: header dates %s to %s", ErrInvalidPeriod, first.Format(time.DateOnly), last.Format(time.DateOnly))
+	}
+	return civilDay(first), civilDay(last), nil
+}
+
+func excludedStatus(status string) bool {
+	return status == "A" || status == "F"
+}
+
+func addAmounts(debit, credit decimal.Decimal, d, c *SafmonetaryType) (decimal.Decimal, decimal.Decimal) {
+	if d != nil {
+		debit = debit.Add(d.Decimal)
+	}
+	if c != nil {
+		credit = credit.Add(c.Decimal)
+	}
+	return debit, credit
+}
+
+func civilDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func minDate(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxDate(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
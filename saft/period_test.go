@@ -0,0 +1,178 @@
+package saft
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func periodTestFile() *AuditFile {
+	date := func(month time.Month, day int) SafdateType {
+		return SafdateType{Time: time.Date(2024, month, day, 0, 0, 0, 0, time.UTC)}
+	}
+	amount := func(v int64) *SafmonetaryType {
+		return &SafmonetaryType{Decimal: decimal.NewFromInt(v)}
+	}
+	pct := func(v int64) *SafdecimalType {
+		return &SafdecimalType{Decimal: decimal.NewFromInt(v)}
+	}
+	invoice := func(no, customer, product, code string, rate int64, date SafdateType, status string) SalesInvoicesInvoice {
+		return SalesInvoicesInvoice{
+			InvoiceNo:      no,
+			InvoiceDate:    date,
+			CustomerId:     SafpttextTypeMandatoryMax30Car(customer),
+			DocumentStatus: InvoiceDocumentStatus{InvoiceStatus: status},
+			Line: []InvoiceLine{{
+				ProductCode:  SafpttextTypeMandatoryMax60Car(product),
+				CreditAmount: amount(100),
+				Tax:          Tax{TaxType: TaxTypeIVA, TaxCountryRegion: "PT", TaxCode: code, TaxPercentage: pct(rate)},
+			}},
+		}
+	}
+
+	return &AuditFile{
+		Header: Header{
+			StartDate: SafptdateSpan(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			EndDate:   SafptdateSpan(time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)),
+		},
+		MasterFiles: AuditFileMasterFiles{
+			Customer: []Customer{{CustomerId: "C1"}, {CustomerId: "C2"}, {CustomerId: "C3"}},
+			Product:  []Product{{ProductCode: "P1"}, {ProductCode: "P2"}},
+			TaxTable: &TaxTable{TaxTableEntry: []TaxTableEntry{
+				{TaxType: TaxTypeIVA, TaxCountryRegion: "PT", TaxCode: "NOR", TaxPercentage: pct(23)},
+				{TaxType: TaxTypeIVA, TaxCountryRegion: "PT", TaxCode: "RED", TaxPercentage: pct(6)},
+			}},
+		},
+		SourceDocuments: &SourceDocuments{
+			SalesInvoices: &SourceDocumentsSalesInvoices{
+				NumberOfEntries: 3,
+				Invoice: []SalesInvoicesInvoice{
+					invoice("FT A/1", "C1", "P1", "NOR", 23, date(time.January, 10), "N"),
+					invoice("FT A/2", "C1", "P1", "NOR", 23, date(time.January, 31), "A"),
+					invoice("FT A/3", "C2", "P2", "RED", 6, date(time.February, 1), "N"),
+				},
+			},
+		},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	a := periodTestFile()
+	jan, err := a.Filter(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.January, 31, 23, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoices := jan.SourceDocuments.SalesInvoices
+	if invoices.NumberOfEntries != 2 || len(invoices.Invoice) != 2 {
+		t.Errorf("NumberOfEntries = %d, %d invoices, want 2", invoices.NumberOfEntries, len(invoices.Invoice))
+	}
+	if !invoices.TotalCredit.Equal(decimal.NewFromInt(100)) || !invoices.TotalDebit.IsZero() {
+		t.Errorf("totals = %s/%s, want 0/100 without the cancelled invoice", invoices.TotalDebit, invoices.TotalCredit)
+	}
+	if n := len(jan.MasterFiles.Customer); n != 1 || jan.MasterFiles.Customer[0].CustomerId != "C1" {
+		t.Errorf("customers = %+v, want C1", jan.MasterFiles.Customer)
+	}
+	if n := len(jan.MasterFiles.Product); n != 1 {
+		t.Errorf("%d products, want 1", n)
+	}
+	if entries := jan.MasterFiles.TaxTable.TaxTableEntry; len(entries) != 1 || entries[0].TaxCode != "NOR" {
+		t.Errorf("tax table = %+v, want NOR", entries)
+	}
+	if got := time.Time(jan.Header.EndDate); !got.Equal(time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("EndDate = %s", got)
+	}
+
+	// The original is unchanged.
+	if len(a.MasterFiles.Customer) != 3 || len(a.SourceDocuments.SalesInvoices.Invoice) != 3 || len(a.MasterFiles.TaxTable.TaxTableEntry) != 2 {
+		t.Error("Filter modified the original file")
+	}
+
+	if _, err := a.Filter(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("Filter(end before start) error = %v", err)
+	}
+}
+
+func TestFilterPeriod(t *testing.T) {
+	a := periodTestFile()
+	a.SourceDocuments.SalesInvoices.Invoice[0].Period = 2
+
+	feb, err := a.FilterPeriod(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []string
+	for _, inv := range feb.SourceDocuments.SalesInvoices.Invoice {
+		numbers = append(numbers, inv.InvoiceNo)
+	}
+	if len(numbers) != 2 || numbers[0] != "FT A/1" || numbers[1] != "FT A/3" {
+		t.Errorf("period 2 invoices = %v, want [FT A/1 FT A/3]", numbers)
+	}
+
+	if _, err := a.FilterPeriod(13); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("FilterPeriod(13) error = %v", err)
+	}
+	if _, err := a.FilterPeriod(4); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("FilterPeriod(4) of a file ending in March error = %v", err)
+	}
+}
+
+func TestFilterPeriodMidYear(t *testing.T) {
+	// A file from March to December: period 3 is still March.
+	a := periodTestFile()
+	a.Header.FiscalYear = "2024"
+	a.Header.StartDate = SafptdateSpan(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+	a.Header.EndDate = SafptdateSpan(time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC))
+	inv := &a.SourceDocuments.SalesInvoices.Invoice[2]
+	inv.InvoiceDate = SafdateType{Time: time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)}
+	inv.Period = 3
+	a.SourceDocuments.SalesInvoices.Invoice = a.SourceDocuments.SalesInvoices.Invoice[2:]
+
+	mar, err := a.FilterPeriod(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := time.Time(mar.Header.StartDate), time.Time(mar.Header.EndDate)
+	if start.Month() != time.March || start.Day() != 1 || end.Month() != time.March || end.Day() != 31 {
+		t.Errorf("period 3 = %s to %s, want March", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	}
+	if n := len(mar.SourceDocuments.SalesInvoices.Invoice); n != 1 {
+		t.Errorf("period 3 has %d invoices, want 1", n)
+	}
+
+	may, err := a.FilterPeriod(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(may.SourceDocuments.SalesInvoices.Invoice); n != 0 || time.Time(may.Header.StartDate).Month() != time.May {
+		t.Errorf("period 5 = %s with %d invoices, want May without any", time.Time(may.Header.StartDate).Format(time.DateOnly), n)
+	}
+
+	if _, err := a.FilterPeriod(1); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("FilterPeriod(1) of a file starting in March error = %v", err)
+	}
+	a.Header.FiscalYear = "x"
+	if _, err := a.FilterPeriod(3); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("FilterPeriod() with FiscalYear x error = %v", err)
+	}
+}
+
+func TestSplitMonthly(t *testing.T) {
+	files, err := periodTestFile().SplitMonthly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("%d files, want 3", len(files))
+	}
+	for i, want := range []uint64{2, 1, 0} {
+		if got := files[i].SourceDocuments.SalesInvoices.NumberOfEntries; got != want {
+			t.Errorf("month %d: NumberOfEntries = %d, want %d", i+1, got, want)
+		}
+	}
+	if files[2].MasterFiles.TaxTable != nil || len(files[2].MasterFiles.Customer) != 0 {
+		t.Errorf("empty month kept master files: %+v", files[2].MasterFiles)
+	}
+}
@@ -0,0 +1,285 @@
+package saft
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrProfile = errors.New("saft: file does not match its TaxAccountingBasis")
+
+// Section is a table of the SAF-T structure, numbered as in Portaria
+// 302/2016. Sections combine as a set.
+type Section uint
+
+const (
+	SectionGeneralLedgerAccounts Section = 1 << iota // 2.1
+	SectionCustomer                                  // 2.2
+	SectionSupplier                                  // 2.3
+	SectionProduct                                   // 2.4
+	SectionTaxTable                                  // 2.5
+	SectionGeneralLedgerEntries                      // 3
+	SectionSalesInvoices                             // 4.1
+	SectionMovementOfGoods                           // 4.2
+	SectionWorkingDocuments                          // 4.3
+	SectionPayments                                  // 4.4
+)
+
+var sectionNames = []string{
+	"GeneralLedgerAccounts", "Customer", "Supplier", "Product", "TaxTable",
+	"GeneralLedgerEntries", "SalesInvoices", "MovementOfGoods", "WorkingDocuments", "Payments",
+}
+
+// String returns the element names of the sections, separated by "|".
+func (s Section) String() string {
+	var names []string
+	for i, name := range sectionNames {
+		if s&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+const (
+	masterFiles = SectionCustomer | SectionSupplier | SectionProduct | SectionTaxTable
+	accounting  = SectionGeneralLedgerAccounts | SectionGeneralLedgerEntries
+	documents   = SectionSalesInvoices | SectionMovementOfGoods | SectionWorkingDocuments | SectionPayments
+)
+
+// Profile is the content of a SAF-T file for one TaxAccountingBasis, after
+// section 1 f) to i) of Portaria 302/2016 (Annex I). The Header is always
+// present.
+type Profile struct {
+	Basis string
+	Name  string
+	// Sections lists the tables the file may contain; others are forbidden.
+	Sections Section
+	// Required lists the tables the file must contain. Empty document
+	// sections are added by [Profile.Prune].
+	Required Section
+	// HeaderComment requires the Header comment, which identifies the
+	// actual issuer of documents issued by third parties.
+	HeaderComment bool
+}
+
+// Profiles holds the profile of every TaxAccountingBasis.
+var Profiles = map[string]*Profile{
+	SaftAccounting: {
+		Basis:    SaftAccounting,
+		Name:     "Contabilidade",
+		Sections: accounting | SectionCustomer | SectionSupplier | SectionTaxTable | SectionPayments,
+		Required: accounting,
+	},
+	SaftInvoicingThirdParties: {
+		Basis:         SaftInvoicingThirdParties,
+		Name:          "Faturação emitida por terceiros",
+		Sections:      masterFiles | documents,
+		Required:      SectionSalesInvoices,
+		HeaderComment: true,
+	},
+	SaftInvoicing: {
+		Basis:    SaftInvoicing,
+		Name:     "Faturação",
+		Sections: masterFiles | documents,
+		Required: SectionSalesInvoices,
+	},
+	SaftIntegrated: {
+		Basis:    SaftIntegrated,
+		Name:     "Contabilidade integrada com a faturação",
+		Sections: accounting | masterFiles | documents,
+		Required: accounting,
+	},
+	SaftInvoicingParcial: {
+		Basis:    SaftInvoicingParcial,
+		Name:     "Faturação parcial",
+		Sections: masterFiles | documents,
+		Required: SectionSalesInvoices,
+	},
+	SaftPayments: {
+		Basis:    SaftPayments,
+		Name:     "Recibos",
+		Sections: SectionCustomer | SectionTaxTable | SectionPayments,
+		Required: SectionPayments,
+	},
+	SaftSelfBilling: {
+		Basis:    SaftSelfBilling,
+		Name:     "Autofaturação",
+		Sections: SectionCustomer | SectionProduct | SectionTaxTable | SectionSalesInvoices,
+		Required: SectionSalesInvoices,
+	},
+	SaftTransportDocuments: {
+		Basis:    SaftTransportDocuments,
+		Name:     "Documentos de transporte",
+		Sections: masterFiles | SectionMovementOfGoods,
+		Required: SectionMovementOfGoods,
+	},
+}
+
+// ProfileOf returns the profile of a TaxAccountingBasis.
+func ProfileOf(basis string) (*Profile, error) {
+	p, ok := Profiles[basis]
+	if !ok {
+		return nil, fmt.Errorf("saft: invalid Header.TaxAccountingBasis: %s", basis)
+	}
+	return p, nil
+}
+
+// Sections returns the sections present in a.
+func (a *AuditFile) Sections() Section {
+	var s Section
+	mf := &a.MasterFiles
+	if mf.GeneralLedgerAccounts != nil {
+		s |= SectionGeneralLedgerAccounts
+	}
+	if len(mf.Customer) > 0 {
+		s |= SectionCustomer
+	}
+	if len(mf.Supplier) > 0 {
+		s |= SectionSupplier
+	}
+	if len(mf.Product) > 0 {
+		s |= SectionProduct
+	}
+	if mf.TaxTable != nil {
+		s |= SectionTaxTable
+	}
+	if a.GeneralLedgerEntries != nil {
+		s |= SectionGeneralLedgerEntries
+	}
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			s |= SectionSalesInvoices
+		}
+		if sd.MovementOfGoods != nil {
+			s |= SectionMovementOfGoods
+		}
+		if sd.WorkingDocuments != nil {
+			s |= SectionWorkingDocuments
+		}
+		if sd.Payments != nil {
+			s |= SectionPayments
+		}
+	}
+	return s
+}
+
+// Prune removes from a the sections p forbids and adds the document
+// sections it requires, empty. It sets Header.TaxAccountingBasis to
+// p.Basis.
+func (p *Profile) Prune(a *AuditFile) {
+	a.Header.TaxAccountingBasis = p.Basis
+	keep := func(s Section) bool { return p.Sections&s != 0 }
+
+	mf := &a.MasterFiles
+	if !keep(SectionGeneralLedgerAccounts) {
+		mf.GeneralLedgerAccounts = nil
+	}
+	if !keep(SectionCustomer) {
+		mf.Customer = nil
+	}
+	if !keep(SectionSupplier) {
+		mf.Supplier = nil
+	}
+	if !keep(SectionProduct) {
+		mf.Product = nil
+	}
+	if !keep(SectionTaxTable) {
+		mf.TaxTable = nil
+	}
+	if !keep(SectionGeneralLedgerEntries) {
+		a.GeneralLedgerEntries = nil
+	}
+
+	if a.SourceDocuments == nil {
+		if p.Required&documents == 0 {
+			return
+		}
+		a.SourceDocuments = &SourceDocuments{}
+	}
+	sd := a.SourceDocuments
+	switch {
+	case !keep(SectionSalesInvoices):
+		sd.SalesInvoices = nil
+	case sd.SalesInvoices == nil && p.Required&SectionSalesInvoices != 0:
+		sd.SalesInvoices = &SourceDocumentsSalesInvoices{}
+	}
+	switch {
+	case !keep(SectionMovementOfGoods):
+		sd.MovementOfGoods = nil
+	case sd.MovementOfGoods == nil && p.Required&SectionMovementOfGoods != 0:
+		sd.MovementOfGoods = &SourceDocumentsMovementOfGoods{}
+	}
+	if !keep(SectionWorkingDocuments) {
+		sd.WorkingDocuments = nil
+	}
+	switch {
+	case !keep(SectionPayments):
+		sd.Payments = nil
+	case sd.Payments == nil && p.Required&SectionPayments != 0:
+		sd.Payments = &SourceDocumentsPayments{}
+	}
+	if a.Sections()&documents == 0 {
+		a.SourceDocuments = nil
+	}
+}
+
+// Check reports the ways a departs from p: another TaxAccountingBasis,
+// forbidden or missing sections, or a missing Header comment. The errors
+// wrap [ErrProfile].
+func (p *Profile) Check(a *AuditFile) error {
+	var errs []error
+	if a.Header.TaxAccountingBasis != p.Basis {
+		errs = append(errs, fmt.Errorf("%w: TaxAccountingBasis is %s, not %s", ErrProfile, a.Header.TaxAccountingBasis, p.Basis))
+	}
+	present := a.Sections()
+	if s := present &^ p.Sections; s != 0 {
+		errs = append(errs, fmt.Errorf("%w: %s (%s) files must not contain %s", ErrProfile, p.Basis, p.Name, s))
+	}
+	if s := p.Required &^ present; s != 0 {
+		errs = append(errs, fmt.Errorf("%w: %s (%s) files must contain %s", ErrProfile, p.Basis, p.Name, s))
+	}
+	if p.HeaderComment && (a.Header.HeaderComment == nil || *a.Header.HeaderComment == "") {
+		errs = append(errs, fmt.Errorf("%w: %s (%s) files must identify the issuer in HeaderComment", ErrProfile, p.Basis, p.Name))
+	}
+	return errors.Join(errs...)
+}
+
+// CheckProfile checks a against the profile of its TaxAccountingBasis.
+func (a *AuditFile) CheckProfile() error {
+	p, err := ProfileOf(a.Header.TaxAccountingBasis)
+	if err != nil {
+		return err
+	}
+	return p.Check(a)
+}
+
+// Export prunes a copy of a to the profile of basis, checks it against the
+// profile, validates it and writes it to w. a itself is not modified.
+func (a *AuditFile) Export(w io.Writer, basis string, opts XMLOptions) error {
+	p, err := ProfileOf(basis)
+	if err != nil {
+		return err
+	}
+	c := a.pruneCopy()
+	p.Prune(c)
+	if err := p.Check(c); err != nil {
+		return err
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return c.WriteXML(w, opts)
+}
+
+// pruneCopy returns a copy of a that [Profile.Prune] can modify without
+// affecting a. The sections themselves are shared, Prune only replaces them.
+func (a *AuditFile) pruneCopy() *AuditFile {
+	c := *a
+	if a.SourceDocuments != nil {
+		sd := *a.SourceDocuments
+		c.SourceDocuments = &sd
+	}
+	return &c
+}
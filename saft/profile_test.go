@@ -0,0 +1,83 @@
+package saft
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProfilePrune(t *testing.T) {
+	a := periodTestFile()
+	a.Header.TaxAccountingBasis = SaftIntegrated
+	a.MasterFiles.GeneralLedgerAccounts = &GeneralLedgerAccounts{}
+	a.MasterFiles.Supplier = []Supplier{{SupplierId: "S1"}}
+	a.GeneralLedgerEntries = &GeneralLedgerEntries{}
+	if err := a.CheckProfile(); err != nil {
+		t.Fatal(err)
+	}
+
+	Profiles[SaftInvoicing].Prune(a)
+	want := SectionCustomer | SectionSupplier | SectionProduct | SectionTaxTable | SectionSalesInvoices
+	if got := a.Sections(); got != want {
+		t.Errorf("F sections = %s, want %s", got, want)
+	}
+	if err := a.CheckProfile(); err != nil {
+		t.Error(err)
+	}
+
+	Profiles[SaftPayments].Prune(a)
+	want = SectionCustomer | SectionTaxTable | SectionPayments
+	if got := a.Sections(); got != want {
+		t.Errorf("R sections = %s, want %s", got, want)
+	}
+
+	a = periodTestFile()
+	Profiles[SaftAccounting].Prune(a)
+	if a.SourceDocuments != nil {
+		t.Errorf("C file keeps an empty SourceDocuments")
+	}
+}
+
+func TestProfileCheck(t *testing.T) {
+	a := periodTestFile()
+	a.Header.TaxAccountingBasis = SaftAccounting
+	err := a.CheckProfile()
+	if !errors.Is(err, ErrProfile) {
+		t.Fatalf("CheckProfile() = %v, want ErrProfile", err)
+	}
+	// Forbidden Product and SalesInvoices, missing ledger.
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("%d errors, want 2: %v", n, err)
+	}
+
+	a.Header.TaxAccountingBasis = SaftInvoicingThirdParties
+	if err := a.CheckProfile(); err == nil || !strings.Contains(err.Error(), "HeaderComment") {
+		t.Errorf("CheckProfile() = %v, want a missing HeaderComment", err)
+	}
+
+	a.Header.TaxAccountingBasis = "X"
+	if err := a.CheckProfile(); err == nil {
+		t.Error("CheckProfile() accepted TaxAccountingBasis X")
+	}
+}
+
+func TestExportInvoicing(t *testing.T) {
+	a := periodTestFile()
+	a.Header.TaxAccountingBasis = SaftIntegrated
+	a.Header.DateCreated = a.Header.EndDate
+	a.GeneralLedgerEntries = &GeneralLedgerEntries{}
+
+	out, err := a.ExportInvoicing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "<TaxAccountingBasis>F</TaxAccountingBasis>") || !strings.Contains(out, "FT A/3") {
+		t.Errorf("ExportInvoicing() did not write the invoicing file:\n%s", out)
+	}
+	if strings.Contains(out, "GeneralLedgerEntries") {
+		t.Error("ExportInvoicing() kept GeneralLedgerEntries")
+	}
+	if a.Header.TaxAccountingBasis != SaftIntegrated || a.GeneralLedgerEntries == nil {
+		t.Error("ExportInvoicing() modified the file")
+	}
+}
@@ -0,0 +1,206 @@
+package saft
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/net/html/charset"
+)
+
+const namespace = "urn:OECD:StandardAuditFile-Tax:PT_1.04_01"
+
+// elements returns the elements of an XML document in document order, as
+// their path from the root followed by their text, with attributes in
+// brackets and elements outside the SAF-T namespace prefixed with theirs.
+// Numbers are normalized, so "12.50" and "12.5" compare equal.
+func elements(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charset.NewReaderLabel
+
+	var out, path []string
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			name := tok.Name.Local
+			if tok.Name.Space != namespace {
+				name = "{" + tok.Name.Space + "}" + name
+			}
+			var attrs []string
+			for _, a := range tok.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" && a.Name.Space != "http://www.w3.org/2001/XMLSchema-instance" {
+					attrs = append(attrs, a.Name.Local+"="+a.Value)
+				}
+			}
+			if len(attrs) > 0 {
+				sort.Strings(attrs)
+				name += "[" + strings.Join(attrs, ",") + "]"
+			}
+			path = append(path, name)
+			text.Reset()
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if n, err := decimal.NewFromString(s); err == nil {
+				s = n.String()
+			}
+			out = append(out, strings.Join(path, "/")+"="+s)
+			path = path[:len(path)-1]
+			text.Reset()
+		}
+	}
+}
+
+// TestRoundTrip decodes each golden file, encodes it again and checks that
+// the result has the same elements, in the same order, with the same
+// values. full.xml has every element of the schema, with both branches of
+// every choice, and unknown elements; minimal.xml has only the mandatory
+// ones; zero.xml has every number set to zero.
+func TestRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("testdata/roundtrip/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "test/real_saft.xml")
+
+	for _, file := range files {
+		for _, encoding := range []string{"utf-8", "windows-1252"} {
+			t.Run(filepath.Base(file)+"/"+encoding, func(t *testing.T) {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a, err := Decode(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var out bytes.Buffer
+				if err := a.WriteXML(&out, XMLOptions{Encoding: encoding, Indent: "  "}); err != nil {
+					t.Fatal(err)
+				}
+
+				want, got := elements(t, bytes.NewReader(data)), elements(t, &out)
+				for i := range max(len(want), len(got)) {
+					var w, g string
+					if i < len(want) {
+						w = want[i]
+					}
+					if i < len(got) {
+						g = got[i]
+					}
+					if w != g {
+						t.Fatalf("element %d:\n got %s\nwant %s", i, g, w)
+					}
+				}
+			})
+		}
+	}
+}
+
+// xsdNode is a node of the schema, for TestRoundTripCoverage.
+type xsdNode struct {
+	XMLName  xml.Name
+	Name     string    `xml:"name,attr"`
+	Ref      string    `xml:"ref,attr"`
+	Children []xsdNode `xml:",any"`
+}
+
+// used adds the elements declared or referenced below n.
+func (n xsdNode) used(names map[string]bool) {
+	for _, c := range n.Children {
+		if c.XMLName.Local == "element" {
+			names[c.Name+c.Ref] = true
+		}
+		c.used(names)
+	}
+}
+
+// TestRoundTripCoverage checks that full.xml has every element the schema
+// uses in a file.
+func TestRoundTripCoverage(t *testing.T) {
+	data, err := os.ReadFile("saftpt1.04_01.xsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema xsdNode
+	if err := xml.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	// Top-level declarations count only where they are referenced.
+	used := map[string]bool{"AuditFile": true}
+	for _, n := range schema.Children {
+		n.used(used)
+	}
+
+	f, err := os.Open("testdata/roundtrip/full.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	present := map[string]bool{}
+	for _, e := range elements(t, f) {
+		path, _, _ := strings.Cut(e, "=")
+		for _, name := range strings.Split(path, "/") {
+			present[name] = true
+		}
+	}
+
+	for name := range used {
+		if !present[name] {
+			t.Errorf("full.xml has no %s element", name)
+		}
+	}
+	// The schema uses 187 elements; fewer means it was not parsed.
+	if len(used) < 187 {
+		t.Errorf("only %d elements found in the schema", len(used))
+	}
+}
+
+func TestUnknownElements(t *testing.T) {
+	a, err := FromXML("testdata/roundtrip/full.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(a.MasterFiles.TaxTable.TaxTableEntry); n != 1 {
+		t.Errorf("%d tax table entries, want 1", n)
+	}
+	if u := a.Unknown; len(u) != 1 || u[0].XMLName.Local != "Signature" {
+		t.Errorf("AuditFile.Unknown = %+v, want Signature", u)
+	}
+	ship := a.SourceDocuments.SalesInvoices.Invoice[0].ShipTo
+	if len(ship.Unknown) != 1 || ship.Unknown[0].XMLName.Space != "urn:example:extension" {
+		t.Errorf("ShipTo.Unknown = %+v, want one extension", ship.Unknown)
+	}
+	want := [][2]string{{"W1", "L1"}, {"", "L2"}, {"W3", ""}}
+	if len(ship.Location) != len(want) {
+		t.Fatalf("ShipTo.Location = %+v, want 3 pairs", ship.Location)
+	}
+	for i, l := range ship.Location {
+		if got := [2]string{text(l.WarehouseId), text(l.LocationId)}; got != want[i] {
+			t.Errorf("ShipTo.Location[%d] = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func text[T ~string](p *T) string {
+	if p == nil {
+		return ""
+	}
+	return string(*p)
+}
@@ -2,7 +2,10 @@ package saft
 
 import (
 	"encoding/xml"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"golang.org/x/net/html/charset"
 	"golang.org/x/text/encoding/charmap"
@@ -13,42 +16,71 @@ import (
 //	return "", nil
 //}
 
+// ExportInvoicing returns a as an invoicing (F) SAF-T file, the monthly
+// e-Fatura communication: accounting sections are removed from a copy of a
+// and the result is checked and validated. See [AuditFile.Export].
 func (a *AuditFile) ExportInvoicing() (string, error) {
-	a.MasterFiles.GeneralLedgerAccounts = nil
-	a.GeneralLedgerEntries = nil
-	a.MasterFiles.Supplier = nil
-
-	// Export the AuditFile to a file
-	if err := a.Validate(); err != nil {
+	var b strings.Builder
+	if err := a.Export(&b, SaftInvoicing, XMLOptions{Indent: "    "}); err != nil {
 		return "", err
 	}
-
-	return "", nil
+	return b.String(), nil
 }
 
 func (a *AuditFile) ToXML() (string, error) {
-	a.XmlnsXsd = "http://www.w3.org/2001/XMLSchema"
-	a.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
-	//a.XsiSchemaLocation = "urn:OECD:StandardAuditFile-Tax:PT_1.04_01 ../saftpt1.04_01.xsd"
-	// Convert the AuditFile to XML
-
 	// Validation must be done manually
 	if err := a.Validate(); err != nil {
 		return "", err
 	}
 
-	out, err := xml.MarshalIndent(a, "", "    ")
-	if err != nil {
+	var b strings.Builder
+	if err := a.WriteXML(&b, XMLOptions{Indent: "    "}); err != nil {
 		return "", err
 	}
+	return b.String(), nil
+}
+
+// XMLOptions controls how [AuditFile.WriteXML] formats a file.
+type XMLOptions struct {
+	// Encoding is "windows-1252" (the default, as required by the AT) or
+	// "utf-8".
+	Encoding string
+	// Indent is the indentation of nested elements; empty writes the file
+	// on a single line.
+	Indent string
+}
 
-	encoder := charmap.Windows1252.NewEncoder()
-	outStr, err := encoder.String(string(out))
+// WriteXML writes a as a SAF-T XML document to w without validating it.
+func (a *AuditFile) WriteXML(w io.Writer, opts XMLOptions) error {
+	a.XmlnsXsd = "http://www.w3.org/2001/XMLSchema"
+	a.XmlnsXsi = "http://www.w3.org/2001/XMLSchema-instance"
+	//a.XsiSchemaLocation = "urn:OECD:StandardAuditFile-Tax:PT_1.04_01 ../saftpt1.04_01.xsd"
+
+	out, err := xml.MarshalIndent(a, "", opts.Indent)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return `<?xml version="1.0" encoding="Windows-1252"?>` + "\n" + outStr, nil
+	var prolog string
+	switch strings.ToLower(opts.Encoding) {
+	case "", "windows-1252", "cp1252":
+		encoded, err := charmap.Windows1252.NewEncoder().Bytes(out)
+		if err != nil {
+			return err
+		}
+		out = encoded
+		prolog = `<?xml version="1.0" encoding="Windows-1252"?>`
+	case "utf-8", "utf8":
+		prolog = `<?xml version="1.0" encoding="UTF-8"?>`
+	default:
+		return fmt.Errorf("saft: unsupported encoding: %s", opts.Encoding)
+	}
+
+	if _, err := io.WriteString(w, prolog+"\n"); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
 }
 
 func FromXML(xmlFile string) (*AuditFile, error) {
@@ -59,9 +91,14 @@ func FromXML(xmlFile string) (*AuditFile, error) {
 	}
 	defer file.Close()
 
-	// Unmarshal the XML file
+	return Decode(file)
+}
+
+// Decode reads a SAF-T XML document from r. The encoding declared in the
+// XML prolog (usually Windows-1252 or UTF-8) is honoured.
+func Decode(r io.Reader) (*AuditFile, error) {
 	a := &AuditFile{}
-	decoder := xml.NewDecoder(file)
+	decoder := xml.NewDecoder(r)
 	decoder.CharsetReader = charset.NewReaderLabel
 	if err := decoder.Decode(a); err != nil {
 		return nil, err
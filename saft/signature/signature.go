@@ -4,8 +4,10 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -14,6 +16,7 @@ import (
 var (
 	ErrPrivateKeyNotSet = errors.New("private key not set")
 	ErrInvoiceNoNotSet  = errors.New("invoice number not set")
+	ErrInvalidHash      = errors.New("invalid hash")
 )
 
 func SignFiscalDocument(r *rsa.PrivateKey, date time.Time, systemDate time.Time, invoiceNo string, grossTotal decimal.Decimal, lastHash string) ([]byte, error) {
@@ -33,10 +36,8 @@ func SignFiscalDocument(r *rsa.PrivateKey, date time.Time, systemDate time.Time,
 		return nil, ErrInvoiceNoNotSet
 	}
 
-	// Sign in this format 2010-05-18;2010-05-18T11:22:19;FAC 001/14;3.12;lastHash
-	message := date.Format("2006-01-02") + ";" + systemDate.Format("2006-01-02T15:04:05") + ";" + invoiceNo + ";" + grossTotal.StringFixed(2) + ";" + lastHash
-	hashed := crypto.SHA1.New().Sum([]byte(message))
-	signature, err := rsa.SignPKCS1v15(rand.Reader, r, crypto.SHA1, hashed)
+	hashed := sha1.Sum([]byte(Message(date, systemDate, invoiceNo, grossTotal, lastHash)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, r, crypto.SHA1, hashed[:])
 	if err != nil {
 		return nil, err
 	}
@@ -45,3 +46,24 @@ func SignFiscalDocument(r *rsa.PrivateKey, date time.Time, systemDate time.Time,
 	signatureBase64 := base64.StdEncoding.EncodeToString(signature)
 	return []byte(signatureBase64), nil
 }
+
+// Message returns the text signed for a document, in the format
+// 2010-05-18;2010-05-18T11:22:19;FAC 001/14;3.12;lastHash.
+func Message(date time.Time, systemDate time.Time, invoiceNo string, grossTotal decimal.Decimal, lastHash string) string {
+	return date.Format("2006-01-02") + ";" + systemDate.Format("2006-01-02T15:04:05") + ";" + invoiceNo + ";" + grossTotal.StringFixed(2) + ";" + lastHash
+}
+
+// VerifyFiscalDocument checks hash, the base64 signature of a document,
+// against the software producer's public key. lastHash is the hash of the
+// previous document of the same series, empty for the first one.
+func VerifyFiscalDocument(pub *rsa.PublicKey, date time.Time, systemDate time.Time, invoiceNo string, grossTotal decimal.Decimal, lastHash, hash string) error {
+	signature, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidHash, invoiceNo, err)
+	}
+	hashed := sha1.Sum([]byte(Message(date, systemDate, invoiceNo, grossTotal, lastHash)))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA1, hashed[:], signature); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidHash, invoiceNo)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSignAndVerifyFiscalDocument(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2010, time.May, 18, 0, 0, 0, 0, time.UTC)
+	system := time.Date(2010, time.May, 18, 11, 22, 19, 0, time.UTC)
+	total := decimal.RequireFromString("3.12")
+
+	if got, want := Message(date, system, "FAC 001/14", total, ""), "2010-05-18;2010-05-18T11:22:19;FAC 001/14;3.12;"; got != want {
+		t.Errorf("Message() = %q, want %q", got, want)
+	}
+
+	first, err := SignFiscalDocument(key, date, system, "FAC 001/14", total, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SignFiscalDocument(key, date, system.Add(time.Minute), "FAC 001/15", total, string(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyFiscalDocument(&key.PublicKey, date, system, "FAC 001/14", total, "", string(first)); err != nil {
+		t.Errorf("VerifyFiscalDocument(first) = %v", err)
+	}
+	if err := VerifyFiscalDocument(&key.PublicKey, date, system.Add(time.Minute), "FAC 001/15", total, string(first), string(second)); err != nil {
+		t.Errorf("VerifyFiscalDocument(second) = %v", err)
+	}
+	if err := VerifyFiscalDocument(&key.PublicKey, date, system.Add(time.Minute), "FAC 001/15", total, "", string(second)); !errors.Is(err, ErrInvalidHash) {
+		t.Errorf("VerifyFiscalDocument with a broken chain = %v, want ErrInvalidHash", err)
+	}
+}
+
+// TestSignFiscalDocumentDigest checks that the signature is over the SHA-1
+// digest of the message, which any PKCS #1 v1.5 verifier accepts.
+func TestSignFiscalDocumentDigest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2010, time.May, 18, 0, 0, 0, 0, time.UTC)
+	system := time.Date(2010, time.May, 18, 11, 22, 19, 0, time.UTC)
+	total := decimal.RequireFromString("3.12")
+
+	hash, err := SignFiscalDocument(key, date, system, "FAC 001/14", total, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha1.Sum([]byte("2010-05-18;2010-05-18T11:22:19;FAC 001/14;3.12;"))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA1, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify over the SHA-1 digest: %v", err)
+	}
+}
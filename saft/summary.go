@@ -0,0 +1,177 @@
+package saft
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// DocumentTypeSummary totals the documents of one type in a section.
+type DocumentTypeSummary struct {
+	// Section is SalesInvoices, MovementOfGoods, WorkingDocuments or Payments.
+	Section string
+	// Type is the InvoiceType, MovementType, WorkType or PaymentType.
+	Type  string
+	Count int
+	// Excluded counts the cancelled (A) and invoiced (F) documents, which are
+	// left out of the totals as in the section's TotalDebit and TotalCredit.
+	Excluded   int
+	NetTotal   decimal.Decimal
+	TaxPayable decimal.Decimal
+	GrossTotal decimal.Decimal
+}
+
+// TaxRateSummary totals the sales invoice lines of one tax rate.
+type TaxRateSummary struct {
+	TaxType          string
+	TaxCountryRegion string
+	TaxCode          string
+	TaxPercentage    decimal.Decimal
+	Lines            int
+	// TaxBase is credit minus debit amounts, so credit notes subtract.
+	TaxBase decimal.Decimal
+	// TaxAmount is TaxBase times the percentage, plus stamp duty amounts.
+	TaxAmount decimal.Decimal
+}
+
+// Summary gives the size of an audit file and its totals by document type
+// and by tax rate.
+type Summary struct {
+	Customers       int
+	Suppliers       int
+	Products        int
+	TaxTableEntries int
+	Accounts        int
+	Journals        int
+	Transactions    int
+	Documents       []DocumentTypeSummary
+	TaxRates        []TaxRateSummary
+}
+
+// Summary computes the counts and totals of a.
+func (a *AuditFile) Summary() Summary {
+	s := Summary{
+		Customers: len(a.MasterFiles.Customer),
+		Suppliers: len(a.MasterFiles.Supplier),
+		Products:  len(a.MasterFiles.Product),
+	}
+	if a.MasterFiles.TaxTable != nil {
+		s.TaxTableEntries = len(a.MasterFiles.TaxTable.TaxTableEntry)
+	}
+	if a.MasterFiles.GeneralLedgerAccounts != nil {
+		s.Accounts = len(a.MasterFiles.GeneralLedgerAccounts.Account)
+	}
+	if a.GeneralLedgerEntries != nil {
+		s.Journals = len(a.GeneralLedgerEntries.Journal)
+		for _, j := range a.GeneralLedgerEntries.Journal {
+			s.Transactions += len(j.Transaction)
+		}
+	}
+
+	docs := map[[2]string]*DocumentTypeSummary{}
+	add := func(section, docType, status string, net, tax, gross decimal.Decimal) {
+		key := [2]string{section, docType}
+		d, ok := docs[key]
+		if !ok {
+			d = &DocumentTypeSummary{Section: section, Type: docType}
+			docs[key] = d
+		}
+		d.Count++
+		if excludedStatus(status) {
+			d.Excluded++
+			return
+		}
+		d.NetTotal = d.NetTotal.Add(net)
+		d.TaxPayable = d.TaxPayable.Add(tax)
+		d.GrossTotal = d.GrossTotal.Add(gross)
+	}
+
+	rates := map[[4]string]*TaxRateSummary{}
+	hundred := decimal.NewFromInt(100)
+
+	if sd := a.SourceDocuments; sd != nil {
+		if sd.SalesInvoices != nil {
+			for _, inv := range sd.SalesInvoices.Invoice {
+				t := inv.DocumentTotals
+				add("SalesInvoices", inv.InvoiceType, inv.DocumentStatus.InvoiceStatus, t.NetTotal.Decimal, t.TaxPayable.Decimal, t.GrossTotal.Decimal)
+				if excludedStatus(inv.DocumentStatus.InvoiceStatus) {
+					continue
+				}
+
+				for _, line := range inv.Line {
+					var pct decimal.Decimal
+					if line.Tax.TaxPercentage != nil {
+						pct = line.Tax.TaxPercentage.Decimal
+					}
+					key := [4]string{line.Tax.TaxType, line.Tax.TaxCountryRegion, line.Tax.TaxCode, pct.String()}
+					r, ok := rates[key]
+					if !ok {
+						r = &TaxRateSummary{TaxType: key[0], TaxCountryRegion: key[1], TaxCode: key[2], TaxPercentage: pct}
+						rates[key] = r
+					}
+
+					var base decimal.Decimal
+					if line.CreditAmount != nil {
+						base = base.Add(line.CreditAmount.Decimal)
+					}
+					if line.DebitAmount != nil {
+						base = base.Sub(line.DebitAmount.Decimal)
+					}
+					r.Lines++
+					r.TaxBase = r.TaxBase.Add(base)
+					r.TaxAmount = r.TaxAmount.Add(base.Mul(pct).Div(hundred))
+					if line.Tax.TaxAmount != nil {
+						r.TaxAmount = r.TaxAmount.Add(line.Tax.TaxAmount.Decimal)
+					}
+				}
+			}
+		}
+		if sd.MovementOfGoods != nil {
+			for _, m := range sd.MovementOfGoods.StockMovement {
+				t := m.DocumentTotals
+				add("MovementOfGoods", m.MovementType, m.DocumentStatus.MovementStatus, t.NetTotal.Decimal, t.TaxPayable.Decimal, t.GrossTotal.Decimal)
+			}
+		}
+		if sd.WorkingDocuments != nil {
+			for _, w := range sd.WorkingDocuments.WorkDocument {
+				t := w.DocumentTotals
+				add("WorkingDocuments", w.WorkType, w.DocumentStatus.WorkStatus, t.NetTotal.Decimal, t.TaxPayable.Decimal, t.GrossTotal.Decimal)
+			}
+		}
+		if sd.Payments != nil {
+			for _, p := range sd.Payments.Payment {
+				t := p.DocumentTotals
+				add("Payments", string(p.PaymentType), p.DocumentStatus.PaymentStatus, t.NetTotal.Decimal, t.TaxPayable.Decimal, t.GrossTotal.Decimal)
+			}
+		}
+	}
+
+	for _, d := range docs {
+		s.Documents = append(s.Documents, *d)
+	}
+	sort.Slice(s.Documents, func(i, j int) bool {
+		if s.Documents[i].Section != s.Documents[j].Section {
+			return s.Documents[i].Section < s.Documents[j].Section
+		}
+		return s.Documents[i].Type < s.Documents[j].Type
+	})
+
+	for _, r := range rates {
+		s.TaxRates = append(s.TaxRates, *r)
+	}
+	sort.Slice(s.TaxRates, func(i, j int) bool {
+		a, b := s.TaxRates[i], s.TaxRates[j]
+		if a.TaxType != b.TaxType {
+			return a.TaxType < b.TaxType
+		}
+		if a.TaxCountryRegion != b.TaxCountryRegion {
+			return a.TaxCountryRegion < b.TaxCountryRegion
+		}
+		if a.TaxCode != b.TaxCode {
+			return a.TaxCode < b.TaxCode
+		}
+		return a.TaxPercentage.LessThan(b.TaxPercentage)
+	})
+
+	return s
+}
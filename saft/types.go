@@ -24,6 +24,8 @@ type AuditFile struct {
 	GeneralLedgerEntries *GeneralLedgerEntries `xml:"GeneralLedgerEntries"`
 
 	SourceDocuments *SourceDocuments `xml:"SourceDocuments"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -73,6 +75,8 @@ type Header struct {
 	Email *SafpttextTypeMandatoryMax254Car `xml:"Email"`
 
 	Website *SafpttextTypeMandatoryMax60Car `xml:"Website"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -82,6 +86,8 @@ type GeneralLedgerAccounts struct {
 	TaxonomyReference string `xml:"TaxonomyReference"`
 
 	Account []GeneralLedgerAccountsAccount `xml:"Account"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -111,6 +117,8 @@ type Customer struct {
 	Website *SafpttextTypeMandatoryMax60Car `xml:"Website"`
 
 	SelfBillingIndicator uint32 `xml:"SelfBillingIndicator"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -140,6 +148,8 @@ type Supplier struct {
 	Website *SafpttextTypeMandatoryMax60Car `xml:"Website"`
 
 	SelfBillingIndicator uint32 `xml:"SelfBillingIndicator"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -157,13 +167,17 @@ type Product struct {
 	ProductNumberCode SafpttextTypeMandatoryMax60Car `xml:"ProductNumberCode"`
 
 	CustomsDetails *CustomsDetails `xml:"CustomsDetails"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
 type TaxTable struct {
 	XMLName xml.Name `xml:"TaxTable"`
 
-	TaxTableEntry []TaxTableEntry `xml:",any"`
+	TaxTableEntry []TaxTableEntry `xml:"TaxTableEntry"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -183,6 +197,8 @@ type TaxTableEntry struct {
 	TaxPercentage *SafdecimalType `xml:"TaxPercentage"`
 
 	TaxAmount *SafmonetaryType `xml:"TaxAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -196,6 +212,8 @@ type GeneralLedgerEntries struct {
 	TotalCredit SafmonetaryType `xml:"TotalCredit"`
 
 	Journal []GeneralLedgerEntriesJournal `xml:"Journal"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -209,6 +227,8 @@ type SourceDocuments struct {
 	WorkingDocuments *SourceDocumentsWorkingDocuments `xml:"WorkingDocuments"`
 
 	Payments *SourceDocumentsPayments `xml:"Payments"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1244,6 +1264,8 @@ type AuditFileMasterFiles struct {
 	Product []Product `xml:"Product"`
 
 	TaxTable *TaxTable `xml:"TaxTable"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1267,6 +1289,8 @@ type GeneralLedgerAccountsAccount struct {
 	GroupingCode *SafptglaccountId `xml:"GroupingCode"`
 
 	TaxonomyCode *SafpttaxonomyCode `xml:"TaxonomyCode"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1284,6 +1308,8 @@ type LinesDebitLine struct {
 	Description SafpttextTypeMandatoryMax200Car `xml:"Description"`
 
 	DebitAmount SafmonetaryType `xml:"DebitAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1301,6 +1327,8 @@ type LinesCreditLine struct {
 	Description SafpttextTypeMandatoryMax200Car `xml:"Description"`
 
 	CreditAmount SafmonetaryType `xml:"CreditAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1310,6 +1338,8 @@ type TransactionLines struct {
 	DebitLine []LinesDebitLine `xml:"DebitLine"`
 
 	CreditLine []LinesCreditLine `xml:"CreditLine"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1322,11 +1352,6 @@ type JournalTransaction struct {
 
 	TransactionDate SafdateType `xml:"TransactionDate"`
 
-	// Either CustomerId or SupplierId must be present
-	CustomerId *SafpttextTypeMandatoryMax30Car `xml:"CustomerID"`
-	// Either CustomerId or SupplierId must be present
-	SupplierId *SafpttextTypeMandatoryMax30Car `xml:"SupplierID"`
-
 	SourceId SafpttextTypeMandatoryMax30Car `xml:"SourceID"`
 
 	Description SafpttextTypeMandatoryMax200Car `xml:"Description"`
@@ -1337,7 +1362,14 @@ type JournalTransaction struct {
 
 	GlpostingDate SafdateType `xml:"GLPostingDate"`
 
+	// Either CustomerId or SupplierId must be present
+	CustomerId *SafpttextTypeMandatoryMax30Car `xml:"CustomerID"`
+	// Either CustomerId or SupplierId must be present
+	SupplierId *SafpttextTypeMandatoryMax30Car `xml:"SupplierID"`
+
 	Lines TransactionLines `xml:"Lines"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1349,6 +1381,8 @@ type GeneralLedgerEntriesJournal struct {
 	Description SafpttextTypeMandatoryMax200Car `xml:"Description"`
 
 	Transaction []JournalTransaction `xml:"Transaction"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1364,6 +1398,8 @@ type InvoiceDocumentStatus struct {
 	SourceId SafpttextTypeMandatoryMax30Car `xml:"SourceID"`
 
 	SourceBilling SaftptsourceBilling `xml:"SourceBilling"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1407,6 +1443,8 @@ type InvoiceLine struct {
 	SettlementAmount *SafmonetaryType `xml:"SettlementAmount"`
 
 	CustomsInformation *CustomsInformation `xml:"CustomsInformation"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1424,18 +1462,21 @@ type InvoiceDocumentTotals struct {
 	Settlement []Settlement `xml:"Settlement"`
 
 	Payment []PaymentMethod `xml:"Payment"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 func (i *InvoiceDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	// Create a temporary struct with string values for monetary fields to ensure exactly 2 decimal places
 	temp := struct {
-		XMLName    xml.Name        `xml:"DocumentTotals"`
-		TaxPayable string          `xml:"TaxPayable"`
-		NetTotal   string          `xml:"NetTotal"`
-		GrossTotal string          `xml:"GrossTotal"`
-		Currency   *Currency       `xml:"Currency"`
-		Settlement []Settlement    `xml:"Settlement"`
-		Payment    []PaymentMethod `xml:"Payment"`
+		XMLName    xml.Name         `xml:"DocumentTotals"`
+		TaxPayable string           `xml:"TaxPayable"`
+		NetTotal   string           `xml:"NetTotal"`
+		GrossTotal string           `xml:"GrossTotal"`
+		Currency   *Currency        `xml:"Currency"`
+		Settlement []Settlement     `xml:"Settlement"`
+		Payment    []PaymentMethod  `xml:"Payment"`
+		Unknown    []UnknownElement `xml:",any"`
 	}{
 		XMLName:    xml.Name{Local: "DocumentTotals"},
 		TaxPayable: i.TaxPayable.Round(2).StringFixed(2),
@@ -1444,6 +1485,7 @@ func (i *InvoiceDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElemen
 		Currency:   i.Currency,
 		Settlement: i.Settlement,
 		Payment:    i.Payment,
+		Unknown:    i.Unknown,
 	}
 
 	return e.EncodeElement(temp, start)
@@ -1494,6 +1536,8 @@ type SalesInvoicesInvoice struct {
 	DocumentTotals InvoiceDocumentTotals `xml:"DocumentTotals"`
 
 	WithholdingTax []WithholdingTax `xml:"WithholdingTax"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1507,6 +1551,8 @@ type SourceDocumentsSalesInvoices struct {
 	TotalCredit SafmonetaryType `xml:"TotalCredit"`
 
 	Invoice []SalesInvoicesInvoice `xml:"Invoice"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1522,6 +1568,8 @@ type StockMovementDocumentStatus struct {
 	SourceId SafpttextTypeMandatoryMax30Car `xml:"SourceID"`
 
 	SourceBilling SaftptsourceBilling `xml:"SourceBilling"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1559,6 +1607,8 @@ type StockMovementLine struct {
 	SettlementAmount *SafmonetaryType `xml:"SettlementAmount"`
 
 	CustomsInformation *CustomsInformation `xml:"CustomsInformation"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1572,22 +1622,26 @@ type StockMovementDocumentTotals struct {
 	GrossTotal SafmonetaryType `xml:"GrossTotal"`
 
 	Currency *Currency `xml:"Currency"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 func (i *StockMovementDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	// Create a temporary struct with string values for monetary fields to ensure exactly 2 decimal places
 	temp := struct {
-		XMLName    xml.Name  `xml:"DocumentTotals"`
-		TaxPayable string    `xml:"TaxPayable"`
-		NetTotal   string    `xml:"NetTotal"`
-		GrossTotal string    `xml:"GrossTotal"`
-		Currency   *Currency `xml:"Currency"`
+		XMLName    xml.Name         `xml:"DocumentTotals"`
+		TaxPayable string           `xml:"TaxPayable"`
+		NetTotal   string           `xml:"NetTotal"`
+		GrossTotal string           `xml:"GrossTotal"`
+		Currency   *Currency        `xml:"Currency"`
+		Unknown    []UnknownElement `xml:",any"`
 	}{
 		XMLName:    xml.Name{Local: "DocumentTotals"},
 		TaxPayable: i.TaxPayable.Round(2).StringFixed(2),
 		NetTotal:   i.NetTotal.Round(2).StringFixed(2),
 		GrossTotal: i.GrossTotal.Round(2).StringFixed(2),
 		Currency:   i.Currency,
+		Unknown:    i.Unknown,
 	}
 
 	return e.EncodeElement(temp, start)
@@ -1607,7 +1661,7 @@ type MovementOfGoodsStockMovement struct {
 
 	HashControl SafpthashControl `xml:"HashControl"`
 
-	Period uint `xml:"Period"`
+	Period uint `xml:"Period,omitempty"`
 
 	MovementDate SafdateType `xml:"MovementDate"`
 
@@ -1640,6 +1694,8 @@ type MovementOfGoodsStockMovement struct {
 	Line []StockMovementLine `xml:"Line"`
 
 	DocumentTotals StockMovementDocumentTotals `xml:"DocumentTotals"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1651,6 +1707,8 @@ type SourceDocumentsMovementOfGoods struct {
 	TotalQuantityIssued SafdecimalType `xml:"TotalQuantityIssued"`
 
 	StockMovement []MovementOfGoodsStockMovement `xml:"StockMovement"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1666,6 +1724,8 @@ type WorkDocumentDocumentStatus struct {
 	SourceId SafpttextTypeMandatoryMax30Car `xml:"SourceID"`
 
 	SourceBilling SaftptsourceBilling `xml:"SourceBilling"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1709,6 +1769,8 @@ type WorkDocumentLine struct {
 	SettlementAmount *SafmonetaryType `xml:"SettlementAmount"`
 
 	CustomsInformation *CustomsInformation `xml:"CustomsInformation"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1722,22 +1784,26 @@ type WorkDocumentDocumentTotals struct {
 	GrossTotal SafmonetaryType `xml:"GrossTotal"`
 
 	Currency *Currency `xml:"Currency"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 func (i *WorkDocumentDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	// Create a temporary struct with string values for monetary fields to ensure exactly 2 decimal places
 	temp := struct {
-		XMLName    xml.Name  `xml:"DocumentTotals"`
-		TaxPayable string    `xml:"TaxPayable"`
-		NetTotal   string    `xml:"NetTotal"`
-		GrossTotal string    `xml:"GrossTotal"`
-		Currency   *Currency `xml:"Currency"`
+		XMLName    xml.Name         `xml:"DocumentTotals"`
+		TaxPayable string           `xml:"TaxPayable"`
+		NetTotal   string           `xml:"NetTotal"`
+		GrossTotal string           `xml:"GrossTotal"`
+		Currency   *Currency        `xml:"Currency"`
+		Unknown    []UnknownElement `xml:",any"`
 	}{
 		XMLName:    xml.Name{Local: "DocumentTotals"},
 		TaxPayable: i.TaxPayable.Round(2).StringFixed(2),
 		NetTotal:   i.NetTotal.Round(2).StringFixed(2),
 		GrossTotal: i.GrossTotal.Round(2).StringFixed(2),
 		Currency:   i.Currency,
+		Unknown:    i.Unknown,
 	}
 
 	return e.EncodeElement(temp, start)
@@ -1757,7 +1823,7 @@ type WorkingDocumentsWorkDocument struct {
 
 	HashControl SafpthashControl `xml:"HashControl"`
 
-	Period uint `xml:"Period"`
+	Period uint `xml:"Period,omitempty"`
 
 	WorkDate SafdateType `xml:"WorkDate"`
 
@@ -1776,6 +1842,8 @@ type WorkingDocumentsWorkDocument struct {
 	Line []WorkDocumentLine `xml:"Line"`
 
 	DocumentTotals WorkDocumentDocumentTotals `xml:"DocumentTotals"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1789,6 +1857,8 @@ type SourceDocumentsWorkingDocuments struct {
 	TotalCredit SafmonetaryType `xml:"TotalCredit"`
 
 	WorkDocument []WorkingDocumentsWorkDocument `xml:"WorkDocument"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1804,6 +1874,8 @@ type PaymentDocumentStatus struct {
 	SourceId SafpttextTypeMandatoryMax30Car `xml:"SourceID"`
 
 	SourcePayment SaftptsourcePayment `xml:"SourcePayment"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1815,6 +1887,8 @@ type LineSourceDocumentId struct {
 	InvoiceDate SafdateType `xml:"InvoiceDate"`
 
 	Description *SafpttextTypeMandatoryMax200Car `xml:"Description"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1836,6 +1910,8 @@ type PaymentLine struct {
 	TaxExemptionReason *SafptportugueseTaxExemptionReason `xml:"TaxExemptionReason"`
 
 	TaxExemptionCode *SafptportugueseTaxExemptionCode `xml:"TaxExemptionCode"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1843,6 +1919,8 @@ type DocumentTotalsSettlement struct {
 	XMLName xml.Name `xml:"Settlement"`
 
 	SettlementAmount SafmonetaryType `xml:",any"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1858,6 +1936,8 @@ type PaymentDocumentTotals struct {
 	Settlement *DocumentTotalsSettlement `xml:"Settlement"`
 
 	Currency *Currency `xml:"Currency"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 func (i *PaymentDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
@@ -1869,6 +1949,7 @@ func (i *PaymentDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElemen
 		GrossTotal string                    `xml:"GrossTotal"`
 		Settlement *DocumentTotalsSettlement `xml:"Settlement"`
 		Currency   *Currency                 `xml:"Currency"`
+		Unknown    []UnknownElement          `xml:",any"`
 	}{
 		XMLName:    xml.Name{Local: "DocumentTotals"},
 		TaxPayable: i.TaxPayable.Round(2).StringFixed(2),
@@ -1876,6 +1957,7 @@ func (i *PaymentDocumentTotals) MarshalXML(e *xml.Encoder, start xml.StartElemen
 		GrossTotal: i.GrossTotal.Round(2).StringFixed(2),
 		Settlement: i.Settlement,
 		Currency:   i.Currency,
+		Unknown:    i.Unknown,
 	}
 
 	return e.EncodeElement(temp, start)
@@ -1889,7 +1971,7 @@ type PaymentsPayment struct {
 
 	Atcud SafpttextTypeMandatoryMax100Car `xml:"ATCUD"`
 
-	Period uint `xml:"Period"`
+	Period uint `xml:"Period,omitempty"`
 
 	TransactionId *SafpttransactionId `xml:"TransactionID"`
 
@@ -1916,6 +1998,8 @@ type PaymentsPayment struct {
 	DocumentTotals PaymentDocumentTotals `xml:"DocumentTotals"`
 
 	WithholdingTax []WithholdingTax `xml:"WithholdingTax"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // Element
@@ -1929,6 +2013,8 @@ type SourceDocumentsPayments struct {
 	TotalCredit SafmonetaryType `xml:"TotalCredit"`
 
 	Payment []PaymentsPayment `xml:"Payment"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // XSD ComplexType declarations
@@ -1949,6 +2035,8 @@ type AddressStructure struct {
 	Region *SafpttextTypeMandatoryMax50Car `xml:"Region"`
 
 	Country string `xml:"Country"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type CustomerAddressStructure struct {
@@ -1967,6 +2055,8 @@ type CustomerAddressStructure struct {
 	Region *SafpttextTypeMandatoryMax50Car `xml:"Region"`
 
 	Country CustomerCountry `xml:"Country"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type Currency struct {
@@ -1977,6 +2067,8 @@ type Currency struct {
 	CurrencyAmount SafmonetaryType `xml:"CurrencyAmount"`
 
 	ExchangeRate SafdecimalType `xml:"ExchangeRate"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type CustomsDetails struct {
@@ -1985,6 +2077,8 @@ type CustomsDetails struct {
 	Cncode []Safptcncode `xml:"CNCode"`
 
 	Unnumber []Safptunnumber `xml:"UNNumber"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type CustomsInformation struct {
@@ -1993,6 +2087,8 @@ type CustomsInformation struct {
 	Arcno []SafpttextTypeMandatoryMax21Car `xml:"ARCNo"`
 
 	Iecamount *SafmonetaryType `xml:"IECAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type MovementTax struct {
@@ -2005,6 +2101,8 @@ type MovementTax struct {
 	TaxCode SaftptmovementTaxCode `xml:"TaxCode"`
 
 	TaxPercentage SafdecimalType `xml:"TaxPercentage"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type OrderReferences struct {
@@ -2013,6 +2111,8 @@ type OrderReferences struct {
 	OriginatingOn *SafpttextTypeMandatoryMax60Car `xml:"OriginatingON"`
 
 	OrderDate *SafdateType `xml:"OrderDate"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type PaymentMethod struct {
@@ -2023,6 +2123,8 @@ type PaymentMethod struct {
 	PaymentAmount SafmonetaryType `xml:"PaymentAmount"`
 
 	PaymentDate SafdateType `xml:"PaymentDate"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type PaymentTax struct {
@@ -2037,12 +2139,16 @@ type PaymentTax struct {
 	TaxPercentage *SafdecimalType `xml:"TaxPercentage"`
 
 	TaxAmount *SafmonetaryType `xml:"TaxAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type ProductSerialNumber struct {
 	//XMLName xml.Name
 
 	SerialNumber []SafpttextTypeMandatoryMax100Car `xml:",any"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type References struct {
@@ -2051,6 +2157,8 @@ type References struct {
 	Reference *SafpttextTypeMandatoryMax60Car `xml:"Reference"`
 
 	Reason *SafpttextTypeMandatoryMax50Car `xml:"Reason"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type Settlement struct {
@@ -2063,6 +2171,8 @@ type Settlement struct {
 	SettlementDate *SafdateType `xml:"SettlementDate"`
 
 	PaymentTerms *SafpttextTypeMandatoryMax100Car `xml:"PaymentTerms"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type ShippingPointStructure struct {
@@ -2072,7 +2182,11 @@ type ShippingPointStructure struct {
 
 	DeliveryDate *SafdateType `xml:"DeliveryDate"`
 
+	Location []ShippingLocation `xml:"-"`
+
 	Address *CustomerAddressStructure `xml:"Address"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type SpecialRegimes struct {
@@ -2083,6 +2197,8 @@ type SpecialRegimes struct {
 	CashVatschemeIndicator uint32 `xml:"CashVATSchemeIndicator"`
 
 	ThirdPartiesBillingIndicator uint32 `xml:"ThirdPartiesBillingIndicator"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type Tax struct {
@@ -2097,6 +2213,8 @@ type Tax struct {
 	TaxPercentage *SafdecimalType `xml:"TaxPercentage"`
 
 	TaxAmount *SafmonetaryType `xml:"TaxAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 type WithholdingTax struct {
@@ -2107,6 +2225,8 @@ type WithholdingTax struct {
 	WithholdingTaxDescription *SafpttextTypeMandatoryMax60Car `xml:"WithholdingTaxDescription"`
 
 	WithholdingTaxAmount SafmonetaryType `xml:"WithholdingTaxAmount"`
+
+	Unknown []UnknownElement `xml:",any"`
 }
 
 // XSD SimpleType declarations
@@ -0,0 +1,154 @@
+package saft
+
+import (
+	"encoding/xml"
+)
+
+// UnknownElement is an element the SAF-T structs do not model, such as a
+// vendor extension. Every record keeps its unknown children in its Unknown
+// field and writes them back, after its known elements, so that decoding
+// and encoding a file loses nothing.
+type UnknownElement struct {
+	XMLName xml.Name
+	Attr    []xml.Attr
+	// Content holds the tokens between the start and end tags.
+	Content []xml.Token
+}
+
+func (u *UnknownElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	u.XMLName = start.Name
+	u.Attr = attrs(start.Attr)
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			t.Attr = attrs(t.Attr)
+			tok = t
+		case xml.EndElement:
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+		u.Content = append(u.Content, xml.CopyToken(tok))
+	}
+}
+
+func (u UnknownElement) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: u.XMLName, Attr: u.Attr}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, tok := range u.Content {
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// attrs drops namespace declarations: names keep their namespace URL and
+// the encoder declares the prefixes it needs.
+func attrs(in []xml.Attr) []xml.Attr {
+	var out []xml.Attr
+	for _, a := range in {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// ShippingLocation is a warehouse and location of a [ShippingPointStructure].
+// The schema repeats them as pairs, either of which may be absent.
+type ShippingLocation struct {
+	WarehouseId *SafpttextTypeMandatoryMax50Car
+	LocationId  *SafpttextTypeMandatoryMax30Car
+}
+
+// UnmarshalXML pairs each WarehouseID with the LocationID that follows it,
+// which struct tags cannot express.
+func (s *ShippingPointStructure) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*s = ShippingPointStructure{}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "DeliveryID":
+				var v SafpttextTypeMandatoryMax255Car
+				err = d.DecodeElement(&v, &t)
+				s.DeliveryId = append(s.DeliveryId, v)
+			case "DeliveryDate":
+				s.DeliveryDate = &SafdateType{}
+				err = d.DecodeElement(s.DeliveryDate, &t)
+			case "WarehouseID":
+				v := new(SafpttextTypeMandatoryMax50Car)
+				err = d.DecodeElement(v, &t)
+				s.Location = append(s.Location, ShippingLocation{WarehouseId: v})
+			case "LocationID":
+				v := new(SafpttextTypeMandatoryMax30Car)
+				err = d.DecodeElement(v, &t)
+				if n := len(s.Location); n > 0 && s.Location[n-1].LocationId == nil {
+					s.Location[n-1].LocationId = v
+				} else {
+					s.Location = append(s.Location, ShippingLocation{LocationId: v})
+				}
+			case "Address":
+				s.Address = &CustomerAddressStructure{}
+				err = d.DecodeElement(s.Address, &t)
+			default:
+				var u UnknownElement
+				err = d.DecodeElement(&u, &t)
+				s.Unknown = append(s.Unknown, u)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (s ShippingPointStructure) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	// Nil pointers and slices encode nothing.
+	encode := func(name string, v any) error {
+		return e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+	if err := encode("DeliveryID", s.DeliveryId); err != nil {
+		return err
+	}
+	if err := encode("DeliveryDate", s.DeliveryDate); err != nil {
+		return err
+	}
+	for _, l := range s.Location {
+		if err := encode("WarehouseID", l.WarehouseId); err != nil {
+			return err
+		}
+		if err := encode("LocationID", l.LocationId); err != nil {
+			return err
+		}
+	}
+	if err := encode("Address", s.Address); err != nil {
+		return err
+	}
+	for _, u := range s.Unknown {
+		if err := e.Encode(u); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
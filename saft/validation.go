@@ -40,9 +40,11 @@ func (a *AuditFile) Validate() error {
 		}
 	}
 
-	for _, invoice := range a.SourceDocuments.SalesInvoices.Invoice {
-		if invoice.SpecialRegimes.CashVatschemeIndicator != IndicatorNo && invoice.SpecialRegimes.CashVatschemeIndicator != IndicatorYes {
-			return fmt.Errorf("saft: invalid CashVatschemeIndicator: %d", invoice.SpecialRegimes.CashVatschemeIndicator)
+	if a.SourceDocuments != nil && a.SourceDocuments.SalesInvoices != nil {
+		for _, invoice := range a.SourceDocuments.SalesInvoices.Invoice {
+			if invoice.SpecialRegimes.CashVatschemeIndicator != IndicatorNo && invoice.SpecialRegimes.CashVatschemeIndicator != IndicatorYes {
+				return fmt.Errorf("saft: invalid CashVatschemeIndicator: %d", invoice.SpecialRegimes.CashVatschemeIndicator)
+			}
 		}
 	}
 
@@ -258,7 +260,11 @@ func (a *AuditFile) checkConstraints() error {
 		}
 
 		journals := make(map[SafptjournalId]bool)
-		for _, entry := range a.GeneralLedgerEntries.Journal {
+		var entries []GeneralLedgerEntriesJournal
+		if a.GeneralLedgerEntries != nil {
+			entries = a.GeneralLedgerEntries.Journal
+		}
+		for _, entry := range entries {
 			// GeneralLedgerEntriesJournalIdConstraint
 			if _, ok := journals[entry.JournalId]; ok {
 				//return errcodes.ErrUQJournalId
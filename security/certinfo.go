@@ -0,0 +1,223 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CertificateInfo summarises a certificate for monitoring and start-up
+// checks.
+type CertificateInfo struct {
+	// Subject is the certificate subject distinguished name.
+	Subject string
+	// NIF is the Portuguese tax number found in the subject, if any.
+	NIF string
+	// Issuer is the issuer distinguished name.
+	Issuer string
+	// NotBefore and NotAfter delimit the validity window.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// DaysUntilExpiry is the number of whole days left until NotAfter at the
+	// time of inspection; it is negative once the certificate has expired.
+	DaysUntilExpiry int
+	// KeyAlgorithm is the public key algorithm, e.g. "RSA".
+	KeyAlgorithm string
+	// KeySize is the public key size in bits.
+	KeySize int
+	// ChainVerified reports whether the certificate chains up to one of the
+	// trusted roots given to the inspection function (the AT CA).
+	ChainVerified bool
+	// ChainError is the verification error when ChainVerified is false.
+	ChainError error
+}
+
+// Expired reports whether the certificate was not valid at now.
+func (i CertificateInfo) Expired(now time.Time) bool {
+	return now.After(i.NotAfter) || now.Before(i.NotBefore)
+}
+
+// ExpiresWithin reports whether the certificate expires within d of now.
+func (i CertificateInfo) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return now.Add(d).After(i.NotAfter)
+}
+
+// Check returns an error if the certificate is expired or not yet valid at
+// now, or if it expires within warnBefore.
+func (i CertificateInfo) Check(now time.Time, warnBefore time.Duration) error {
+	switch {
+	case now.Before(i.NotBefore):
+		return fmt.Errorf("certificate %s is not valid before %s", i.Subject, i.NotBefore.Format(time.RFC3339))
+	case now.After(i.NotAfter):
+		return fmt.Errorf("certificate %s expired on %s", i.Subject, i.NotAfter.Format(time.RFC3339))
+	case i.ExpiresWithin(now, warnBefore):
+		return fmt.Errorf("certificate %s expires in %d days (%s)", i.Subject, i.DaysUntilExpiry, i.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// InspectClientCert reports on the leaf of a client certificate loaded with
+// [LoadClientCert], [LoadClientCertPFX] or similar. Any further certificates
+// in cert are used as intermediates. atRoots holds the AT CA
+// certificates; if nil, the chain is not verified.
+func InspectClientCert(cert tls.Certificate, atRoots *x509.CertPool) (CertificateInfo, error) {
+	if len(cert.Certificate) == 0 {
+		return CertificateInfo{}, fmt.Errorf("client certificate is empty")
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return CertificateInfo{}, fmt.Errorf("parse client certificate: %w", err)
+		}
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range cert.Certificate[1:] {
+		if c, err := x509.ParseCertificate(der); err == nil {
+			intermediates.AddCert(c)
+		}
+	}
+
+	return InspectCertificate(leaf, intermediates, atRoots, time.Now()), nil
+}
+
+// InspectCertificate reports on cert at the given time. The chain is verified
+// for client authentication against roots when roots is not nil.
+func InspectCertificate(cert *x509.Certificate, intermediates, roots *x509.CertPool, now time.Time) CertificateInfo {
+	info := CertificateInfo{
+		Subject:         cert.Subject.String(),
+		NIF:             nifFromSubject(cert),
+		Issuer:          cert.Issuer.String(),
+		NotBefore:       cert.NotBefore,
+		NotAfter:        cert.NotAfter,
+		DaysUntilExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+		KeyAlgorithm:    cert.PublicKeyAlgorithm.String(),
+		KeySize:         keySize(cert.PublicKey),
+	}
+
+	if roots == nil {
+		info.ChainError = fmt.Errorf("no AT CA certificates given")
+		return info
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		Roots:         roots,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	info.ChainVerified = err == nil
+	info.ChainError = err
+	return info
+}
+
+var nifPattern = regexp.MustCompile(`(?:^|[^0-9])(?:PT)?([0-9]{9})(?:[^0-9]|$)`)
+
+// nifFromSubject looks for a 9-digit NIF in the serialNumber, common name
+// and organizational units of the subject, in that order.
+func nifFromSubject(cert *x509.Certificate) string {
+	candidates := []string{cert.Subject.SerialNumber, cert.Subject.CommonName}
+	candidates = append(candidates, cert.Subject.OrganizationalUnit...)
+	for _, c := range candidates {
+		if m := nifPattern.FindStringSubmatch(c); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func keySize(pub interface{}) int {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+// -----------------------------------------------------------------------
+// AT public key environment check
+// -----------------------------------------------------------------------
+
+// ErrTestKeyInProduction is returned by [CheckATPublicKey] when the AT test
+// public key is used against a production endpoint.
+var ErrTestKeyInProduction = errors.New("AT test public key used with a production endpoint")
+
+// productionEndpoints are the host and ports of the AT production
+// webservices: e-Fatura (400), transport documents (401), series (422) and
+// invoice communication (423). The test environment uses 700, 701, 722 and
+// 723.
+var productionEndpoints = map[string]bool{
+	"servicos.portaldasfinancas.gov.pt:400": true,
+	"servicos.portaldasfinancas.gov.pt:401": true,
+	"servicos.portaldasfinancas.gov.pt:422": true,
+	"servicos.portaldasfinancas.gov.pt:423": true,
+}
+
+// IsProductionURL reports whether endpoint is an AT production webservice,
+// i.e. one of the published production host and port pairs.
+func IsProductionURL(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return productionEndpoints[strings.ToLower(u.Hostname())+":"+u.Port()]
+}
+
+// ATTestKeyFingerprints holds the [PublicKeyFingerprint] of the public keys
+// AT distributes for its test environment. [CheckATPublicKey] refuses them
+// with production endpoints without further configuration. Add the
+// fingerprint of a new test key here when AT replaces it.
+var ATTestKeyFingerprints = map[string]bool{}
+
+// PublicKeyFingerprint returns the hex-encoded SHA-256 of the DER
+// SubjectPublicKeyInfo of key, as printed by
+//
+//	openssl x509 -in key.cer -noout -pubkey | openssl pkey -pubin -outform DER | sha256sum
+func PublicKeyFingerprint(key *rsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckATPublicKey returns [ErrTestKeyInProduction] when key is an AT test
+// key and endpoint is a production URL. Test keys are those listed in
+// [ATTestKeyFingerprints] and any of testKeys.
+//
+// Requests encrypted with the wrong key are rejected by AT with a generic
+// authentication fault, so this is worth checking at start-up.
+func CheckATPublicKey(key *rsa.PublicKey, endpoint string, testKeys ...*rsa.PublicKey) error {
+	if key == nil {
+		return fmt.Errorf("no AT public key")
+	}
+	if !IsProductionURL(endpoint) {
+		return nil
+	}
+	isTestKey := ATTestKeyFingerprints[PublicKeyFingerprint(key)]
+	for _, testKey := range testKeys {
+		isTestKey = isTestKey || (testKey != nil && key.Equal(testKey))
+	}
+	if isTestKey {
+		return fmt.Errorf("%s: %w", endpoint, ErrTestKeyInProduction)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, subject pkix.Name, notAfter time.Time, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestInspectClientCert(t *testing.T) {
+	ca, caKey := newTestCert(t, pkix.Name{CommonName: "Test AT CA"}, time.Now().AddDate(5, 0, 0), nil, nil)
+	leaf, _ := newTestCert(t, pkix.Name{CommonName: "555555555", Organization: []string{"Produtor"}}, time.Now().AddDate(0, 0, 20).Add(time.Hour), ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	info, err := InspectClientCert(tls.Certificate{Certificate: [][]byte{leaf.Raw}}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NIF != "555555555" {
+		t.Errorf("NIF = %q, want 555555555", info.NIF)
+	}
+	if info.KeySize != 2048 || info.KeyAlgorithm != "RSA" {
+		t.Errorf("key = %s %d", info.KeyAlgorithm, info.KeySize)
+	}
+	if info.DaysUntilExpiry != 20 {
+		t.Errorf("DaysUntilExpiry = %d, want 20", info.DaysUntilExpiry)
+	}
+	if !info.ChainVerified {
+		t.Errorf("ChainVerified = false: %v", info.ChainError)
+	}
+	if err := info.Check(time.Now(), 30*24*time.Hour); err == nil {
+		t.Errorf("Check() within warning window, want error")
+	}
+	if err := info.Check(time.Now(), 7*24*time.Hour); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	info, _ = InspectClientCert(tls.Certificate{Certificate: [][]byte{leaf.Raw}}, x509.NewCertPool())
+	if info.ChainVerified {
+		t.Errorf("ChainVerified = true without the AT CA")
+	}
+}
+
+func TestCheckATPublicKey(t *testing.T) {
+	testKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	prodKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	const (
+		testURL = "https://servicos.portaldasfinancas.gov.pt:722/SeriesWSService"
+		prodURL = "https://servicos.portaldasfinancas.gov.pt:422/SeriesWSService"
+	)
+
+	if err := CheckATPublicKey(&testKey.PublicKey, prodURL, &testKey.PublicKey); !errors.Is(err, ErrTestKeyInProduction) {
+		t.Errorf("test key on prod: error = %v, want ErrTestKeyInProduction", err)
+	}
+	if err := CheckATPublicKey(&testKey.PublicKey, testURL, &testKey.PublicKey); err != nil {
+		t.Errorf("test key on test: error = %v", err)
+	}
+	if err := CheckATPublicKey(&prodKey.PublicKey, prodURL, &testKey.PublicKey); err != nil {
+		t.Errorf("prod key on prod: error = %v", err)
+	}
+
+	// Known test keys are refused without passing them.
+	fingerprint := PublicKeyFingerprint(&testKey.PublicKey)
+	ATTestKeyFingerprints[fingerprint] = true
+	defer delete(ATTestKeyFingerprints, fingerprint)
+	if err := CheckATPublicKey(&testKey.PublicKey, prodURL); !errors.Is(err, ErrTestKeyInProduction) {
+		t.Errorf("known test key on prod: error = %v, want ErrTestKeyInProduction", err)
+	}
+	if err := CheckATPublicKey(&prodKey.PublicKey, prodURL); err != nil {
+		t.Errorf("prod key on prod: error = %v", err)
+	}
+}
+
+func TestIsProductionURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://servicos.portaldasfinancas.gov.pt:400/fews/faturas":                true,
+		"https://servicos.portaldasfinancas.gov.pt:401/sgdtws/documentosTransporte": true,
+		"https://servicos.portaldasfinancas.gov.pt:422/SeriesWSService":             true,
+		"https://servicos.portaldasfinancas.gov.pt:423/fatcorews/ws/":               true,
+		"https://servicos.portaldasfinancas.gov.pt:722/SeriesWSService":             false,
+		"https://servicos.portaldasfinancas.gov.pt:701/sgdtws/documentosTransporte": false,
+		"https://servicos.portaldasfinancas.gov.pt/SeriesWSService":                 false,
+		"http://localhost:4430/SeriesWSService":                                     false,
+		"https://proxy.example.com:422/SeriesWSService":                             false,
+	}
+	for endpoint, want := range tests {
+		if got := IsProductionURL(endpoint); got != want {
+			t.Errorf("IsProductionURL(%q) = %v, want %v", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package security
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderBuilder builds WS-Security headers with credentials from a
+// [CredentialProvider] and a configurable clock.
+type HeaderBuilder struct {
+	// Credentials supplies the username and password of every header.
+	Credentials CredentialProvider
+	// ATPublicKey is AT's authentication system RSA public key.
+	ATPublicKey *rsa.PublicKey
+	// Now returns the time written to the encrypted Created field. Defaults
+	// to time.Now. Use [SkewDetector.Now] on hosts with a drifting clock,
+	// or a fixed clock in tests.
+	Now func() time.Time
+}
+
+// Build constructs a WS-Security header for a single request.
+func (b HeaderBuilder) Build(ctx context.Context) (Header, error) {
+	if b.Credentials == nil {
+		return Header{}, fmt.Errorf("no credential provider")
+	}
+	creds, err := b.Credentials.Credentials(ctx)
+	if err != nil {
+		return Header{}, fmt.Errorf("get credentials: %w", err)
+	}
+
+	now := time.Now
+	if b.Now != nil {
+		now = b.Now
+	}
+	return BuildAt(creds.Username, creds.Password, b.ATPublicKey, now())
+}
+
+// -----------------------------------------------------------------------
+// Clock skew
+// -----------------------------------------------------------------------
+
+// ErrClockSkew is reported when AT rejects the Created timestamp of a
+// request, which almost always means the local clock is off.
+var ErrClockSkew = errors.New("AT rejected the request timestamp, check the host clock")
+
+// IsTimestampRejection reports whether an AT fault message is about the
+// Created timestamp. AT does not use a dedicated fault code for it, so the
+// message is matched against the wording AT uses. Faults about expired
+// passwords or certificates are not timestamp rejections.
+func IsTimestampRejection(faultString string) bool {
+	s := strings.ToLower(faultString)
+	for _, k := range []string{"created", "timestamp", "data de cria", "hora de cria"} {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkewDetector estimates the offset between the local clock and AT's clock
+// from the Date header of AT's replies, and corrects the Created timestamp
+// of later requests by that offset.
+//
+// Install it both as HTTP middleware and as the header clock, e.g. with
+// soap.WithSkewDetector. One detector may be shared by several clients.
+type SkewDetector struct {
+	// Clock is the local clock. Defaults to time.Now.
+	Clock func() time.Time
+	// Threshold is the smallest offset that is applied. Smaller offsets are
+	// ignored, since the Date header only has a resolution of one second.
+	// Defaults to 2 seconds.
+	Threshold time.Duration
+
+	mu     sync.RWMutex
+	offset time.Duration
+}
+
+// Now returns the local time corrected by the last observed offset.
+func (d *SkewDetector) Now() time.Time {
+	return d.clock()().Add(d.Offset())
+}
+
+// Offset returns how far AT's clock is ahead of the local clock (negative
+// if it is behind), or zero if the difference is below Threshold.
+func (d *SkewDetector) Offset() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.offset
+}
+
+// Observe records a reply's Date header. sent and received are the local
+// times the request was sent and the reply received.
+func (d *SkewDetector) Observe(date string, sent, received time.Time) {
+	server, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+	// The Date header is truncated to the second; assume the middle of it,
+	// and that AT stamped the reply halfway through the round trip.
+	server = server.Add(500 * time.Millisecond)
+	local := sent.Add(received.Sub(sent) / 2)
+
+	offset := server.Sub(local)
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = 2 * time.Second
+	}
+	if offset < threshold && offset > -threshold {
+		offset = 0
+	}
+
+	d.mu.Lock()
+	d.offset = offset
+	d.mu.Unlock()
+}
+
+// Wrap is a [Middleware] that observes the Date header of every reply.
+func (d *SkewDetector) Wrap(next HTTPDoer) HTTPDoer {
+	return HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+		sent := d.clock()()
+		resp, err := next.Do(req)
+		if err == nil {
+			if date := resp.Header.Get("Date"); date != "" {
+				d.Observe(date, sent, d.clock()())
+			}
+		}
+		return resp, err
+	})
+}
+
+func (d *SkewDetector) clock() func() time.Time {
+	if d.Clock == nil {
+		return time.Now
+	}
+	return d.Clock
+}
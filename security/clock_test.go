@@ -0,0 +1,95 @@
+package security
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// decryptCreated recovers the Created timestamp of h with AT's private key.
+func decryptCreated(t *testing.T, h Header, atKey *rsa.PrivateKey) string {
+	t.Helper()
+	encKs, _ := base64.StdEncoding.DecodeString(h.UsernameToken.Nonce)
+	ks, err := rsa.DecryptPKCS1v15(rand.Reader, atKey, encKs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := base64.StdEncoding.DecodeString(h.UsernameToken.Created)
+	block, _ := aes.NewCipher(ks)
+	out := make([]byte, len(enc))
+	for i := 0; i < len(enc); i += block.BlockSize() {
+		block.Decrypt(out[i:i+block.BlockSize()], enc[i:i+block.BlockSize()])
+	}
+	return string(out[:len(out)-int(out[len(out)-1])])
+}
+
+func TestHeaderBuilderClock(t *testing.T) {
+	atKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := time.Date(2024, 3, 1, 10, 30, 0, 123e6, time.FixedZone("WET", 0))
+
+	h, err := HeaderBuilder{
+		Credentials: NewStaticCredentials("555555555/1", "secret"),
+		ATPublicKey: &atKey.PublicKey,
+		Now:         func() time.Time { return fixed },
+	}.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decryptCreated(t, h, atKey); got != "2024-03-01T10:30:00.123Z" {
+		t.Errorf("Created = %q, want 2024-03-01T10:30:00.123Z", got)
+	}
+}
+
+func TestSkewDetector(t *testing.T) {
+	local := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	d := &SkewDetector{Clock: func() time.Time { return local }}
+
+	// AT is 90 seconds ahead.
+	server := local.Add(90 * time.Second)
+	doer := d.Wrap(HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+		h := http.Header{}
+		h.Set("Date", server.Format(http.TimeFormat))
+		return &http.Response{StatusCode: http.StatusOK, Header: h, Body: http.NoBody}, nil
+	}))
+	req, _ := http.NewRequest(http.MethodPost, "https://at.example/ws", nil)
+	if _, err := doer.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if off := d.Offset(); off < 89*time.Second || off > 91*time.Second {
+		t.Errorf("Offset() = %s, want about 90s", off)
+	}
+	if now := d.Now(); now.Sub(server).Abs() > time.Second {
+		t.Errorf("Now() = %s, want about %s", now, server)
+	}
+
+	// Differences within the Date header resolution are ignored.
+	d.Observe(local.Format(http.TimeFormat), local, local)
+	if off := d.Offset(); off != 0 {
+		t.Errorf("Offset() = %s after an in-sync reply, want 0", off)
+	}
+}
+
+func TestIsTimestampRejection(t *testing.T) {
+	for fault, want := range map[string]bool{
+		"O campo Created do pedido é inválido":    true,
+		"Timestamp do pedido fora do intervalo":   true,
+		"Data de criação do pedido inválida":      true,
+		"Utilizador ou senha inválidos":           false,
+		"A senha do utilizador expirou":           false,
+		"Senha expirada":                          false,
+		"O certificado do software está expirado": false,
+	} {
+		if got := IsTimestampRejection(fault); got != want {
+			t.Errorf("IsTimestampRejection(%q) = %v, want %v", fault, got, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package security
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Credentials are the Portal das Finanças sub-user credentials used in the
+// WS-Security UsernameToken.
+type Credentials struct {
+	// Username is the NIF/subuser identifier, e.g. "555555555/37".
+	Username string
+	// Password is the plain-text sub-user password.
+	Password string
+}
+
+// CredentialProvider returns the credentials to use for a single AT
+// webservice call. It is consulted on every call, so implementations may
+// rotate credentials or select them per tenant without restarting.
+//
+// Implementations must be safe for concurrent use.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts an ordinary function to a [CredentialProvider].
+type CredentialProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f(ctx).
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// BuildWithProvider constructs a WS-Security header with the credentials
+// returned by provider for ctx. See [Build].
+func BuildWithProvider(ctx context.Context, provider CredentialProvider, atPubKey *rsa.PublicKey) (Header, error) {
+	return HeaderBuilder{Credentials: provider, ATPublicKey: atPubKey}.Build(ctx)
+}
+
+// -----------------------------------------------------------------------
+// Static credentials
+// -----------------------------------------------------------------------
+
+// StaticCredentials is a [CredentialProvider] that always returns the same
+// credentials.
+type StaticCredentials Credentials
+
+// NewStaticCredentials returns a provider for a fixed username and password.
+func NewStaticCredentials(username, password string) StaticCredentials {
+	return StaticCredentials{Username: username, Password: password}
+}
+
+// Credentials returns the static credentials.
+func (s StaticCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	if s.Username == "" {
+		return Credentials{}, fmt.Errorf("empty username")
+	}
+	return Credentials(s), nil
+}
+
+// -----------------------------------------------------------------------
+// Environment credentials
+// -----------------------------------------------------------------------
+
+const (
+	// DefaultUsernameEnv is the environment variable read by [EnvCredentials]
+	// when UsernameVar is empty.
+	DefaultUsernameEnv = "AT_USERNAME"
+	// DefaultPasswordEnv is the environment variable read by [EnvCredentials]
+	// when PasswordVar is empty.
+	DefaultPasswordEnv = "AT_PASSWORD"
+)
+
+// EnvCredentials is a [CredentialProvider] that reads the credentials from
+// environment variables on every call.
+type EnvCredentials struct {
+	// UsernameVar is the variable holding the username. Defaults to
+	// [DefaultUsernameEnv].
+	UsernameVar string
+	// PasswordVar is the variable holding the password. Defaults to
+	// [DefaultPasswordEnv].
+	PasswordVar string
+}
+
+// Credentials reads the username and password variables.
+func (e EnvCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	userVar, passVar := e.UsernameVar, e.PasswordVar
+	if userVar == "" {
+		userVar = DefaultUsernameEnv
+	}
+	if passVar == "" {
+		passVar = DefaultPasswordEnv
+	}
+
+	username, ok := os.LookupEnv(userVar)
+	if !ok || username == "" {
+		return Credentials{}, fmt.Errorf("environment variable %s is not set", userVar)
+	}
+	password, ok := os.LookupEnv(passVar)
+	if !ok {
+		return Credentials{}, fmt.Errorf("environment variable %s is not set", passVar)
+	}
+	return Credentials{Username: username, Password: password}, nil
+}
+
+// -----------------------------------------------------------------------
+// Multi-tenant credentials
+// -----------------------------------------------------------------------
+
+type nifContextKey struct{}
+
+// ContextWithNIF returns a copy of ctx that carries the taxpayer NIF the call
+// is made on behalf of. [TenantCredentials] uses it to select credentials.
+func ContextWithNIF(ctx context.Context, nif string) context.Context {
+	return context.WithValue(ctx, nifContextKey{}, nif)
+}
+
+// NIFFromContext returns the NIF stored in ctx by [ContextWithNIF].
+func NIFFromContext(ctx context.Context) (string, bool) {
+	nif, ok := ctx.Value(nifContextKey{}).(string)
+	return nif, ok && nif != ""
+}
+
+// TenantCredentials is a [CredentialProvider] for processes that call AT on
+// behalf of several taxpayers. It selects the provider registered for the NIF
+// carried by the request context (see [ContextWithNIF]).
+//
+// The zero value is ready to use. It is safe for concurrent use, and tenants
+// may be added or replaced while calls are in flight.
+type TenantCredentials struct {
+	mu      sync.RWMutex
+	tenants map[string]CredentialProvider
+}
+
+// Set registers the provider used for nif, replacing any previous one.
+func (t *TenantCredentials) Set(nif string, provider CredentialProvider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tenants == nil {
+		t.tenants = make(map[string]CredentialProvider)
+	}
+	t.tenants[nif] = provider
+}
+
+// Remove unregisters nif.
+func (t *TenantCredentials) Remove(nif string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tenants, nif)
+}
+
+// Credentials returns the credentials of the tenant whose NIF is in ctx.
+func (t *TenantCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	nif, ok := NIFFromContext(ctx)
+	if !ok {
+		return Credentials{}, fmt.Errorf("no NIF in context")
+	}
+
+	t.mu.RLock()
+	provider, ok := t.tenants[nif]
+	t.mu.RUnlock()
+	if !ok {
+		return Credentials{}, fmt.Errorf("no credentials for NIF %s", nif)
+	}
+	return provider.Credentials(ctx)
+}
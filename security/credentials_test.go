@@ -0,0 +1,65 @@
+package security
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvCredentials(t *testing.T) {
+	t.Setenv("TEST_AT_USER", "555555555/1")
+	t.Setenv("TEST_AT_PASS", "first")
+
+	provider := EnvCredentials{UsernameVar: "TEST_AT_USER", PasswordVar: "TEST_AT_PASS"}
+	got, err := provider.Credentials(context.Background())
+	if err != nil {
+		t.Fatalf("Credentials() error = %v", err)
+	}
+	if got != (Credentials{Username: "555555555/1", Password: "first"}) {
+		t.Errorf("Credentials() = %+v", got)
+	}
+
+	// Rotated credentials are picked up on the next call.
+	t.Setenv("TEST_AT_PASS", "second")
+	got, _ = provider.Credentials(context.Background())
+	if got.Password != "second" {
+		t.Errorf("Password = %q, want second", got.Password)
+	}
+
+	if _, err := (EnvCredentials{UsernameVar: "TEST_AT_MISSING"}).Credentials(context.Background()); err == nil {
+		t.Errorf("Credentials() with unset variable, want error")
+	}
+}
+
+func TestTenantCredentials(t *testing.T) {
+	var tenants TenantCredentials
+	tenants.Set("555555555", NewStaticCredentials("555555555/1", "a"))
+	tenants.Set("999999999", NewStaticCredentials("999999999/2", "b"))
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		wantErr bool
+	}{
+		{"first tenant", ContextWithNIF(context.Background(), "555555555"), "555555555/1", false},
+		{"second tenant", ContextWithNIF(context.Background(), "999999999"), "999999999/2", false},
+		{"unknown tenant", ContextWithNIF(context.Background(), "123456789"), "", true},
+		{"no NIF", context.Background(), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tenants.Credentials(tt.ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Credentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Username != tt.want {
+				t.Errorf("Username = %q, want %q", got.Username, tt.want)
+			}
+		})
+	}
+
+	tenants.Remove("555555555")
+	if _, err := tenants.Credentials(ContextWithNIF(context.Background(), "555555555")); err == nil {
+		t.Errorf("Credentials() after Remove, want error")
+	}
+}
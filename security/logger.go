@@ -15,6 +15,9 @@ type HTTPDoer interface {
 // LoggingHTTPClient intercepts and logs HTTP requests and responses.
 // It implements the HTTPClient interface required by the gowsdl soap package.
 //
+// The dump includes the WS-Security token and every NIF and address in the
+// request; use [SlogHTTPClient] outside development.
+//
 // Usage:
 //
 //	httpClient := &http.Client{
@@ -30,7 +33,7 @@ type HTTPDoer interface {
 //	}
 //
 //	client := soap.NewClient(
-//		"https://servicos.portaldasfinancas.gov.pt:701/SeriesWSService/SeriesWS",
+//		seriesws.TestURL,
 //		soap.WithHTTPClient(loggingClient),
 //	)
 type LoggingHTTPClient struct {
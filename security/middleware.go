@@ -0,0 +1,277 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPDoerFunc adapts an ordinary function to an [HTTPDoer].
+type HTTPDoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f HTTPDoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps an [HTTPDoer] with additional behaviour.
+type Middleware func(next HTTPDoer) HTTPDoer
+
+// Chain wraps doer with the given middlewares. The first middleware is the
+// outermost one, i.e. it sees the request first:
+//
+//	client := security.Chain(httpClient,
+//		security.RateLimit(200*time.Millisecond, 5),
+//		security.ConcurrencyLimit(4),
+//		security.CircuitBreaker(5, 30*time.Second),
+//	)
+//
+// Retries are not an HTTP middleware: every attempt needs a new WS-Security
+// header, so they are done by the SOAP client (see soap.WithRetry) with a
+// [RetryPolicy].
+func Chain(doer HTTPDoer, middlewares ...Middleware) HTTPDoer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		doer = middlewares[i](doer)
+	}
+	return doer
+}
+
+// isServerFailure reports whether a reply indicates that the endpoint itself
+// is unavailable. 500 is excluded because AT returns SOAP faults, including
+// authentication and validation errors, with that status, and so are errors
+// caused by the caller cancelling req or letting its deadline pass.
+func isServerFailure(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return !callerGaveUp(req, err)
+	}
+	return resp.StatusCode > 500 && resp.StatusCode <= 599
+}
+
+// callerGaveUp reports whether err comes from the context of req being done.
+func callerGaveUp(req *http.Request, err error) bool {
+	return req.Context().Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// -----------------------------------------------------------------------
+// Retry policy
+// -----------------------------------------------------------------------
+
+// RetryPolicy configures retries with exponential backoff and full jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff ceiling before the first retry; it doubles on
+	// every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff ceiling.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy makes up to 4 attempts, waiting at most 500ms, 1s and
+// 2s between them.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// Delay returns a random wait before retry number attempt (1 for the first
+// retry), drawn uniformly from [0, min(MaxDelay, BaseDelay*2^(attempt-1))].
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if attempt < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+	ceiling := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || ceiling < p.MaxDelay); i++ {
+		ceiling *= 2
+	}
+	if p.MaxDelay > 0 && ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	return rand.N(ceiling + 1)
+}
+
+// -----------------------------------------------------------------------
+// Circuit breaker
+// -----------------------------------------------------------------------
+
+// ErrCircuitOpen is returned by [CircuitBreaker] while an endpoint is
+// considered down.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker stops sending requests to an endpoint (scheme, host and
+// path) after failureThreshold consecutive failures, returning
+// [ErrCircuitOpen] instead. After cooldown a single trial request is let
+// through; its success closes the circuit again.
+//
+// Transport errors and 5xx replies other than 500 count as failures.
+func CircuitBreaker(failureThreshold int, cooldown time.Duration) Middleware {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return func(next HTTPDoer) HTTPDoer {
+		cb := &circuitBreaker{
+			next:      next,
+			threshold: failureThreshold,
+			cooldown:  cooldown,
+			endpoints: make(map[string]*circuitState),
+			now:       time.Now,
+		}
+		return cb
+	}
+}
+
+type circuitState struct {
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+type circuitBreaker struct {
+	next      HTTPDoer
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*circuitState
+}
+
+func (cb *circuitBreaker) Do(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+
+	cb.mu.Lock()
+	st, ok := cb.endpoints[endpoint]
+	if !ok {
+		st = &circuitState{}
+		cb.endpoints[endpoint] = st
+	}
+	if st.failures >= cb.threshold {
+		if st.trial || cb.now().Before(st.openUntil) {
+			cb.mu.Unlock()
+			return nil, fmt.Errorf("%s: %w", endpoint, ErrCircuitOpen)
+		}
+		st.trial = true
+	}
+	cb.mu.Unlock()
+
+	resp, err := cb.next.Do(req)
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	st.trial = false
+	switch {
+	case isServerFailure(req, resp, err):
+		st.failures++
+		if st.failures >= cb.threshold {
+			st.openUntil = cb.now().Add(cb.cooldown)
+		}
+	case err != nil:
+		// The caller gave up: nothing is known about the endpoint.
+	default:
+		st.failures = 0
+	}
+	return resp, err
+}
+
+// -----------------------------------------------------------------------
+// Concurrency limiter
+// -----------------------------------------------------------------------
+
+// ConcurrencyLimit allows at most n requests in flight at once. Further
+// requests wait for a free slot or for their context to be done.
+func ConcurrencyLimit(n int) Middleware {
+	if n < 1 {
+		n = 1
+	}
+	return func(next HTTPDoer) HTTPDoer {
+		slots := make(chan struct{}, n)
+		return HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+			select {
+			case slots <- struct{}{}:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			defer func() { <-slots }()
+			return next.Do(req)
+		})
+	}
+}
+
+// -----------------------------------------------------------------------
+// Rate limiter
+// -----------------------------------------------------------------------
+
+// RateLimit allows on average one request every interval, with bursts of up
+// to burst requests (token bucket). Requests over the limit wait for a
+// token or for their context to be done.
+func RateLimit(interval time.Duration, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	return func(next HTTPDoer) HTTPDoer {
+		rl := &rateLimiter{
+			interval: interval,
+			burst:    float64(burst),
+			tokens:   float64(burst),
+			last:     time.Now(),
+		}
+		return HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+			if wait := rl.reserve(); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-req.Context().Done():
+					timer.Stop()
+					rl.cancel()
+					return nil, req.Context().Err()
+				}
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+type rateLimiter struct {
+	interval time.Duration
+	burst    float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait before the token is actually available.
+func (rl *rateLimiter) reserve() time.Duration {
+	if rl.interval <= 0 {
+		return 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	rl.tokens += float64(now.Sub(rl.last)) / float64(rl.interval)
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens * float64(rl.interval))
+}
+
+// cancel returns a reserved token that was not used.
+func (rl *rateLimiter) cancel() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.tokens++
+}
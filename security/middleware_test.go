@@ -0,0 +1,143 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func statusDoer(status *int32, calls *int32) HTTPDoer {
+	return HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(calls, 1)
+		return &http.Response{StatusCode: int(atomic.LoadInt32(status)), Body: http.NoBody}, nil
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var status, calls int32 = http.StatusServiceUnavailable, 0
+	doer := CircuitBreaker(2, time.Hour)(statusDoer(&status, &calls)).(*circuitBreaker)
+	now := time.Now()
+	doer.now = func() time.Time { return now }
+
+	req, _ := http.NewRequest(http.MethodPost, "https://at.example/ws", nil)
+	for i := 0; i < 2; i++ {
+		if _, err := doer.Do(req); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+	}
+	if _, err := doer.Do(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Do() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+
+	// A SOAP fault (500) does not count as an endpoint failure.
+	other, _ := http.NewRequest(http.MethodPost, "https://at.example/other", nil)
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	for i := 0; i < 3; i++ {
+		if _, err := doer.Do(other); err != nil {
+			t.Fatalf("other endpoint: %v", err)
+		}
+	}
+
+	// After the cooldown a successful trial closes the circuit.
+	now = now.Add(2 * time.Hour)
+	atomic.StoreInt32(&status, http.StatusOK)
+	if _, err := doer.Do(req); err != nil {
+		t.Fatalf("trial request: %v", err)
+	}
+	if _, err := doer.Do(req); err != nil {
+		t.Fatalf("after trial: %v", err)
+	}
+}
+
+func TestCircuitBreakerCallerGaveUp(t *testing.T) {
+	var calls int32
+	doer := CircuitBreaker(2, time.Hour)(HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	for _, ctx := range []context.Context{cancelled, expired} {
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://at.example/ws", nil)
+			if _, err := doer.Do(req); errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("attempt %d: circuit opened by the caller's context", i)
+			}
+		}
+	}
+	if calls != 6 {
+		t.Errorf("calls = %d, want 6", calls)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+	doer := ConcurrencyLimit(2)(HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	done := make(chan struct{})
+	for i := 0; i < 6; i++ {
+		go func() {
+			req, _ := http.NewRequest(http.MethodPost, "https://at.example/ws", nil)
+			doer.Do(req)
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	if maxInFlight > 2 {
+		t.Errorf("max in flight = %d, want <= 2", maxInFlight)
+	}
+}
+
+func TestRateLimitContext(t *testing.T) {
+	var status, calls int32 = http.StatusOK, 0
+	doer := RateLimit(time.Hour, 1)(statusDoer(&status, &calls))
+
+	req, _ := http.NewRequest(http.MethodPost, "https://at.example/ws", nil)
+	if _, err := doer.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := doer.Do(req.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() over the limit error = %v, want DeadlineExceeded", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	ceilings := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, ceiling := range ceilings {
+		for j := 0; j < 50; j++ {
+			if d := p.Delay(i + 1); d < 0 || d > ceiling {
+				t.Fatalf("Delay(%d) = %s, want within [0, %s]", i+1, d, ceiling)
+			}
+		}
+	}
+}
@@ -59,6 +59,12 @@ type UsernameToken struct {
 // A new random symmetric key is generated on every call, so each invocation
 // produces a different nonce — as required by the AT to prevent replay attacks.
 func Build(username, password string, atPubKey *rsa.PublicKey) (Header, error) {
+	return BuildAt(username, password, atPubKey, time.Now())
+}
+
+// BuildAt is like [Build] but writes now, instead of the current time, to
+// the encrypted Created field. See [HeaderBuilder] and [SkewDetector].
+func BuildAt(username, password string, atPubKey *rsa.PublicKey, now time.Time) (Header, error) {
 	// Step 1 — random 128-bit AES symmetric key for this request only.
 	ks := make([]byte, 16)
 	if _, err := rand.Read(ks); err != nil {
@@ -79,7 +85,7 @@ func Build(username, password string, atPubKey *rsa.PublicKey) (Header, error) {
 
 	// Step 4 — encrypt current UTC timestamp (ISO 8601).
 	// The AT server uses this to enforce request freshness.
-	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	timestamp := now.UTC().Format("2006-01-02T15:04:05.000Z")
 	encTimestamp, err := aesECBEncrypt(ks, []byte(timestamp))
 	if err != nil {
 		return Header{}, fmt.Errorf("encrypt timestamp: %w", err)
@@ -0,0 +1,321 @@
+package security
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxLogBodySize is the number of body bytes logged by
+// [SlogHTTPClient] when MaxBodySize is zero.
+const DefaultMaxLogBodySize = 4096
+
+// minPeekSize is the smallest number of body bytes read by [SlogHTTPClient]
+// to find the operation name and the AT result code.
+const minPeekSize = 16 << 10
+
+// secretElements are the WS-Security elements whose content is always
+// replaced by [SlogHTTPClient], whatever their namespace prefix.
+var secretElements = []string{"Password", "Nonce", "Created"}
+
+// taxIDElements are the elements holding a NIF (or a username that starts
+// with one) masked by [SlogHTTPClient] when MaskPersonalData is set.
+var taxIDElements = []string{
+	"Username", "TaxRegistrationNumber", "CustomerTaxID", "SupplierTaxID",
+	"ProductCompanyTaxID", "nifComunicou",
+}
+
+// personalDataElements are the name, address and contact elements masked by
+// [SlogHTTPClient] when MaskPersonalData is set.
+var personalDataElements = []string{
+	"CompanyName", "BusinessName", "CustomerName", "Contact", "StreetName",
+	"AddressDetail", "Addressdetail", "BuildingNumber", "City", "PostalCode",
+	"Telephone", "Fax", "Email",
+}
+
+// atCodeElements hold the operation result code in the replies of each
+// AT webservice (seriesws, workDocuments and fatcorews respectively).
+var atCodeElements = []string{"codResultOper", "ReturnCode", "CodigoResposta"}
+
+const redacted = "[REDACTED]"
+
+// SlogHTTPClient is a structured, redacting alternative to
+// [LoggingHTTPClient], safe to enable in production.
+//
+// Every call is logged as a single record with the operation name, endpoint,
+// HTTP status, latency and AT result code as attributes. The WS-Security
+// Password, Nonce and Created values are always redacted; NIFs and personal
+// data are masked when MaskPersonalData is set. Bodies are truncated to
+// MaxBodySize bytes. Only the start of each body is read for the record;
+// the rest is streamed to the caller without being buffered.
+//
+//	client := soap.NewClient(
+//		seriesws.ProdURL,
+//		soap.WithHTTPClient(&security.SlogHTTPClient{
+//			Client:           httpClient,
+//			Logger:           slog.Default(),
+//			MaskPersonalData: true,
+//			SampleRate:       0.1,
+//		}),
+//	)
+type SlogHTTPClient struct {
+	// Client is the underlying HTTP client (defaults to http.DefaultClient if nil).
+	Client HTTPDoer
+
+	// Logger receives the records (defaults to slog.Default() if nil).
+	Logger *slog.Logger
+
+	// Level is the level of successful calls. Failed calls (transport
+	// errors and non-2xx replies) are logged at slog.LevelError.
+	Level slog.Level
+
+	// MaskPersonalData masks NIFs, names, addresses and contacts in the
+	// logged bodies.
+	MaskPersonalData bool
+
+	// MaxBodySize limits the number of body bytes logged per message.
+	// Zero means [DefaultMaxLogBodySize]; a negative value omits bodies.
+	MaxBodySize int
+
+	// SampleRate is the fraction of successful calls that are logged, in
+	// (0, 1]. Zero logs every call. Failed calls are always logged.
+	SampleRate float64
+}
+
+// Do executes the HTTP request and logs a redacted summary of the exchange.
+func (l *SlogHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	reqBody, reqMore, err := peekBody(&req.Body, l.peekSize())
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+
+	attrs := []slog.Attr{
+		slog.String("operation", operationName(req, reqBody)),
+		slog.String("url", req.URL.Redacted()),
+		slog.Duration("latency", latency),
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		attrs = l.appendBody(attrs, "request_body", reqBody, reqMore)
+		l.logger().LogAttrs(req.Context(), slog.LevelError, "soap call failed", attrs...)
+		return resp, err
+	}
+
+	respBody, respMore, readErr := peekBody(&resp.Body, l.peekSize())
+	if readErr != nil {
+		return resp, readErr
+	}
+
+	failed := resp.StatusCode < 200 || resp.StatusCode > 299
+	if !failed && !l.sampled() {
+		return resp, nil
+	}
+
+	attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	if code, ok := atResultCode(respBody); ok {
+		attrs = append(attrs, slog.Int("at_code", code))
+	}
+	attrs = l.appendBody(attrs, "request_body", reqBody, reqMore)
+	attrs = l.appendBody(attrs, "response_body", respBody, respMore)
+
+	level, msg := l.Level, "soap call"
+	if failed {
+		level, msg = slog.LevelError, "soap call failed"
+	}
+	l.logger().LogAttrs(req.Context(), level, msg, attrs...)
+
+	return resp, nil
+}
+
+func (l *SlogHTTPClient) logger() *slog.Logger {
+	if l.Logger == nil {
+		return slog.Default()
+	}
+	return l.Logger
+}
+
+func (l *SlogHTTPClient) sampled() bool {
+	if l.SampleRate <= 0 || l.SampleRate >= 1 {
+		return true
+	}
+	return rand.Float64() < l.SampleRate
+}
+
+func (l *SlogHTTPClient) maxBodySize() int {
+	if l.MaxBodySize == 0 {
+		return DefaultMaxLogBodySize
+	}
+	return l.MaxBodySize
+}
+
+// peekSize is the number of body bytes read for the record.
+func (l *SlogHTTPClient) peekSize() int {
+	return max(l.maxBodySize(), minPeekSize)
+}
+
+// appendBody appends the redacted body, truncated to MaxBodySize bytes.
+// more reports that body is only the start of the message.
+func (l *SlogHTTPClient) appendBody(attrs []slog.Attr, key string, body []byte, more bool) []slog.Attr {
+	limit := l.maxBodySize()
+	if limit < 0 || len(body) == 0 {
+		return attrs
+	}
+
+	s := RedactXML(string(body), l.MaskPersonalData)
+	if len(s) > limit {
+		s, more = s[:limit], true
+	}
+	if more {
+		s = trimIncompleteRune(s) + "...(truncated)"
+	}
+	return append(attrs, slog.String(key, s))
+}
+
+// trimIncompleteRune removes the bytes of a UTF-8 sequence cut at the end of
+// s.
+func trimIncompleteRune(s string) string {
+	for i := 0; i < utf8.UTFMax-1 && s != ""; i++ {
+		if r, size := utf8.DecodeLastRuneInString(s); r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// RedactXML replaces the content of the WS-Security secret elements in s.
+// If maskPersonalData is true, NIFs, names, addresses and contacts are
+// masked as well.
+func RedactXML(s string, maskPersonalData bool) string {
+	s = secretPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return replaceContent(secretPattern, m, func(string) string { return redacted })
+	})
+	if !maskPersonalData {
+		return s
+	}
+	s = taxIDPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return replaceContent(taxIDPattern, m, maskTaxID)
+	})
+	return personalDataPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return replaceContent(personalDataPattern, m, func(string) string { return "***" })
+	})
+}
+
+var (
+	secretPattern       = elementPattern(secretElements)
+	taxIDPattern        = elementPattern(taxIDElements)
+	personalDataPattern = elementPattern(personalDataElements)
+	atCodePattern       = elementPattern(atCodeElements)
+)
+
+// elementPattern matches the opening tag and text content of any of the
+// named elements, with or without a namespace prefix. Group 1 is the tag and
+// group 2 the content.
+func elementPattern(names []string) *regexp.Regexp {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = regexp.QuoteMeta(n)
+	}
+	return regexp.MustCompile(`(<(?:[\w.-]+:)?(?:` + strings.Join(quoted, "|") + `)(?:\s[^>]*)?>)([^<]*)`)
+}
+
+func replaceContent(re *regexp.Regexp, match string, fn func(string) string) string {
+	sub := re.FindStringSubmatch(match)
+	if sub[2] == "" {
+		return match
+	}
+	return sub[1] + fn(sub[2])
+}
+
+// maskTaxID keeps the last three digits of a NIF and any "/subuser" suffix.
+func maskTaxID(v string) string {
+	nif, suffix, _ := strings.Cut(v, "/")
+	if len(nif) <= 3 {
+		nif = strings.Repeat("*", len(nif))
+	} else {
+		nif = strings.Repeat("*", len(nif)-3) + nif[len(nif)-3:]
+	}
+	if suffix != "" {
+		return nif + "/" + suffix
+	}
+	return nif
+}
+
+// atResultCode returns the first AT operation result code found in body.
+func atResultCode(body []byte) (int, bool) {
+	sub := atCodePattern.FindSubmatch(body)
+	if sub == nil {
+		return 0, false
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(sub[2])))
+	return code, err == nil
+}
+
+// operationName returns the local name of the first element of the SOAP
+// Body, falling back to the last segment of the SOAPAction header.
+func operationName(req *http.Request, body []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	inBody := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if inBody {
+				return se.Name.Local
+			}
+			inBody = se.Name.Local == "Body"
+		}
+	}
+
+	action := strings.Trim(req.Header.Get("SOAPAction"), `"'/`)
+	if i := strings.LastIndex(action, "/"); i >= 0 {
+		action = action[i+1:]
+	}
+	return action
+}
+
+// peekBody reads up to n bytes of *body and replaces *body with a reader
+// that returns them followed by the rest, so the caller still reads the
+// whole body. more reports whether the body is longer than n bytes.
+func peekBody(body *io.ReadCloser, n int) (data []byte, more bool, err error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, false, nil
+	}
+	data, err = io.ReadAll(io.LimitReader(*body, int64(n)+1))
+	if err != nil {
+		(*body).Close()
+		return nil, false, err
+	}
+	rest := *body
+	*body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), rest), rest}
+	if len(data) > n {
+		return data[:n], true, nil
+	}
+	return data, false, nil
+}
+
+// Compile-time check.
+var _ HTTPDoer = (*SlogHTTPClient)(nil)
@@ -0,0 +1,124 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testRequest = `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Header><wss:Security xmlns:wss="http://schemas.xmlsoap.org/ws/2002/12/secext"><wss:UsernameToken><wss:Username>555555555/37</wss:Username><wss:Password>c2VjcmV0</wss:Password><wss:Nonce>bm9uY2U=</wss:Nonce><wss:Created>Y3JlYXRlZA==</wss:Created></wss:UsernameToken></wss:Security></soapenv:Header><soapenv:Body><ns1:envioDocumentoTransporteRequestElem xmlns:ns1="urn:x"><CustomerTaxID>123456789</CustomerTaxID><CustomerAddress><AddressDetail>Rua Augusta 1</AddressDetail></CustomerAddress></ns1:envioDocumentoTransporteRequestElem></soapenv:Body></soapenv:Envelope>`
+
+func TestRedactXML(t *testing.T) {
+	got := RedactXML(testRequest, false)
+	for _, secret := range []string{"c2VjcmV0", "bm9uY2U=", "Y3JlYXRlZA=="} {
+		if strings.Contains(got, secret) {
+			t.Errorf("RedactXML() leaked %q", secret)
+		}
+	}
+	if !strings.Contains(got, "<CustomerTaxID>123456789<") {
+		t.Errorf("RedactXML() masked personal data without maskPersonalData")
+	}
+
+	got = RedactXML(testRequest, true)
+	for _, want := range []string{
+		"<wss:Username>******555/37<",
+		"<CustomerTaxID>******789<",
+		"<AddressDetail>***<",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RedactXML() = %s, want it to contain %s", got, want)
+		}
+	}
+}
+
+func TestSlogHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/"><S:Body><ns2:envioDocumentoTransporteResponseElem xmlns:ns2="urn:x"><ResponseStatus><ReturnCode>-3</ReturnCode></ResponseStatus></ns2:envioDocumentoTransporteResponseElem></S:Body></S:Envelope>`)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &SlogHTTPClient{
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+		MaxBodySize: 64,
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(testRequest))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "<ReturnCode>-3<") {
+		t.Errorf("response body was not restored: %s", body)
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record %q: %v", buf.String(), err)
+	}
+	if record["operation"] != "envioDocumentoTransporteRequestElem" {
+		t.Errorf("operation = %v", record["operation"])
+	}
+	if record["status"] != float64(200) || record["at_code"] != float64(-3) {
+		t.Errorf("status = %v, at_code = %v", record["status"], record["at_code"])
+	}
+	if s, _ := record["request_body"].(string); !strings.HasSuffix(s, "...(truncated)") || len(s) > 64+len("...(truncated)") {
+		t.Errorf("request_body = %q, want it truncated to 64 bytes", s)
+	}
+}
+
+func TestSlogHTTPClientLargeBody(t *testing.T) {
+	large := "<S:Envelope><S:Body><Nome>" + strings.Repeat("çã", 1<<16) + "</Nome></S:Body></S:Envelope>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		io.WriteString(w, large)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := &SlogHTTPClient{
+		Logger:      slog.New(slog.NewJSONHandler(&buf, nil)),
+		MaxBodySize: 31,
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(testRequest))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != large {
+		t.Errorf("response body was not restored: %d bytes, want %d", len(body), len(large))
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record %q: %v", buf.String(), err)
+	}
+	// 31 bytes end in the middle of the second "ç".
+	if s := record["response_body"]; s != "<S:Envelope><S:Body><Nome>çã...(truncated)" {
+		t.Errorf("response_body = %q", s)
+	}
+}
+
+func TestPeekBody(t *testing.T) {
+	for _, n := range []int{3, 5, 10} {
+		body := io.NopCloser(strings.NewReader("corpo"))
+		data, more, err := peekBody(&body, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "corpo"[:min(n, 5)]; string(data) != want || more != (n < 5) {
+			t.Errorf("peekBody(%d) = %q, %v", n, data, more)
+		}
+		if rest, _ := io.ReadAll(body); string(rest) != "corpo" {
+			t.Errorf("peekBody(%d) left %q", n, rest)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package seriesws
 
 const (
+	// TestURL is the AT staging endpoint for series communication.
 	TestURL = "https://servicos.portaldasfinancas.gov.pt:722/SeriesWSService"
+	// ProdURL is the AT production endpoint for series communication.
 	ProdURL = "https://servicos.portaldasfinancas.gov.pt:422/SeriesWSService"
 )
@@ -9,6 +9,7 @@ import (
 
 	"github.com/hestiatechnology/autoridadetributaria/security"
 	"github.com/hestiatechnology/autoridadetributaria/seriesws"
+	atsoap "github.com/hestiatechnology/autoridadetributaria/soap"
 	"github.com/hooklift/gowsdl/soap"
 )
 
@@ -46,21 +47,18 @@ func Example() {
 	}
 
 	// 4. Initialize the SOAP client pointing to the AT webservice endpoint
-	// Test env: https://servicos.portaldasfinancas.gov.pt:701/SeriesWSService/SeriesWS
-	// Prod env: https://servicos.portaldasfinancas.gov.pt:401/SeriesWSService/SeriesWS
-	client := soap.NewClient(
-		"https://servicos.portaldasfinancas.gov.pt:701/SeriesWSService/SeriesWS",
-		soap.WithHTTPClient(loggingClient),
-	)
-
-	// 5. Build and add the WS-Security header
+	// Test env: seriesws.TestURL
+	// Prod env: seriesws.ProdURL
+	//
+	// 5. Add the WS-Security header
 	// The username is typically the NIF and subuser ID (e.g., "555555555/37")
 	// The password is the subuser password configured in Portal das Finanças
-	secHeader, err := security.Build("555555555/37", "subuser_password", atPubKey)
-	if err != nil {
-		log.Fatalf("Failed to build security header: %v", err)
-	}
-	client.AddHeader(secHeader)
+	// A new header (nonce, timestamp) is built for every call.
+	client := atsoap.NewClient(
+		seriesws.TestURL,
+		atsoap.WithHTTPClient(loggingClient),
+		atsoap.WithWSSecurity("555555555/37", "subuser_password", atPubKey),
+	)
 
 	// 6. Create the SeriesWS service client using the configured SOAP client
 	service := seriesws.NewSeriesWS(client)
@@ -7,6 +7,7 @@ import (
 	"encoding/xml"
 	"time"
 
+	atsoap "github.com/hestiatechnology/autoridadetributaria/soap"
 	"github.com/hooklift/gowsdl/soap"
 )
 
@@ -301,10 +302,10 @@ type SeriesWS interface {
 }
 
 type seriesWS struct {
-	client *soap.Client
+	client atsoap.Caller
 }
 
-func NewSeriesWS(client *soap.Client) SeriesWS {
+func NewSeriesWS(client atsoap.Caller) SeriesWS {
 	return &seriesWS{
 		client: client,
 	}
@@ -1 +1,454 @@
-package soap
+// Package soap implements the SOAP 1.1 transport shared by every AT
+// webservice client in this module (seriesws, fatcorews and workDocuments).
+//
+// A [Client] builds the envelope, injects a freshly generated WS-Security
+// header on every call (see [security.Build]), sets the SOAPAction header,
+// and turns SOAP faults into [*Fault] errors:
+//
+//	client := soap.NewClient(
+//		seriesws.TestURL,
+//		soap.WithClientCertificate(clientCert),
+//		soap.WithWSSecurity("555555555/37", "subuser_password", atPubKey),
+//	)
+//	service := seriesws.NewSeriesWS(client)
+package soap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/security"
+	"golang.org/x/net/html/charset"
+)
+
+// EnvelopeNS is the SOAP 1.1 envelope namespace.
+const EnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// Caller is the interface used by the generated service clients to perform
+// a SOAP call. It is implemented by [*Client] and, for backwards
+// compatibility, by gowsdl's *soap.Client.
+type Caller interface {
+	CallContext(ctx context.Context, soapAction string, request, response interface{}) error
+}
+
+// HeaderFunc builds the SOAP header element for a single call. It is invoked
+// once per call, so it can produce per-request values such as the
+// WS-Security nonce and timestamp.
+type HeaderFunc func(ctx context.Context) (interface{}, error)
+
+// Option configures a [Client].
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to send requests, e.g. a
+// [security.LoggingHTTPClient] wrapping an mTLS *http.Client. It takes
+// precedence over [WithClientCertificate] and [WithTimeout].
+func WithHTTPClient(c security.HTTPDoer) Option {
+	return func(s *Client) {
+		s.httpClient = c
+	}
+}
+
+// WithClientCertificate configures the default HTTP client for mutual TLS
+// with the software producer's AT client certificate.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(s *Client) {
+		s.certificates = append(s.certificates, cert)
+	}
+}
+
+// WithTimeout sets the request timeout of the default HTTP client.
+func WithTimeout(t time.Duration) Option {
+	return func(s *Client) {
+		s.timeout = t
+	}
+}
+
+// WithHeader sets the function that builds the SOAP header of every call.
+func WithHeader(fn HeaderFunc) Option {
+	return func(s *Client) {
+		s.header = fn
+	}
+}
+
+// WithWSSecurity injects an AT WS-Security UsernameToken header, built with
+// a new symmetric key on every call.
+func WithWSSecurity(username, password string, atPubKey *rsa.PublicKey) Option {
+	return WithCredentials(security.NewStaticCredentials(username, password), atPubKey)
+}
+
+// WithCredentials is like [WithWSSecurity], but asks provider for the
+// credentials on every call, with the call's context.
+func WithCredentials(provider security.CredentialProvider, atPubKey *rsa.PublicKey) Option {
+	return func(s *Client) {
+		s.header = func(ctx context.Context) (interface{}, error) {
+			return security.HeaderBuilder{
+				Credentials: provider,
+				ATPublicKey: atPubKey,
+				Now:         s.Now,
+			}.Build(ctx)
+		}
+	}
+}
+
+// WithClock sets the clock used for the WS-Security Created timestamp.
+func WithClock(now func() time.Time) Option {
+	return func(s *Client) {
+		s.now = now
+	}
+}
+
+// WithSkewDetector corrects the WS-Security Created timestamp by the offset
+// between the local clock and AT's, as measured by d from the Date header of
+// every reply. It overrides [WithClock].
+func WithSkewDetector(d *security.SkewDetector) Option {
+	return func(s *Client) {
+		s.skew = d
+	}
+}
+
+// WithRetry retries calls that AT cannot have processed: those whose
+// connection could not be established (DNS or dial errors, such as a
+// refused connection) and 503 replies without a SOAP fault. Other transport
+// errors, such as a timeout waiting for the reply, and other 5xx replies
+// are not retried, since the request may have been processed and not every
+// operation can be repeated safely. [security.ErrCircuitOpen] is not
+// retried. The envelope, and so the WS-Security header, is rebuilt for
+// every attempt: AT rejects a replayed nonce.
+//
+// SOAP faults are not retried, except a rejected Created timestamp once the
+// [WithSkewDetector] detector has measured the clock offset.
+func WithRetry(policy security.RetryPolicy) Option {
+	return func(s *Client) {
+		s.retry = policy
+	}
+}
+
+// Client is a SOAP 1.1 client for a single AT endpoint.
+// It is safe for concurrent use.
+type Client struct {
+	url          string
+	httpClient   security.HTTPDoer
+	certificates []tls.Certificate
+	timeout      time.Duration
+	header       HeaderFunc
+	retry        security.RetryPolicy
+	now          func() time.Time
+	skew         *security.SkewDetector
+}
+
+// NewClient creates a SOAP client for the given endpoint URL.
+func NewClient(url string, opts ...Option) *Client {
+	c := &Client{url: url}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Timeout: c.timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					Certificates: c.certificates,
+				},
+			},
+		}
+	}
+
+	if c.skew != nil {
+		c.httpClient = c.skew.Wrap(c.httpClient)
+		c.now = c.skew.Now
+	}
+
+	return c
+}
+
+// URL returns the endpoint the client sends requests to.
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Now returns the time used for the WS-Security Created timestamp.
+func (c *Client) Now() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// Call performs a SOAP call with a background context.
+func (c *Client) Call(soapAction string, request, response interface{}) error {
+	return c.CallContext(context.Background(), soapAction, request, response)
+}
+
+// CallContext marshals request into a SOAP envelope, posts it with the given
+// SOAPAction and unmarshals the body of the reply into response.
+//
+// A SOAP fault in the reply is returned as a [*Fault]; a non-2xx reply
+// without a fault is returned as an [*HTTPError].
+func (c *Client) CallContext(ctx context.Context, soapAction string, request, response interface{}) error {
+	for attempt := 1; ; attempt++ {
+		err := c.call(ctx, soapAction, request, response)
+		if err == nil || attempt >= c.retry.MaxAttempts || !c.retryable(ctx, err) {
+			return err
+		}
+
+		timer := time.NewTimer(c.retry.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// clockSkewError wraps a fault that rejected the Created timestamp with
+// [security.ErrClockSkew]. Both remain reachable with errors.Is/As.
+func (c *Client) clockSkewError(fault *Fault) error {
+	if c.skew != nil && c.skew.Offset() != 0 {
+		return fmt.Errorf("%w (AT clock offset %s): %w", security.ErrClockSkew, c.skew.Offset(), fault)
+	}
+	return fmt.Errorf("%w: %w", security.ErrClockSkew, fault)
+}
+
+// retryable reports whether err means the request was not processed: it
+// did not reach the endpoint, the endpoint was unavailable, or it was
+// rejected for a clock offset the skew detector has since measured and will
+// correct.
+func (c *Client) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, security.ErrCircuitOpen) {
+		return false
+	}
+	if errors.Is(err, security.ErrClockSkew) {
+		return c.skew != nil && c.skew.Offset() != 0
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusServiceUnavailable
+	}
+	var transportErr *transportError
+	return errors.As(err, &transportErr) && notSent(transportErr.err)
+}
+
+// notSent reports whether a transport error happened before the request
+// was written: the host could not be resolved or connected to.
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// transportError wraps errors returned by the HTTP client.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string { return "http post: " + e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+
+// call performs a single attempt of CallContext.
+func (c *Client) call(ctx context.Context, soapAction string, request, response interface{}) error {
+	env := envelope{
+		XmlNSSoapenv: EnvelopeNS,
+		Body:         body{Content: request},
+	}
+
+	if c.header != nil {
+		h, err := c.header(ctx)
+		if err != nil {
+			return fmt.Errorf("build soap header: %w", err)
+		}
+		env.Header = &header{Content: h}
+	}
+
+	envBytes, err := xml.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("marshal soap envelope: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(envBytes))
+	if err != nil {
+		return fmt.Errorf("create http request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	httpReq.Header.Set("SOAPAction", soapAction)
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return &transportError{err: err}
+	}
+	defer httpResp.Body.Close()
+
+	respBytes, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("read http response: %w", err)
+	}
+
+	err = decodeBody(respBytes, response)
+	var fault *Fault
+	if errors.As(err, &fault) {
+		fault.StatusCode = httpResp.StatusCode
+		if fault.IsClient() && security.IsTimestampRejection(fault.String) {
+			return c.clockSkewError(fault)
+		}
+		return fault
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return &HTTPError{StatusCode: httpResp.StatusCode, ResponseBody: respBytes}
+	}
+	return err
+}
+
+// decodeBody decodes the first element of the envelope Body into response.
+// The element is decoded in place, with the namespace declarations of the
+// enclosing Envelope and Body in scope.
+func decodeBody(data []byte, response interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+
+	inBody := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return fmt.Errorf("unmarshal soap response: missing Body")
+		}
+		if err != nil {
+			return fmt.Errorf("unmarshal soap response: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !inBody {
+				inBody = t.Name.Local == "Body"
+				continue
+			}
+			if t.Name.Local == "Fault" {
+				fault := &Fault{}
+				if err := dec.DecodeElement(fault, &t); err != nil {
+					return fmt.Errorf("unmarshal soap fault: %w", err)
+				}
+				return fault
+			}
+			if response == nil {
+				return nil
+			}
+			if err := dec.DecodeElement(response, &t); err != nil {
+				return fmt.Errorf("unmarshal soap body: %w", err)
+			}
+			return nil
+		case xml.EndElement:
+			if inBody && t.Name.Local == "Body" {
+				// Empty body
+				return nil
+			}
+		}
+	}
+}
+
+// -----------------------------------------------------------------------
+// Envelope
+// -----------------------------------------------------------------------
+
+type envelope struct {
+	XMLName      xml.Name `xml:"soapenv:Envelope"`
+	XmlNSSoapenv string   `xml:"xmlns:soapenv,attr"`
+	Header       *header  `xml:"soapenv:Header,omitempty"`
+	Body         body     `xml:"soapenv:Body"`
+}
+
+type header struct {
+	Content interface{} `xml:",any"`
+}
+
+type body struct {
+	Content interface{} `xml:",any"`
+}
+
+// -----------------------------------------------------------------------
+// Errors
+// -----------------------------------------------------------------------
+
+// Fault is a SOAP 1.1 fault returned by an AT webservice.
+type Fault struct {
+	// Code is the faultcode, e.g. "soap:Client" or "soap:Server".
+	Code string `xml:"faultcode"`
+	// String is the human readable faultstring.
+	String string `xml:"faultstring"`
+	// Actor is the optional faultactor.
+	Actor string `xml:"faultactor"`
+	// Detail is the raw inner XML of the optional detail element.
+	Detail FaultDetail `xml:"detail"`
+	// StatusCode is the HTTP status of the reply that carried the fault.
+	StatusCode int `xml:"-"`
+}
+
+// FaultDetail holds the raw content of a fault detail element.
+type FaultDetail struct {
+	Content string `xml:",innerxml"`
+}
+
+func (f *Fault) Error() string {
+	if f.Code == "" {
+		return "soap fault: " + f.String
+	}
+	return fmt.Sprintf("soap fault %s: %s", f.Code, f.String)
+}
+
+// IsClient reports whether the fault blames the request (faultcode Client),
+// e.g. a schema violation or an authentication failure.
+func (f *Fault) IsClient() bool {
+	return faultCodeLocal(f.Code) == "Client"
+}
+
+// IsServer reports whether the fault blames the server (faultcode Server).
+func (f *Fault) IsServer() bool {
+	return faultCodeLocal(f.Code) == "Server"
+}
+
+func faultCodeLocal(code string) string {
+	if i := strings.LastIndex(code, ":"); i >= 0 {
+		code = code[i+1:]
+	}
+	// SOAP 1.1 allows dotted sub-codes, e.g. "Client.Authentication".
+	if i := strings.Index(code, "."); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// HTTPError is returned when the endpoint replies with a non-2xx status and
+// no SOAP fault.
+type HTTPError struct {
+	StatusCode   int
+	ResponseBody []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("soap: http status %d: %s", e.StatusCode, string(e.ResponseBody))
+}
+
+// AsFault returns the [*Fault] wrapped in err, if any.
+func AsFault(err error) (*Fault, bool) {
+	var f *Fault
+	if errors.As(err, &f) {
+		return f, true
+	}
+	return nil, false
+}
@@ -0,0 +1,240 @@
+package soap
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hestiatechnology/autoridadetributaria/security"
+)
+
+type echoRequest struct {
+	XMLName xml.Name `xml:"at:echo"`
+	XmlNSAt string   `xml:"xmlns:at,attr"`
+	Value   string   `xml:"value"`
+}
+
+type echoResponse struct {
+	XMLName xml.Name `xml:"http://at.gov.pt/ echoResponse"`
+	Value   string   `xml:"value"`
+}
+
+func TestClientCallContext(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var env struct {
+			Header struct {
+				Security struct {
+					Nonce string `xml:"UsernameToken>Nonce"`
+				} `xml:"Security"`
+			} `xml:"Header"`
+			Value string `xml:"Body>echo>value"`
+		}
+		if err := xml.Unmarshal(body, &env); err != nil {
+			t.Errorf("server: unmarshal request: %v", err)
+		}
+		nonces = append(nonces, env.Header.Security.Nonce)
+
+		if r.Header.Get("SOAPAction") != "urn:echo" {
+			t.Errorf("SOAPAction = %q, want urn:echo", r.Header.Get("SOAPAction"))
+		}
+
+		if env.Value == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/"><S:Body><S:Fault><faultcode>S:Client</faultcode><faultstring>Autenticacao invalida</faultstring></S:Fault></S:Body></S:Envelope>`)
+			return
+		}
+		io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns2="http://at.gov.pt/"><S:Body><ns2:echoResponse><value>`+env.Value+`</value></ns2:echoResponse></S:Body></S:Envelope>`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithWSSecurity("555555555/1", "secret", &key.PublicKey))
+
+	var resp echoResponse
+	if err := client.Call("urn:echo", &echoRequest{XmlNSAt: "http://at.gov.pt/", Value: "ola"}, &resp); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if resp.Value != "ola" {
+		t.Errorf("response value = %q, want ola", resp.Value)
+	}
+
+	err = client.Call("urn:echo", &echoRequest{XmlNSAt: "http://at.gov.pt/", Value: "fail"}, &resp)
+	fault, ok := AsFault(err)
+	if !ok {
+		t.Fatalf("Call() error = %v, want *Fault", err)
+	}
+	if !fault.IsClient() || fault.String != "Autenticacao invalida" || fault.StatusCode != http.StatusInternalServerError {
+		t.Errorf("fault = %+v", fault)
+	}
+
+	if len(nonces) != 2 || nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Errorf("expected a fresh WS-Security nonce per call, got %q", nonces)
+	}
+}
+
+func TestClientHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "bad gateway")
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL).Call("''", &echoRequest{}, &echoResponse{})
+	httpErr, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("Call() error = %v, want *HTTPError", err)
+	}
+	if httpErr.StatusCode != http.StatusBadGateway || !strings.Contains(string(httpErr.ResponseBody), "bad gateway") {
+		t.Errorf("HTTPError = %+v", httpErr)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var nonces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env struct {
+			Nonce string `xml:"Header>Security>UsernameToken>Nonce"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		xml.Unmarshal(body, &env)
+		nonces = append(nonces, env.Nonce)
+
+		if len(nonces) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/"><S:Body><echoResponse xmlns="http://at.gov.pt/"><value>ok</value></echoResponse></S:Body></S:Envelope>`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL,
+		WithWSSecurity("555555555/1", "secret", &key.PublicKey),
+		WithRetry(security.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+	)
+
+	var resp echoResponse
+	if err := client.Call("urn:echo", &echoRequest{XmlNSAt: "http://at.gov.pt/"}, &resp); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if len(nonces) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(nonces))
+	}
+	if nonces[0] == nonces[1] || nonces[1] == nonces[2] {
+		t.Errorf("retries reused the WS-Security nonce: %q", nonces)
+	}
+}
+
+// TestClientRetryOnlyUnsent checks that only requests AT cannot have
+// processed are retried, since operations such as registering a series
+// must not be repeated.
+func TestClientRetryOnlyUnsent(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		attempts int
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, 0, 3},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "example.invalid"}, 0, 3},
+		{"read timeout", &net.OpError{Op: "read", Net: "tcp", Err: context.DeadlineExceeded}, 0, 1},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, 0, 1},
+		{"unavailable", nil, http.StatusServiceUnavailable, 3},
+		{"gateway timeout", nil, http.StatusGatewayTimeout, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			doer := security.HTTPDoerFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if tt.err != nil {
+					return nil, &url.Error{Op: "Post", URL: req.URL.String(), Err: tt.err}
+				}
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			client := NewClient("https://example.invalid/",
+				WithHTTPClient(doer),
+				WithRetry(security.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+			)
+			if err := client.Call("", &echoRequest{XmlNSAt: "http://at.gov.pt/"}, &echoResponse{}); err == nil {
+				t.Fatal("Call() succeeded")
+			}
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestClientClockSkew(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Date", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/"><S:Body><S:Fault><faultcode>S:Client</faultcode><faultstring>Created fora do intervalo permitido</faultstring></S:Fault></S:Body></S:Envelope>`)
+			return
+		}
+		io.WriteString(w, `<S:Envelope xmlns:S="http://schemas.xmlsoap.org/soap/envelope/"><S:Body><echoResponse xmlns="http://at.gov.pt/"><value>ok</value></echoResponse></S:Body></S:Envelope>`)
+	}))
+	defer server.Close()
+
+	// Without retries the rejection is reported as a clock problem.
+	skew := &security.SkewDetector{}
+	client := NewClient(server.URL,
+		WithWSSecurity("555555555/1", "secret", &key.PublicKey),
+		WithSkewDetector(skew),
+	)
+	err = client.Call("urn:echo", &echoRequest{XmlNSAt: "http://at.gov.pt/"}, &echoResponse{})
+	if !errors.Is(err, security.ErrClockSkew) {
+		t.Fatalf("Call() error = %v, want ErrClockSkew", err)
+	}
+	if _, ok := AsFault(err); !ok {
+		t.Errorf("Call() error = %v, want it to wrap the fault", err)
+	}
+	if off := skew.Offset(); off < 9*time.Minute {
+		t.Errorf("Offset() = %s, want about 10m", off)
+	}
+	if d := client.Now().Sub(time.Now()); d < 9*time.Minute {
+		t.Errorf("client clock is %s ahead, want about 10m", d)
+	}
+
+	// With retries the call is repeated with the corrected timestamp.
+	calls = 0
+	client = NewClient(server.URL,
+		WithWSSecurity("555555555/1", "secret", &key.PublicKey),
+		WithSkewDetector(&security.SkewDetector{}),
+		WithRetry(security.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+	)
+	if err := client.Call("urn:echo", &echoRequest{XmlNSAt: "http://at.gov.pt/"}, &echoResponse{}); err != nil {
+		t.Errorf("Call() with retry error = %v", err)
+	}
+}
@@ -1,25 +1,22 @@
 package workDocuments
 
 import (
-	"bytes"
+	"context"
 	"crypto/rsa"
 	"crypto/tls"
 	"encoding/xml"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
 
 	"github.com/hestiatechnology/autoridadetributaria/security"
+	"github.com/hestiatechnology/autoridadetributaria/soap"
 )
 
+const soapAction = "https://servicos.portaldasfinancas.gov.pt/sgdtws/documentosTransporte/"
+
 // Client is a SOAP client for the AT Documentos de Transporte webservice.
 type Client struct {
-	httpClient *http.Client
-	url        string
-	username   string
-	password   string
-	atPubKey   *rsa.PublicKey
+	soap        *soap.Client
+	credentials security.CredentialProvider
+	atPubKey    *rsa.PublicKey
 }
 
 // NewClient creates a new WS client configured for MTLS and WS-Security.
@@ -29,108 +26,63 @@ func NewClient(
 	clientCert tls.Certificate,
 	atPubKey *rsa.PublicKey,
 ) *Client {
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				Certificates: []tls.Certificate{clientCert},
-			},
-		},
-	}
-
-	return &Client{
-		httpClient: httpClient,
-		url:        url,
-		username:   username,
-		password:   password,
-		atPubKey:   atPubKey,
-	}
-}
-
-type soapHeader struct {
-	XMLName  xml.Name    `xml:"soapenv:Header"`
-	Security interface{} `xml:"wsse:Security"`
-}
-
-type soapBody struct {
-	XMLName xml.Name    `xml:"soapenv:Body"`
-	Content interface{} `xml:",any"`
-}
-
-type soapEnvelope struct {
-	XMLName xml.Name   `xml:"soapenv:Envelope"`
-	Soapenv string     `xml:"xmlns:soapenv,attr"`
-	Wsse    string     `xml:"xmlns:wsse,attr"`
-	Header  soapHeader `xml:"soapenv:Header"`
-	Body    soapBody   `xml:"soapenv:Body"`
+	return NewClientWithCredentials(url, security.NewStaticCredentials(username, password), clientCert, atPubKey)
 }
 
-type soapResponseEnvelope struct {
-	XMLName xml.Name `xml:"Envelope"`
-	Body    struct {
-		Fault   *soapFault `xml:"Fault"`
-		Content []byte     `xml:",innerxml"`
-	} `xml:"Body"`
-}
-
-type soapFault struct {
-	FaultCode   string `xml:"faultcode"`
-	FaultString string `xml:"faultstring"`
-}
-
-// call sends the request using the same WS-Security logic as the invoice client
-func (c *Client) call(requestBody interface{}) ([]byte, error) {
-	secHeader, err := security.Build(c.username, c.password, c.atPubKey)
-	if err != nil {
-		return nil, fmt.Errorf("build security header: %w", err)
-	}
-
-	env := soapEnvelope{
-		Soapenv: "http://schemas.xmlsoap.org/soap/envelope/",
-		Wsse:    "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd",
-		Header:  soapHeader{Security: secHeader},
-		Body:    soapBody{Content: requestBody},
-	}
-
-	envBytes, err := xml.Marshal(env)
-	if err != nil {
-		return nil, fmt.Errorf("marshal soap envelope: %w", err)
-	}
-
-	httpReq, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(envBytes))
-	if err != nil {
-		return nil, fmt.Errorf("create http request: %w", err)
+// NewClientWithCredentials creates a new WS client that asks provider for
+// the WS-Security credentials on every call. opts are applied to the
+// underlying SOAP client, e.g. to set a timeout or a logging HTTP client.
+func NewClientWithCredentials(
+	url string,
+	provider security.CredentialProvider,
+	clientCert tls.Certificate,
+	atPubKey *rsa.PublicKey,
+	opts ...soap.Option,
+) *Client {
+	c := &Client{
+		credentials: provider,
+		atPubKey:    atPubKey,
 	}
-	httpReq.Header.Set("Content-Type", "text/xml; charset=utf-8")
-	httpReq.Header.Set("SOAPAction", "https://servicos.portaldasfinancas.gov.pt/sgdtws/documentosTransporte/")
+	opts = append([]soap.Option{
+		soap.WithClientCertificate(clientCert),
+		soap.WithHeader(c.securityHeader),
+	}, opts...)
+	c.soap = soap.NewClient(url, opts...)
+	return c
+}
 
-	httpResp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("http post: %w", err)
-	}
-	defer httpResp.Body.Close()
+type credentialsContextKey struct{}
 
-	respBytes, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read http response: %w", err)
+// securityHeader builds a fresh WS-Security header with the credentials of
+// the current call: the ones passed explicitly to the call, if any, or else
+// the ones returned by the client's provider.
+func (c *Client) securityHeader(ctx context.Context) (interface{}, error) {
+	if creds, ok := ctx.Value(credentialsContextKey{}).(security.Credentials); ok {
+		return security.BuildAt(creds.Username, creds.Password, c.atPubKey, c.soap.Now())
 	}
+	return security.HeaderBuilder{
+		Credentials: c.credentials,
+		ATPublicKey: c.atPubKey,
+		Now:         c.soap.Now,
+	}.Build(ctx)
+}
 
-	var envelope soapResponseEnvelope
-	if err := xml.Unmarshal(respBytes, &envelope); err != nil {
-		fmt.Printf("\n=== AT TRANSPORT SOAP FAULT ===\nRequest XML:\n%s\n\nResponse XML:\n%s\n===============================\n\n", string(envBytes), string(respBytes))
-		return nil, fmt.Errorf("unmarshal soap response: %w", err)
-	}
-	if envelope.Body.Fault != nil {
-		fmt.Printf("\n=== AT TRANSPORT SOAP FAULT ===\nRequest XML:\n%s\n\nResponse XML:\n%s\n===============================\n\n", string(envBytes), string(respBytes))
-		return nil, errors.New(envelope.Body.Fault.FaultString)
+// EnvioDocumentoTransporte communicates a transport document with the given
+// credentials. If username is empty the client's credential provider is used.
+func (c *Client) EnvioDocumentoTransporte(username, password string, request *StockMovement) (*StockMovementResponse, error) {
+	ctx := context.Background()
+	if username != "" {
+		ctx = context.WithValue(ctx, credentialsContextKey{}, security.Credentials{Username: username, Password: password})
 	}
-
-	return envelope.Body.Content, nil
+	return c.EnvioDocumentoTransporteContext(ctx, request)
 }
 
-func (c *Client) EnvioDocumentoTransporte(username, password string, request *StockMovement) (*StockMovementResponse, error) {
-	// Update credentials for this call
-	c.username = username
-	c.password = password
+// EnvioDocumentoTransporteContext communicates a transport document with the
+// credentials returned by the client's provider for ctx.
+func (c *Client) EnvioDocumentoTransporteContext(ctx context.Context, request *StockMovement) (*StockMovementResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
 
 	// Wrap with a prefixed namespace (ns1:) so child elements are NOT in any namespace.
 	// If we let StockMovement's own XMLName set xmlns="" (default namespace), AT's XSD
@@ -146,14 +98,9 @@ func (c *Client) EnvioDocumentoTransporte(username, password string, request *St
 		StockMovement: request,
 	}
 
-	respBytes, err := c.call(req)
-	if err != nil {
-		return nil, err
-	}
-
 	var resp StockMovementResponse
-	if err := xml.Unmarshal(respBytes, &resp); err != nil {
-		return nil, fmt.Errorf("unmarshal response: %w", err)
+	if err := c.soap.CallContext(ctx, soapAction, req, &resp); err != nil {
+		return nil, err
 	}
 
 	return &resp, nil
@@ -2,8 +2,11 @@ package workDocuments
 
 import (
 	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/hooklift/gowsdl/soap"
 	"github.com/shopspring/decimal"
-    "github.com/hooklift/gowsdl/soap"
 )
 
 // Dates
@@ -16,26 +19,79 @@ func (t *SAFdateTimeType) MarshalText() ([]byte, error) {
 }
 
 // Decimals
+//
+// Quantities (SAFdecimalType) are written with exactly the precision they
+// carry, so 0.125 kg stays 0.125 and 3 units stays 3. Monetary values
+// (SAFmonetaryType) are rounded to two decimal places, except in UnitPrice
+// elements: unit prices are written with at least two decimal places and
+// keep any extra precision (e.g. 0.0125 €/un).
+
+func formatQuantity(d decimal.Decimal) string {
+	return d.String()
+}
+
+func formatMonetary(d decimal.Decimal) string {
+	return d.StringFixed(2)
+}
+
+func formatUnitPrice(d decimal.Decimal) string {
+	s := d.String()
+	parts := strings.Split(s, ".")
+	if len(parts) > 1 && len(parts[1]) > 2 {
+		return s
+	}
+	return d.StringFixed(2)
+}
+
+func parseDecimal(text []byte) (decimal.Decimal, error) {
+	d, err := decimal.NewFromString(strings.TrimSpace(string(text)))
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("parse decimal %q: %w", text, err)
+	}
+	return d, nil
+}
+
 func (d SAFdecimalType) MarshalText() ([]byte, error) {
-	return []byte(decimal.NewFromFloat(float64(d)).StringFixed(2)), nil
+	return []byte(formatQuantity(decimal.Decimal(d))), nil
+}
+
+func (d *SAFdecimalType) UnmarshalText(text []byte) error {
+	v, err := parseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*d = SAFdecimalType(v)
+	return nil
 }
 
 func (d SAFmonetaryType) MarshalText() ([]byte, error) {
-	return []byte(decimal.NewFromFloat(float64(d)).StringFixed(2)), nil
+	return []byte(formatMonetary(decimal.Decimal(d))), nil
+}
+
+func (d *SAFmonetaryType) UnmarshalText(text []byte) error {
+	v, err := parseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*d = SAFmonetaryType(v)
+	return nil
 }
 
 func (d *SAFdecimalType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	if d == nil {
 		return nil
 	}
-	return e.EncodeElement(decimal.NewFromFloat(float64(*d)).StringFixed(2), start)
+	return e.EncodeElement(formatQuantity(decimal.Decimal(*d)), start)
 }
 
 func (d *SAFmonetaryType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	if d == nil {
 		return nil
 	}
-	return e.EncodeElement(decimal.NewFromFloat(float64(*d)).StringFixed(2), start)
+	if start.Name.Local == "UnitPrice" {
+		return e.EncodeElement(formatUnitPrice(decimal.Decimal(*d)), start)
+	}
+	return e.EncodeElement(formatMonetary(decimal.Decimal(*d)), start)
 }
 
 // Special case for ATCUD - ensure it is never empty if it contains a value
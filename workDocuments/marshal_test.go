@@ -0,0 +1,99 @@
+package workDocuments
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLineDecimalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		quantity     string
+		unitPrice    string
+		wantQuantity string
+		wantPrice    string
+	}{
+		{
+			name:         "Fractional quantity keeps its precision",
+			quantity:     "0.3",
+			unitPrice:    "10",
+			wantQuantity: "0.3",
+			wantPrice:    "10.00",
+		},
+		{
+			name:         "Quantity with three decimals",
+			quantity:     "1.125",
+			unitPrice:    "2.5",
+			wantQuantity: "1.125",
+			wantPrice:    "2.50",
+		},
+		{
+			name:         "Unit price with four decimals",
+			quantity:     "100",
+			unitPrice:    "0.0125",
+			wantQuantity: "100",
+			wantPrice:    "0.0125",
+		},
+		{
+			name:         "Unit price with trailing zeros",
+			quantity:     "1",
+			unitPrice:    "3.1000",
+			wantQuantity: "1",
+			wantPrice:    "3.10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := SAFdecimalType(decimal.RequireFromString(tt.quantity))
+			p := SAFmonetaryType(decimal.RequireFromString(tt.unitPrice))
+			line := Line{Quantity: &q, UnitPrice: &p}
+
+			out, err := xml.Marshal(line)
+			if err != nil {
+				t.Fatalf("xml.Marshal() error = %v", err)
+			}
+			want := "<Line><Quantity>" + tt.wantQuantity + "</Quantity><UnitPrice>" + tt.wantPrice + "</UnitPrice></Line>"
+			if string(out) != want {
+				t.Errorf("xml.Marshal() = %s, want %s", out, want)
+			}
+
+			var got Line
+			if err := xml.Unmarshal(out, &got); err != nil {
+				t.Fatalf("xml.Unmarshal() error = %v", err)
+			}
+			if !decimal.Decimal(*got.Quantity).Equal(decimal.Decimal(q)) {
+				t.Errorf("Quantity = %s, want %s", decimal.Decimal(*got.Quantity), decimal.Decimal(q))
+			}
+			if !decimal.Decimal(*got.UnitPrice).Equal(decimal.Decimal(p)) {
+				t.Errorf("UnitPrice = %s, want %s", decimal.Decimal(*got.UnitPrice), decimal.Decimal(p))
+			}
+		})
+	}
+}
+
+func TestMonetaryRounding(t *testing.T) {
+	type total struct {
+		XMLName    xml.Name         `xml:"Totals"`
+		GrossTotal *SAFmonetaryType `xml:"GrossTotal"`
+	}
+	for value, want := range map[string]string{
+		"12.3":    "12.30",
+		"12.345":  "12.35",
+		"12.3449": "12.34",
+		"-0.125":  "-0.13",
+	} {
+		v := SAFmonetaryType(decimal.RequireFromString(value))
+		out, err := xml.Marshal(total{GrossTotal: &v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantXML := "<Totals><GrossTotal>" + want + "</GrossTotal></Totals>"; string(out) != wantXML {
+			t.Errorf("xml.Marshal(%s) = %s, want %s", value, out, wantXML)
+		}
+		if text, _ := v.MarshalText(); string(text) != want {
+			t.Errorf("MarshalText(%s) = %s, want %s", value, text, want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package workDocuments
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+// TransportDocumentResult is the typed outcome of a transport document
+// communication (new, cancelled or updated guide).
+type TransportDocumentResult struct {
+	// DocumentNumber is the document number echoed back by AT.
+	DocumentNumber string
+	// ATCUD is the unique document code echoed back by AT.
+	ATCUD string
+	// ATDocCodeID is the identification code assigned by AT to the guide.
+	// It must be kept to cancel or update the guide later.
+	ATDocCodeID string
+	// Status holds every ResponseStatus returned by AT.
+	Status []ResponseStatus
+}
+
+// Accepted reports whether AT accepted the communication. AT uses return
+// code 0 for success; any other code is a rejection.
+func (r *TransportDocumentResult) Accepted() bool {
+	if r == nil || len(r.Status) == 0 {
+		return false
+	}
+	for _, s := range r.Status {
+		if s.ReturnCode != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns a description of the first rejection, or nil if the
+// communication was accepted.
+func (r *TransportDocumentResult) Err() error {
+	if r.Accepted() {
+		return nil
+	}
+	for _, s := range r.Status {
+		if s.ReturnCode != 0 {
+			return fmt.Errorf("workDocuments: AT rejected document %s: %d %s", r.DocumentNumber, s.ReturnCode, s.ReturnMessage)
+		}
+	}
+	return fmt.Errorf("workDocuments: AT returned no status for document %s", r.DocumentNumber)
+}
+
+// TransportDocumentUpdate holds the fields that may be changed on a guide
+// that was already communicated to AT.
+type TransportDocumentUpdate struct {
+	// MovementStartTime is the new start of transport. Required, and it must
+	// not be in the past.
+	MovementStartTime time.Time
+	// MovementEndTime is the new end of transport. Leave zero to keep the
+	// end of the original document, which must then be after the new
+	// start.
+	MovementEndTime time.Time
+	// VehicleID is the new vehicle registration. Leave empty to keep the
+	// vehicle of the original document.
+	VehicleID string
+}
+
+// CancelTransportDocument cancels a guide that was already communicated to
+// AT. original is the document as it was first sent and atDocCodeID is the
+// code AT returned for it.
+func (c *Client) CancelTransportDocument(username, password string, original *StockMovement, atDocCodeID string) (*TransportDocumentResult, error) {
+	request, err := cancellationOf(original, atDocCodeID)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendTransportDocument(username, password, request)
+}
+
+// UpdateTransportDocument changes the start time and, optionally, the end
+// time and the vehicle of a guide that was already communicated to AT.
+// original is the document as it was first sent and atDocCodeID is the code
+// AT returned for it.
+func (c *Client) UpdateTransportDocument(username, password string, original *StockMovement, atDocCodeID string, update TransportDocumentUpdate) (*TransportDocumentResult, error) {
+	request, err := amendmentOf(original, atDocCodeID, update)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendTransportDocument(username, password, request)
+}
+
+func (c *Client) sendTransportDocument(username, password string, request *StockMovement) (*TransportDocumentResult, error) {
+	resp, err := c.EnvioDocumentoTransporte(username, password, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TransportDocumentResult{}
+	if resp.DocumentNumber != nil {
+		result.DocumentNumber = string(*resp.DocumentNumber)
+	} else if request.DocumentNumber != nil {
+		result.DocumentNumber = string(*request.DocumentNumber)
+	}
+	if resp.ATCUD != nil {
+		result.ATCUD = string(*resp.ATCUD)
+	}
+	if resp.ATDocCodeID != nil {
+		result.ATDocCodeID = string(*resp.ATDocCodeID)
+	} else if request.ATDocCodeID != nil {
+		result.ATDocCodeID = string(*request.ATDocCodeID)
+	}
+	for _, s := range resp.ResponseStatus {
+		if s != nil {
+			result.Status = append(result.Status, *s)
+		}
+	}
+
+	return result, nil
+}
+
+// referenceOf copies original and points it at the guide AT already knows.
+// Lines and addresses are shared with original; only top-level fields that
+// are changed afterwards are replaced.
+func referenceOf(original *StockMovement, atDocCodeID string) (*StockMovement, error) {
+	if original == nil {
+		return nil, fmt.Errorf("workDocuments: missing original StockMovement")
+	}
+	if atDocCodeID == "" {
+		return nil, fmt.Errorf("workDocuments: missing ATDocCodeID of the original document")
+	}
+	if original.ATDocCodeID != nil && *original.ATDocCodeID != "" && string(*original.ATDocCodeID) != atDocCodeID {
+		return nil, fmt.Errorf("workDocuments: ATDocCodeID %s does not match the original document (%s)", atDocCodeID, *original.ATDocCodeID)
+	}
+
+	request := *original
+	code := SAFPTtextTypeMandatoryMax200Car(atDocCodeID)
+	request.ATDocCodeID = &code
+	return &request, nil
+}
+
+func cancellationOf(original *StockMovement, atDocCodeID string) (*StockMovement, error) {
+	request, err := referenceOf(original, atDocCodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := MovementStatusA
+	request.MovementStatus = &status
+	return request, nil
+}
+
+func amendmentOf(original *StockMovement, atDocCodeID string, update TransportDocumentUpdate) (*StockMovement, error) {
+	if original != nil && original.MovementStatus != nil && *original.MovementStatus == MovementStatusA {
+		return nil, fmt.Errorf("workDocuments: cannot update a cancelled document")
+	}
+	if update.MovementStartTime.IsZero() {
+		return nil, fmt.Errorf("workDocuments: missing MovementStartTime in update")
+	}
+
+	request, err := referenceOf(original, atDocCodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := SAFdateTimeType(soap.CreateXsdDateTime(update.MovementStartTime, false))
+	request.MovementStartTime = &start
+
+	if !update.MovementEndTime.IsZero() {
+		end := SAFdateTimeType(soap.CreateXsdDateTime(update.MovementEndTime, false))
+		request.MovementEndTime = &end
+	}
+
+	if update.VehicleID != "" {
+		vehicle := SAFPTtextTypeMandatoryMax32Car(update.VehicleID)
+		request.VehicleID = &vehicle
+	}
+
+	return request, nil
+}
@@ -0,0 +1,114 @@
+package workDocuments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+)
+
+func TestCancellationOf(t *testing.T) {
+	now := time.Now()
+	original := validStockMovement(now)
+
+	got, err := cancellationOf(original, "123456789")
+	if err != nil {
+		t.Fatalf("cancellationOf() error = %v", err)
+	}
+	if *got.MovementStatus != MovementStatusA {
+		t.Errorf("MovementStatus = %s, want A", *got.MovementStatus)
+	}
+	if got.ATDocCodeID == nil || *got.ATDocCodeID != "123456789" {
+		t.Errorf("ATDocCodeID = %v, want 123456789", got.ATDocCodeID)
+	}
+	if *original.MovementStatus != MovementStatusN || original.ATDocCodeID != nil {
+		t.Errorf("cancellationOf() modified the original document")
+	}
+	if err := got.validate(now); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+
+	if _, err := cancellationOf(original, ""); err == nil {
+		t.Errorf("cancellationOf() without ATDocCodeID, want error")
+	}
+}
+
+func TestAmendmentOf(t *testing.T) {
+	now := time.Now()
+	original := validStockMovement(now)
+	newStart := now.Add(2 * time.Hour).Truncate(time.Second)
+
+	got, err := amendmentOf(original, "123456789", TransportDocumentUpdate{
+		MovementStartTime: newStart,
+		VehicleID:         "CC-11-DD",
+	})
+	if err != nil {
+		t.Fatalf("amendmentOf() error = %v", err)
+	}
+	if start := (*soap.XSDDateTime)(got.MovementStartTime).ToGoTime(); !start.Equal(newStart) {
+		t.Errorf("MovementStartTime = %s, want %s", start, newStart)
+	}
+	if *got.VehicleID != "CC-11-DD" {
+		t.Errorf("VehicleID = %s, want CC-11-DD", *got.VehicleID)
+	}
+	if *got.MovementStatus != MovementStatusN {
+		t.Errorf("MovementStatus = %s, want N", *got.MovementStatus)
+	}
+	if *original.VehicleID != "AA-00-BB" {
+		t.Errorf("amendmentOf() modified the original document")
+	}
+
+	if _, err := amendmentOf(original, "123456789", TransportDocumentUpdate{}); err == nil {
+		t.Errorf("amendmentOf() without MovementStartTime, want error")
+	}
+}
+
+// TestTransportDocumentStarted checks that a guide can be cancelled or
+// amended after its transport started.
+func TestTransportDocumentStarted(t *testing.T) {
+	now := time.Now()
+	original := validStockMovement(now.Add(-3 * time.Hour))
+
+	cancellation, err := cancellationOf(original, "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cancellation.validate(now); err != nil {
+		t.Errorf("validate(cancellation) error = %v", err)
+	}
+
+	// The original started 2 hours ago and ends in 2 hours.
+	update := TransportDocumentUpdate{MovementStartTime: now.Add(3 * time.Hour)}
+	amendment, err := amendmentOf(original, "123456789", update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := amendment.validate(now); err == nil {
+		t.Errorf("validate(amendment) starting after the original end, want error")
+	}
+
+	update.MovementEndTime = now.Add(6 * time.Hour).Truncate(time.Second)
+	amendment, err = amendmentOf(original, "123456789", update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end := (*soap.XSDDateTime)(amendment.MovementEndTime).ToGoTime(); !end.Equal(update.MovementEndTime) {
+		t.Errorf("MovementEndTime = %s, want %s", end, update.MovementEndTime)
+	}
+	if err := amendment.validate(now); err != nil {
+		t.Errorf("validate(amendment) error = %v", err)
+	}
+
+	// Neither an amendment nor a new guide can start in the past.
+	update.MovementStartTime = now.Add(-time.Hour)
+	amendment, err = amendmentOf(original, "123456789", update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := amendment.validate(now); err == nil {
+		t.Errorf("validate(amendment) starting in the past, want error")
+	}
+	if err := original.validate(now); err == nil {
+		t.Errorf("validate(original) starting in the past, want error")
+	}
+}
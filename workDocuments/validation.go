@@ -0,0 +1,131 @@
+package workDocuments
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+	"github.com/shopspring/decimal"
+)
+
+var postalCodePTRegexp = regexp.MustCompile(`^[0-9]{4}-[0-9]{3}$`)
+
+// Validate checks a transport document against the AT rules that can be
+// verified locally, so that obvious rejections are caught before the
+// document is sent with [Client.EnvioDocumentoTransporte].
+//
+// It returns the first rule violated, or nil if the document is valid.
+func (s *StockMovement) Validate() error {
+	return s.validate(time.Now())
+}
+
+func (s *StockMovement) validate(now time.Time) error {
+	if s == nil {
+		return fmt.Errorf("workDocuments: missing StockMovement")
+	}
+
+	// Exactly one of CustomerTaxID / SupplierTaxID (xsd:choice)
+	hasCustomer := s.CustomerTaxID != nil && *s.CustomerTaxID != ""
+	hasSupplier := s.SupplierTaxID != nil && *s.SupplierTaxID != ""
+	if hasCustomer == hasSupplier {
+		return fmt.Errorf("workDocuments: exactly one of CustomerTaxID and SupplierTaxID must be set")
+	}
+
+	if s.ATCUD == nil || *s.ATCUD == "" {
+		return fmt.Errorf("workDocuments: missing ATCUD")
+	}
+
+	if s.MovementStatus == nil {
+		return fmt.Errorf("workDocuments: missing MovementStatus")
+	}
+	switch *s.MovementStatus {
+	case MovementStatusN, MovementStatusT:
+		// Ignore
+	case MovementStatusA:
+		// A cancelled document only makes sense for a guide that AT
+		// already knows about, i.e. one with an ATDocCodeID.
+		if s.ATDocCodeID == nil || *s.ATDocCodeID == "" {
+			return fmt.Errorf("workDocuments: MovementStatus A is only allowed to cancel a communicated document (missing ATDocCodeID)")
+		}
+	default:
+		return fmt.Errorf("workDocuments: invalid MovementStatus: %s", *s.MovementStatus)
+	}
+
+	if s.MovementType == nil {
+		return fmt.Errorf("workDocuments: missing MovementType")
+	}
+	switch *s.MovementType {
+	case MovementTypeGR, MovementTypeGA, MovementTypeGC, MovementTypeGD:
+		// Ignore
+	case MovementTypeGT:
+		if s.VehicleID == nil || *s.VehicleID == "" {
+			return fmt.Errorf("workDocuments: missing VehicleID, mandatory for MovementType GT")
+		}
+	default:
+		return fmt.Errorf("workDocuments: invalid MovementType: %s", *s.MovementType)
+	}
+
+	// Postal codes, only Portuguese ones have a known format
+	addresses := []struct {
+		name    string
+		address *AddressStructurePT
+	}{
+		{"CompanyAddress", s.CompanyAddress},
+		{"CustomerAddress", s.CustomerAddress},
+		{"AddressTo", s.AddressTo},
+		{"AddressFrom", s.AddressFrom},
+	}
+	for _, a := range addresses {
+		if a.address == nil || a.address.PostalCode == nil || a.address.Country != "PT" {
+			continue
+		}
+		if !postalCodePTRegexp.MatchString(string(*a.address.PostalCode)) {
+			return fmt.Errorf("workDocuments: invalid %s.PostalCode, must be NNNN-NNN: %s", a.name, *a.address.PostalCode)
+		}
+	}
+
+	// Movement times
+	if s.MovementStartTime == nil {
+		return fmt.Errorf("workDocuments: missing MovementStartTime")
+	}
+	start := (*soap.XSDDateTime)(s.MovementStartTime).ToGoTime()
+	// A cancellation repeats the start time of a guide whose transport may
+	// already have started. Amendments set a new one, which must be ahead.
+	if *s.MovementStatus != MovementStatusA && start.Before(now) {
+		return fmt.Errorf("workDocuments: invalid MovementStartTime, must not be in the past: %s", start.Format(time.RFC3339))
+	}
+	if s.MovementEndTime != nil {
+		end := (*soap.XSDDateTime)(s.MovementEndTime).ToGoTime()
+		if !start.Before(end) {
+			return fmt.Errorf("workDocuments: invalid MovementStartTime, must be before MovementEndTime: %s >= %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+	}
+
+	// Lines
+	if len(s.Line) == 0 {
+		return fmt.Errorf("workDocuments: missing Line")
+	}
+	for i, line := range s.Line {
+		if line == nil {
+			return fmt.Errorf("workDocuments: missing Line[%d]", i)
+		}
+
+		if line.ProductDescription == nil || *line.ProductDescription == "" {
+			return fmt.Errorf("workDocuments: missing Line[%d].ProductDescription", i)
+		}
+
+		if line.Quantity == nil {
+			return fmt.Errorf("workDocuments: missing Line[%d].Quantity", i)
+		}
+		if !decimal.Decimal(*line.Quantity).IsPositive() {
+			return fmt.Errorf("workDocuments: invalid Line[%d].Quantity, must be positive: %s", i, decimal.Decimal(*line.Quantity))
+		}
+
+		if line.UnitOfMeasure == nil || *line.UnitOfMeasure == "" {
+			return fmt.Errorf("workDocuments: missing Line[%d].UnitOfMeasure", i)
+		}
+	}
+
+	return nil
+}
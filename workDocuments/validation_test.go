@@ -0,0 +1,156 @@
+package workDocuments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hooklift/gowsdl/soap"
+	"github.com/shopspring/decimal"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func dateTime(t time.Time) *SAFdateTimeType {
+	v := SAFdateTimeType(soap.CreateXsdDateTime(t, false))
+	return &v
+}
+
+func validStockMovement(now time.Time) *StockMovement {
+	quantity := SAFdecimalType(decimal.NewFromInt(2))
+	return &StockMovement{
+		DocumentNumber:    ptr(SAFPTtextTypeMandatoryMax60Car("GT A/1")),
+		ATCUD:             ptr(SAFPTtextTypeMandatoryMax100Car("ABCD1234-1")),
+		MovementStatus:    ptr(MovementStatusN),
+		MovementType:      ptr(MovementTypeGT),
+		CustomerTaxID:     ptr(SAFPTtextTypeMandatoryMax20Car("999999990")),
+		AddressFrom:       &AddressStructurePT{PostalCode: ptr(PostalCodePT("4705-111")), Country: "PT"},
+		AddressTo:         &AddressStructurePT{PostalCode: ptr(PostalCodePT("1000-001")), Country: "PT"},
+		MovementStartTime: dateTime(now.Add(time.Hour)),
+		MovementEndTime:   dateTime(now.Add(5 * time.Hour)),
+		VehicleID:         ptr(SAFPTtextTypeMandatoryMax32Car("AA-00-BB")),
+		Line: []*Line{{
+			ProductDescription: ptr(SAFPTtextTypeMandatoryMax200Car("Parafusos")),
+			Quantity:           &quantity,
+			UnitOfMeasure:      ptr(SAFPTtextTypeMandatoryMax20Car("UN")),
+		}},
+	}
+}
+
+func TestStockMovementValidate(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name    string
+		mutate  func(s *StockMovement)
+		wantErr bool
+	}{
+		{
+			name:    "Valid GT",
+			mutate:  func(s *StockMovement) {},
+			wantErr: false,
+		},
+		{
+			name:    "Both CustomerTaxID and SupplierTaxID",
+			mutate:  func(s *StockMovement) { s.SupplierTaxID = ptr(SAFPTtextTypeMandatoryMax20Car("503140600")) },
+			wantErr: true,
+		},
+		{
+			name:    "Neither CustomerTaxID nor SupplierTaxID",
+			mutate:  func(s *StockMovement) { s.CustomerTaxID = nil },
+			wantErr: true,
+		},
+		{
+			name:    "Invalid postal code",
+			mutate:  func(s *StockMovement) { s.AddressTo.PostalCode = ptr(PostalCodePT("1000")) },
+			wantErr: true,
+		},
+		{
+			name: "Foreign postal code",
+			mutate: func(s *StockMovement) {
+				s.AddressTo = &AddressStructurePT{PostalCode: ptr(PostalCodePT("28001")), Country: "ES"}
+			},
+		},
+		{
+			name:    "Start time in the past",
+			mutate:  func(s *StockMovement) { s.MovementStartTime = dateTime(now.Add(-time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name: "Start time in the past on an amendment",
+			mutate: func(s *StockMovement) {
+				s.MovementStartTime = dateTime(now.Add(-time.Minute))
+				s.ATDocCodeID = ptr(SAFPTtextTypeMandatoryMax200Car("123456789"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Start time in the past on a cancellation",
+			mutate: func(s *StockMovement) {
+				s.MovementStartTime = dateTime(now.Add(-time.Minute))
+				s.MovementStatus = ptr(MovementStatusA)
+				s.ATDocCodeID = ptr(SAFPTtextTypeMandatoryMax200Car("123456789"))
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Start time after end time",
+			mutate:  func(s *StockMovement) { s.MovementEndTime = dateTime(now.Add(30 * time.Minute)) },
+			wantErr: true,
+		},
+		{
+			name:    "Line without unit of measure",
+			mutate:  func(s *StockMovement) { s.Line[0].UnitOfMeasure = nil },
+			wantErr: true,
+		},
+		{
+			name: "Line with zero quantity",
+			mutate: func(s *StockMovement) {
+				q := SAFdecimalType(decimal.Zero)
+				s.Line[0].Quantity = &q
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Missing ATCUD",
+			mutate:  func(s *StockMovement) { s.ATCUD = nil },
+			wantErr: true,
+		},
+		{
+			name:    "GT without VehicleID",
+			mutate:  func(s *StockMovement) { s.VehicleID = nil },
+			wantErr: true,
+		},
+		{
+			name: "GR without VehicleID",
+			mutate: func(s *StockMovement) {
+				s.MovementType = ptr(MovementTypeGR)
+				s.VehicleID = nil
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Status A on a new document",
+			mutate:  func(s *StockMovement) { s.MovementStatus = ptr(MovementStatusA) },
+			wantErr: true,
+		},
+		{
+			name: "Status A cancelling a communicated document",
+			mutate: func(s *StockMovement) {
+				s.MovementStatus = ptr(MovementStatusA)
+				s.ATDocCodeID = ptr(SAFPTtextTypeMandatoryMax200Car("123456789"))
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validStockMovement(now)
+			tt.mutate(s)
+			if err := s.validate(now); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
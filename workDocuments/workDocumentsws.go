@@ -5,7 +5,9 @@ package workDocuments
 import (
 	"context"
 	"encoding/xml"
+	atsoap "github.com/hestiatechnology/autoridadetributaria/soap"
 	"github.com/hooklift/gowsdl/soap"
+	"github.com/shopspring/decimal"
 	"time"
 )
 
@@ -55,9 +57,9 @@ type SAFPTtextTypeMandatoryMax20Car string
 
 type SAFdateType soap.XSDDate
 
-type SAFdecimalType float64
+type SAFdecimalType decimal.Decimal
 
-type SAFmonetaryType float64
+type SAFmonetaryType decimal.Decimal
 
 type SAFPTPortugueseVatNumber int32
 
@@ -179,10 +181,10 @@ type DocumentosTransporte interface {
 }
 
 type documentosTransporte struct {
-	client *soap.Client
+	client atsoap.Caller
 }
 
-func NewDocumentosTransporte(client *soap.Client) DocumentosTransporte {
+func NewDocumentosTransporte(client atsoap.Caller) DocumentosTransporte {
 	return &documentosTransporte{
 		client: client,
 	}